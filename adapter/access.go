@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Role limits the requests that a client may send. The Kenwood commands and FLRig methods are checked like the
+// corresponding Hamlib requests.
+type Role string
+
+// All roles of a client.
+const (
+	// RoleFull permits all requests.
+	RoleFull Role = "full"
+	// RoleTune permits all requests except the ones that key the TRX or change the output power.
+	RoleTune Role = "tune"
+	// RoleReadOnly permits only the requests that read the state of the TRX or change the state of the connection.
+	RoleReadOnly Role = "read_only"
+)
+
+// ParseRole returns the role with the given name. An empty name is returned as empty role.
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	switch role {
+	case "", RoleFull, RoleTune, RoleReadOnly:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %s", name)
+	}
+}
+
+// txCommands key the TRX or change its output power.
+var txCommands = map[string]bool{
+	"set_ptt":           true,
+	"send_morse":        true,
+	"stop_morse":        true,
+	"set_func_tuner":    true,
+	"set_level_rfpower": true,
+}
+
+// connectionCommands change only the state of the Hamlib connection, not the state of the TRX.
+var connectionCommands = map[string]bool{
+	"set_vfo_opt":   true,
+	"set_vfo":       true,
+	"set_trn":       true,
+	"set_lock_mode": true,
+}
+
+// permits indicates if the role permits the request with the given lower case command key. Without role, all
+// requests are permitted.
+func (r Role) permits(key string) bool {
+	switch r {
+	case RoleReadOnly:
+		return !txCommands[key] && (!strings.HasPrefix(key, "set_") || connectionCommands[key])
+	case RoleTune:
+		return !txCommands[key]
+	default:
+		return true
+	}
+}
+
+// notPermitted returns an error for a request that the role of the client does not permit.
+func notPermitted(role Role) error {
+	return newRequestError(resultSecurity, "the role %s does not permit this request", role)
+}
+
+// accepts indicates if a client from the given remote address may connect to the adapter. The address must not be
+// in the deny list and, if there is an allow list, it must be in the allow list. Connections without remote IP
+// address are always accepted.
+func (s *Settings) accepts(remoteAddress net.Addr) bool {
+	ip := remoteIP(remoteAddress)
+	if ip == nil {
+		return true
+	}
+	if containsIP(s.Deny, ip) {
+		return false
+	}
+	return len(s.Allow) == 0 || containsIP(s.Allow, ip)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptConnection checks the given remote address against the access lists of the current settings, a refused
+// connection is logged.
+func (a *Adapter) acceptConnection(frontend string, remoteAddress net.Addr) bool {
+	if a.settings.Load().accepts(remoteAddress) {
+		return true
+	}
+	err := fmt.Errorf("%s connection from %s refused by the access lists", frontend, remoteAddress)
+	log.Print(err)
+	a.errors.add(err)
+	return false
+}
+
+// acceptHTTP wraps the given handler of an HTTP frontend, the requests from clients that the access lists refuse are
+// answered with 403 Forbidden.
+func (a *Adapter) acceptHTTP(frontend string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.acceptConnection(frontend, parseRemoteAddress(r.RemoteAddr)) {
+			http.Error(w, "refused by the access lists", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
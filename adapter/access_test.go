@@ -0,0 +1,173 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole(" Read_Only ")
+	require.NoError(t, err)
+	assert.Equal(t, RoleReadOnly, role)
+
+	role, err = ParseRole("")
+	require.NoError(t, err)
+	assert.Equal(t, Role(""), role)
+
+	_, err = ParseRole("admin")
+	assert.Error(t, err)
+}
+
+func TestRole_Permits(t *testing.T) {
+	tt := []struct {
+		key      string
+		readOnly bool
+		tune     bool
+	}{
+		{"get_freq", true, true},
+		{"dump_caps", true, true},
+		{"get_level_rfpower", true, true},
+		{"set_vfo", true, true},
+		{"set_trn", true, true},
+		{"wait_morse", true, true},
+		{"set_freq", false, true},
+		{"set_mode", false, true},
+		{"set_level_af", false, true},
+		{"set_func_lock", false, true},
+		{"set_ptt", false, false},
+		{"send_morse", false, false},
+		{"set_func_tuner", false, false},
+		{"set_level_rfpower", false, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.readOnly, RoleReadOnly.permits(tc.key), "read only")
+			assert.Equal(t, tc.tune, RoleTune.permits(tc.key), "tune")
+			assert.True(t, RoleFull.permits(tc.key), "full")
+			assert.True(t, Role("").permits(tc.key), "no role")
+		})
+	}
+}
+
+func TestSettings_Accepts(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	_, printer, _ := net.ParseCIDR("192.168.1.99/32")
+	addr := func(ip string) net.Addr {
+		return &net.TCPAddr{IP: net.ParseIP(ip), Port: 4532}
+	}
+
+	open := &Settings{}
+	assert.True(t, open.accepts(addr("10.0.0.1")))
+
+	denied := &Settings{Deny: []*net.IPNet{printer}}
+	assert.True(t, denied.accepts(addr("192.168.1.10")))
+	assert.False(t, denied.accepts(addr("192.168.1.99")))
+
+	allowed := &Settings{Allow: []*net.IPNet{lan}, Deny: []*net.IPNet{printer}}
+	assert.True(t, allowed.accepts(addr("192.168.1.10")))
+	assert.False(t, allowed.accepts(addr("192.168.1.99")), "deny wins")
+	assert.False(t, allowed.accepts(addr("10.0.0.1")))
+	assert.True(t, allowed.accepts(nil), "no remote address")
+}
+
+func TestE2E_Roles(t *testing.T) {
+	setup := startE2E(t, 0)
+	_, localhost, _ := net.ParseCIDR("127.0.0.1/32")
+	setup.adapter.Reconfigure(Settings{
+		Role:    RoleReadOnly,
+		Clients: []ClientSettings{{Networks: []*net.IPNet{localhost}, Role: RoleTune}},
+	})
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	// the requests are sent in this order on the same connection, the role changes with the settings
+	tt := []struct {
+		role     Role
+		request  string
+		expected string
+	}{
+		{"", `F 14074000`, "RPRT 0"},
+		{"", `L AF 0.5`, "RPRT 0"},
+		{"", `L RFPOWER 0.5`, "RPRT -19"},
+		{"", `T 1`, "RPRT -19"},
+		{"", `\send_morse CQ`, "RPRT -19"},
+		{RoleReadOnly, `f`, "14074000"},
+		{RoleReadOnly, `V VFOB`, "RPRT 0"},
+		{RoleReadOnly, `F 7074000`, "RPRT -19"},
+		{RoleReadOnly, `T 1`, "RPRT -19"},
+		{RoleFull, `T 1`, "RPRT 0"},
+		{RoleFull, `T 0`, "RPRT 0"},
+	}
+	for _, tc := range tt {
+		if tc.role != "" {
+			settings := setup.adapter.Settings()
+			settings.Clients = []ClientSettings{{Networks: []*net.IPNet{localhost}, Role: tc.role}}
+			setup.adapter.Reconfigure(settings)
+		}
+		_, err := fmt.Fprintln(conn, tc.request)
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err, tc.request)
+		assert.Equal(t, tc.expected, line[:len(line)-1], tc.request)
+	}
+}
+
+func TestE2E_AccessLists(t *testing.T) {
+	setup := startE2E(t, 0)
+	_, localhost, _ := net.ParseCIDR("127.0.0.1/32")
+	setup.adapter.Reconfigure(Settings{Deny: []*net.IPNet{localhost}})
+
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "the connection is closed by the adapter")
+
+	setup.adapter.Reconfigure(Settings{Allow: []*net.IPNet{localhost}})
+	rig := setup.openHamlib(t, 0)
+	_, err = rig.Frequency(e2eContext(t))
+	assert.NoError(t, err)
+}
+
+func TestAcceptHTTP(t *testing.T) {
+	setup := startE2E(t, 0)
+	_, remote, _ := net.ParseCIDR("192.0.2.0/24")
+	handler := setup.adapter.acceptHTTP("Dashboard", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tc := range []struct {
+		name     string
+		settings Settings
+		expected int
+	}{
+		{"no access lists", Settings{}, http.StatusNoContent},
+		{"denied", Settings{Deny: []*net.IPNet{remote}}, http.StatusForbidden},
+		{"not allowed", Settings{Allow: []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(32, 32)}}}, http.StatusForbidden},
+		{"allowed", Settings{Allow: []*net.IPNet{remote}}, http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setup.adapter.Reconfigure(tc.settings)
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/status", nil)
+			request.RemoteAddr = "192.0.2.1:1234"
+
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.expected, recorder.Code)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package adapter
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +9,8 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	hamlib "github.com/ftl/rigproxy/pkg/client"
@@ -15,52 +18,122 @@ import (
 	tci "github.com/ftl/tci/client"
 )
 
-func Listen(localAddress string, tciHost *net.TCPAddr, trx int, done <-chan struct{}, traceHamlib, traceTCI bool, noDigimodes bool, version string) (*Adapter, error) {
-	listener, err := net.Listen("tcp", localAddress)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open local port %s: %w", localAddress, err)
+// TRXAddress maps a TRX of the TCI host to the local address on which the adapter listens for incoming
+// Hamlib connections for this TRX.
+type TRXAddress struct {
+	TRX          int
+	LocalAddress string
+	Settings     TRXSettings
+}
+
+// Listen starts the adapter with the given settings, the settings of the TRX are taken from the TRX addresses. If a
+// recorder is given, the adapter records the Hamlib and the TCI communication and closes the recorder when the adapter
+// is closed.
+func Listen(trxAddresses []TRXAddress, tciHost *TCIHost, done <-chan struct{}, settings Settings, version string, recorder *Recorder) (*Adapter, error) {
+	if len(trxAddresses) == 0 {
+		return nil, fmt.Errorf("no TRX selected")
 	}
 
 	result := &Adapter{
-		listener:    listener,
-		trxData:     newTRXData(trx),
-		closed:      make(chan struct{}),
-		traceHamlib: traceHamlib,
-		traceTCI:    traceTCI,
-		noDigimodes: noDigimodes,
-		version:     version,
+		closed:   make(chan struct{}),
+		stopped:  make(chan struct{}),
+		version:  version,
+		recorder: recorder,
+		metrics:  newAdapterMetrics(),
+		clients:  newHamlibClients(),
+		errors:   newRecentErrors(recentErrorsSize),
 	}
-	result.tciClient = tci.KeepOpen(tciHost, 10*time.Second, traceTCI)
-	result.tciClient.Notify(result.trxData)
+	settings.TRX = make(map[int]TRXSettings, len(trxAddresses))
+	for _, trxAddress := range trxAddresses {
+		listener, err := net.Listen("tcp", trxAddress.LocalAddress)
+		if err != nil {
+			result.closeListeners()
+			return nil, fmt.Errorf("cannot open local port %s for TRX %d: %w", trxAddress.LocalAddress, trxAddress.TRX, err)
+		}
+		result.trxListeners = append(result.trxListeners, &trxListener{
+			listener: listener,
+			trxData:  newTRXData(trxAddress.TRX),
+		})
+		settings.TRX[trxAddress.TRX] = trxAddress.Settings
+	}
+	result.settings.Store(&settings)
+
+	recorder.Record(RecordedEvent{Stream: streamSession, Session: result.recordedSession(settings)})
 
-	go result.run()
+	result.tciDialer = newTCIDialer(tciHost, settings.TraceTCI, recorder)
+	result.tciClient = tci.KeepOpenWithDialer(result.tciDialer, 10*time.Second, false, result.metrics)
+	result.tciDevice = &announcedDevice{adapter: result}
+	result.tciClient.Notify(result.tciDevice)
+	result.watchdog = newTXWatchdog(result)
+	result.tciClient.Notify(result.watchdog)
+	for _, trxListener := range result.trxListeners {
+		result.tciClient.Notify(trxListener.trxData)
+		log.Printf("listening for Hamlib connections to TRX %d on %s", trxListener.trxData.trx, trxListener.Addr())
+		go result.run(trxListener, trxListener.listener)
+	}
 	go func() {
 		select {
 		case <-done:
-			result.Close()
-			return
 		case <-result.closed:
-			listener.Close()
-			result.Close()
-			return
 		}
+		result.watchdog.releaseAll()
+		result.Close()
+		result.closeListeners()
+		result.tciClient.Disconnect()
+		result.tciDialer.Close()
+		result.recorder.Close()
+		close(result.stopped)
 	}()
 
 	return result, nil
 }
 
 type Adapter struct {
-	listener    net.Listener
-	tciClient   *tci.Client
-	trxData     *TRXData
-	closed      chan struct{}
-	traceHamlib bool
-	traceTCI    bool
-	noDigimodes bool
-	version     string
+	trxListeners []*trxListener
+	tciClient    *tci.Client
+	closed       chan struct{}
+	stopped      chan struct{}
+	version      string
+	recorder     *Recorder
+	tciDialer    *tciDialer
+	lastConnID   atomic.Int64
+	metrics      *adapterMetrics
+	clients      *hamlibClients
+	errors       *recentErrors
+	tciDevice    *announcedDevice
+	watchdog     *txWatchdog
+
+	settingsLock sync.Mutex
+	settings     atomic.Pointer[Settings]
 }
 
-func (a *Adapter) run() {
+// trxListener accepts the incoming Hamlib connections for one TRX. The listener is replaced when the local
+// address of the TRX changes.
+type trxListener struct {
+	mutex    sync.Mutex
+	listener net.Listener
+	trxData  *TRXData
+}
+
+func (l *trxListener) Addr() net.Addr {
+	return l.currentListener().Addr()
+}
+
+func (l *trxListener) currentListener() net.Listener {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.listener
+}
+
+func (l *trxListener) switchListener(listener net.Listener) net.Listener {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	result := l.listener
+	l.listener = listener
+	return result
+}
+
+func (a *Adapter) run(trxListener *trxListener, listener net.Listener) {
 	for {
 		select {
 		case <-a.closed:
@@ -68,23 +141,46 @@ func (a *Adapter) run() {
 		default:
 		}
 
-		c, err := a.listener.Accept()
+		c, err := listener.Accept()
 		if err != nil {
+			if trxListener.currentListener() != listener {
+				// the TRX was moved to another local address
+				return
+			}
 			log.Print(err)
 			a.Close()
 			return
 		}
 
+		if !a.acceptConnection("Hamlib", c.RemoteAddr()) {
+			c.Close()
+			continue
+		}
+		if tlsConfig := a.settings.Load().TLS; tlsConfig != nil {
+			c = tls.Server(c, tlsConfig)
+		}
+
+		trx := trxListener.trxData.trx
+		settings := a.settingsSource(trx, c.RemoteAddr())
 		conn := inboundConnection{
 			conn:          c,
 			tciClient:     a.tciClient,
-			trxData:       a.trxData,
+			tciDevice:     a.tciDevice,
+			watchdog:      a.watchdog,
+			trxData:       trxListener.trxData,
 			adapterClosed: a.closed,
 			closed:        make(chan struct{}),
-			trace:         a.traceHamlib,
-			noDigimodes:   a.noDigimodes,
+			settings:      settings,
+			vfoMode:       settings.current().vfoMode,
 			version:       a.version,
-		}
+			recorder:      a.recorder,
+			connID:        a.lastConnID.Add(1),
+			metrics:       a.metrics,
+			clients:       a.clients,
+			errors:        a.errors,
+		}
+		a.metrics.hamlibConnectionOpened(trx)
+		a.clients.add(conn.connID, trx, c.RemoteAddr().String())
 		go conn.run()
 		go func() {
 			select {
@@ -99,6 +195,82 @@ func (a *Adapter) run() {
 	}
 }
 
+// Rebind moves the Hamlib listeners of the TRX to the given local addresses. The open Hamlib connections are not
+// affected. The TRX selection of the adapter cannot be changed, TRX that are not provided by the adapter are ignored.
+func (a *Adapter) Rebind(trxAddresses []TRXAddress) error {
+	var errs []error
+	for _, trxAddress := range trxAddresses {
+		trxListener := a.trxListener(trxAddress.TRX)
+		if trxListener == nil {
+			errs = append(errs, fmt.Errorf("TRX %d is not used by the adapter", trxAddress.TRX))
+			continue
+		}
+		if sameAddress(trxListener.Addr(), trxAddress.LocalAddress) {
+			continue
+		}
+		listener, err := net.Listen("tcp", trxAddress.LocalAddress)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot open local port %s for TRX %d: %w", trxAddress.LocalAddress, trxAddress.TRX, err))
+			continue
+		}
+		old := trxListener.switchListener(listener)
+		old.Close()
+		log.Printf("listening for Hamlib connections to TRX %d on %s", trxAddress.TRX, listener.Addr())
+		go a.run(trxListener, listener)
+	}
+	return errors.Join(errs...)
+}
+
+// sameAddress indicates if the given listener address was opened for the given local address.
+func sameAddress(addr net.Addr, localAddress string) bool {
+	if addr.String() == localAddress {
+		return true
+	}
+	resolved, err := net.ResolveTCPAddr("tcp", localAddress)
+	if err != nil {
+		return false
+	}
+	listenerAddr, ok := addr.(*net.TCPAddr)
+	if !ok || resolved.Port != listenerAddr.Port {
+		return false
+	}
+	if resolved.IP == nil || resolved.IP.IsUnspecified() {
+		return listenerAddr.IP.IsUnspecified()
+	}
+	return resolved.IP.Equal(listenerAddr.IP)
+}
+
+// SetTCIHost connects the adapter to the given TCI host. The open Hamlib connections are not affected, the new TCI
+// host sends its state like after a reconnect.
+func (a *Adapter) SetTCIHost(tciHost *TCIHost) {
+	log.Printf("switching to the TCI host %s", tciHost)
+	a.tciDialer.setTCIHost(tciHost)
+}
+
+// Addr returns the local address of the Hamlib listener for the given TRX, or nil if the TRX is not provided.
+func (a *Adapter) Addr(trx int) net.Addr {
+	trxListener := a.trxListener(trx)
+	if trxListener == nil {
+		return nil
+	}
+	return trxListener.Addr()
+}
+
+func (a *Adapter) trxListener(trx int) *trxListener {
+	for _, trxListener := range a.trxListeners {
+		if trxListener.trxData.trx == trx {
+			return trxListener
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) closeListeners() {
+	for _, trxListener := range a.trxListeners {
+		trxListener.currentListener().Close()
+	}
+}
+
 func (a *Adapter) Close() {
 	select {
 	case <-a.closed:
@@ -107,27 +279,51 @@ func (a *Adapter) Close() {
 	}
 }
 
+// Wait waits until the adapter is closed and all transmissions that were started through the adapter are ended.
 func (a *Adapter) Wait() {
-	<-a.closed
+	<-a.stopped
 }
 
 type inboundConnection struct {
-	conn          io.ReadWriteCloser
-	tciClient     *tci.Client
-	trxData       *TRXData
-	adapterClosed <-chan struct{}
-	closed        chan struct{}
-	trace         bool
-	noDigimodes   bool
-	version       string
-	modeLocked    bool
+	conn           io.ReadWriteCloser
+	tciClient      *tci.Client
+	tciDevice      *announcedDevice
+	watchdog       *txWatchdog
+	trxData        *TRXData
+	adapterClosed  <-chan struct{}
+	closed         chan struct{}
+	settings       settingsSource
+	version        string
+	modeLocked     bool
+	vfoMode        bool
+	authenticated  bool
+	wrongPasswords int
+	currentVFO     atomic.Int32
+	transceive     string
+	recorder       *Recorder
+	connID         int64
+	metrics        *adapterMetrics
+	clients        *hamlibClients
+	errors         *recentErrors
 }
 
 func (c *inboundConnection) run() {
 	defer c.conn.Close()
-	r := protocol.NewRequestReader(c.conn)
+	defer c.watchdog.release(c.txOwner())
+	if c.metrics != nil {
+		defer c.metrics.hamlibConnectionClosed(c.trxData.trx)
+	}
+	if c.clients != nil {
+		defer c.clients.remove(c.connID)
+	}
+	r := newRequestReader(c.conn)
+	if c.recorder != nil {
+		r.lineRead = func(line string) {
+			c.record(directionRX, redactPassword(line))
+		}
+	}
 	for {
-		req, err := r.ReadRequest()
+		req, err := r.ReadRequest(c.vfoMode)
 		if err == io.EOF {
 			log.Print("connection EOF")
 			c.Close()
@@ -135,22 +331,34 @@ func (c *inboundConnection) run() {
 		}
 		if err != nil {
 			log.Printf("connection: %v", err)
+			if c.errors != nil {
+				c.errors.add(fmt.Errorf("connection closed: %w", err))
+			}
 			c.Close()
 			return
 		}
 
+		if c.clients != nil {
+			c.clients.commandReceived(c.connID, req.logFormat())
+		}
+		start := time.Now()
 		resp, err := c.handleRequest(req)
-		if strings.HasPrefix(string(req.Key()), "set_") && errors.Is(err, tci.ErrTimeout) {
-			resp = protocol.Response{
-				Command: req.Key(),
-				Result:  "0",
-			}
-		} else if err != nil {
-			log.Printf("request failed: %v", err)
-			resp = protocol.Response{
-				Command: req.Key(),
-				Result:  "-1",
+		if c.metrics != nil && errors.Is(err, tci.ErrTimeout) {
+			c.metrics.tciTimeout()
+		}
+		if err != nil {
+			result := resultOf(req.Key(), err)
+			if result != resultOK {
+				log.Printf("request failed: %v", err)
+				if c.errors != nil {
+					c.errors.add(err)
+				}
 			}
+			resp = resultResponse(req.Key(), result)
+		}
+
+		if c.metrics != nil {
+			c.metrics.requestDone(metricCommand(req), resp.Result, time.Since(start))
 		}
 
 		var response string
@@ -159,116 +367,269 @@ func (c *inboundConnection) run() {
 		} else {
 			response = resp.Format()
 		}
-		if c.trace {
-			log.Printf("> %s", response)
+		c.writeResponse(response)
+
+		if c.tooManyWrongPasswords() {
+			err := fmt.Errorf("connection closed after %d wrong passwords", c.wrongPasswords)
+			log.Print(err)
+			if c.errors != nil {
+				c.errors.add(err)
+			}
+			c.Close()
+			return
 		}
-		fmt.Fprintln(c.conn, response)
 	}
 }
 
-func (c *inboundConnection) handleRequest(req protocol.Request) (protocol.Response, error) {
+// writeResponse writes the given response to the client.
+func (c *inboundConnection) writeResponse(response string) {
+	if c.settings.current().trace {
+		log.Printf("> %s", response)
+	}
+	c.record(directionTX, response)
+	fmt.Fprintln(c.conn, response)
+}
+
+func (c *inboundConnection) record(direction string, data string) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Record(RecordedEvent{
+		Stream:    streamHamlib,
+		Direction: direction,
+		Conn:      c.connID,
+		TRX:       c.trxData.trx,
+		Data:      data,
+	})
+}
+
+func (c *inboundConnection) handleRequest(req request) (protocol.Response, error) {
 	key := strings.ToLower(string(req.Key()))
-	if c.trace {
-		log.Printf("< %s (%s)", req.LongFormat(), key)
+	settings := c.settings.current()
+	if settings.trace {
+		log.Printf("< %s (%s) %s", req.logFormat(), key, req.vfo)
+	}
+	if key == passwordCommand.Long {
+		return c.authenticate(req, settings.password)
+	}
+	if settings.password != "" && !c.authenticated {
+		return protocol.NoResponse, fmt.Errorf("%s: %w", key, notAuthenticated())
+	}
+	if !settings.role.permits(key) {
+		return protocol.NoResponse, fmt.Errorf("%s: %w", key, notPermitted(settings.role))
+	}
+	vfo, err := c.resolveVFO(req.vfo)
+	if err != nil {
+		return protocol.NoResponse, fmt.Errorf("%s: %w", key, err)
 	}
 	switch key {
 	case "chk_vfo":
-		return protocol.ChkVFOResponse, nil
+		return chkVFOResponse(c.vfoMode), nil
+	case "set_vfo_opt":
+		if len(req.Args) < 1 {
+			return protocol.NoResponse, fmt.Errorf("set_vfo_opt: %w", errNoArguments)
+		}
+		vfoMode, err := parseHamlibBool(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_vfo_opt: %w", err)
+		}
+		c.vfoMode = vfoMode
+		return protocol.OKResponse(req.Key()), nil
 	case "dump_state":
-		return protocol.DumpStateResponse, nil
+		return dumpStateResponse(c.capabilities()), nil
 	case "dump_caps":
-		return dumpCapsResponse(c.version), nil
+		return dumpCapsResponse(c.capabilities()), nil
 	case "get_freq":
-		return protocol.GetFreqResponse(c.trxData.CurrentVFOFrequency()), nil
+		return protocol.GetFreqResponse(c.trxData.VFOFrequency(vfo)), nil
 	case "set_freq":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("set_freq: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_freq: %w", errNoArguments)
+		}
+		if c.trxData.Lock() {
+			return protocol.NoResponse, fmt.Errorf("set_freq: %w", rejected("VFO is locked"))
 		}
 		frequency, err := strconv.ParseFloat(req.Args[0], 64)
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_freq: invalid frequency: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_freq: %w", invalidArgument("invalid frequency: %w", err))
 		}
-		err = c.tciClient.SetVFOFrequency(c.trxData.trx, c.trxData.currentVFO, int(frequency))
+		err = c.checkTXChange(func(s *TRXState) { s.VFOs[vfo].Frequency = int(frequency) })
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_freq: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_freq: %w", err)
+		}
+		err = c.tciClient.SetVFOFrequency(c.trxData.trx, vfo, int(frequency))
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_freq: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "get_vfo":
-		vfo := tciToHamlibVFO[c.trxData.currentVFO]
-		return protocol.GetVFOResponse(string(vfo)), nil
+		return protocol.GetVFOResponse(string(tciToHamlibVFO[c.getCurrentVFO()])), nil
 	case "set_vfo":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("set_freq: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_vfo: %w", errNoArguments)
 		}
-		vfo, ok := hamlibToTCIVFO[hamlib.VFO(req.Args[0])]
-		if !ok {
-			return protocol.NoResponse, fmt.Errorf("set_vfo: unknown VFO %s", req.Args[0])
+		vfo, err := c.resolveVFO(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_vfo: %w", err)
 		}
-		c.trxData.currentVFO = vfo
+		c.currentVFO.Store(int32(vfo))
 		return protocol.OKResponse(req.Key()), nil
 	case "get_mode":
-		mode := tciToHamlibMode[c.trxData.Mode()]
-		min, max := c.trxData.RXFilterBand()
-		passband := max - min
-		return protocol.GetModeResponse(string(mode), passband), nil
+		state := c.trxData.Snapshot()
+		mode, err := c.hamlibMode(state)
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("get_mode: %w", err)
+		}
+		return protocol.GetModeResponse(string(mode), state.Passband()), nil
 	case "set_mode":
 		if len(req.Args) < 2 {
-			return protocol.NoResponse, fmt.Errorf("set_mode: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_mode: %w", errNoArguments)
 		}
 		if c.modeLocked {
 			return protocol.OKResponse(req.Key()), nil
 		}
-		mode := c.overrideDigimode(hamlibToTCIMode[hamlib.Mode(req.Args[0])])
-		// passband, err := strconv.Atoi(req.Args[1]) // TODO also take the passband into account
-		err := c.tciClient.SetMode(c.trxData.trx, mode)
+		passband, err := parsePassband(req.Args[1])
+		if err == nil {
+			err = c.setMode(req.Args[0], passband)
+		}
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_mode: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_mode: %w", err)
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "get_split_vfo":
 		return protocol.GetSplitVFOResponse(c.trxData.SplitEnable(), string(hamlib.VFOB)), nil
 	case "set_split_vfo":
 		if len(req.Args) < 2 {
-			return protocol.NoResponse, fmt.Errorf("set_split_vfo: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_split_vfo: %w", errNoArguments)
+		}
+		enabled, err := parseHamlibBool(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_split_vfo: %w", err)
+		}
+		// in split, TCI always transmits on VFO B
+		txVFO, err := c.resolveVFO(req.Args[1])
+		if err == nil && enabled && txVFO != tci.VFOB {
+			err = newRequestError(resultInvalidVFO, "TX VFO %s not supported, TCI transmits on VFO B in split", req.Args[1])
+		}
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_split_vfo: %w", err)
+		}
+		err = c.checkTXChange(func(s *TRXState) { s.SplitEnabled = enabled })
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_split_vfo: %w", err)
 		}
-		enabled := (req.Args[0] != "0")
-		// TODO handle setting the TXVFO as this is usually VFOB in TCI
-		err := c.tciClient.SetSplitEnable(c.trxData.trx, enabled)
+		err = c.tciClient.SetSplitEnable(c.trxData.trx, enabled)
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_split_vfo: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_split_vfo: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "get_split_freq":
 		return protocol.GetSplitFreqResponse(c.trxData.VFOFrequency(tci.VFOB)), nil
 	case "set_split_freq":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("set_split_freq: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_split_freq: %w", errNoArguments)
+		}
+		if c.trxData.Lock() {
+			return protocol.NoResponse, fmt.Errorf("set_split_freq: %w", rejected("VFO is locked"))
 		}
 		frequency, err := strconv.ParseFloat(req.Args[0], 64)
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_split_freq: invalid frequency: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_split_freq: %w", invalidArgument("invalid frequency: %w", err))
+		}
+		err = c.checkTXChange(func(s *TRXState) { s.VFOs[tci.VFOB].Frequency = int(frequency) })
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_split_freq: %w", err)
 		}
 		err = c.tciClient.SetVFOFrequency(c.trxData.trx, tci.VFOB, int(frequency))
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_split_freq: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_split_freq: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "get_split_mode":
-		mode := string(tciToHamlibMode[c.trxData.Mode()])
-		min, max := c.trxData.RXFilterBand()
-		passband := max - min
-		return protocol.GetSplitModeResponse(mode, passband), nil
+		state := c.trxData.Snapshot()
+		mode, err := c.hamlibMode(state)
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("get_split_mode: %w", err)
+		}
+		return protocol.GetSplitModeResponse(string(mode), state.Passband()), nil
 	case "set_split_mode":
+		if len(req.Args) < 2 {
+			return protocol.NoResponse, fmt.Errorf("set_split_mode: %w", errNoArguments)
+		}
+		if c.modeLocked {
+			return protocol.OKResponse(req.Key()), nil
+		}
+		// TCI does not distinguish between the RX and the TX mode, both are the mode of the TRX. TCI has no TX
+		// filter, the TX passband is ignored and the width of the RX filter is kept.
+		_, err := parsePassband(req.Args[1])
+		if err == nil {
+			err = c.setMode(req.Args[0], passbandNoChange)
+		}
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_split_mode: %w", err)
+		}
+		return protocol.OKResponse(req.Key()), nil
+	case "get_rit":
+		return getRITResponse(c.trxData.RIT()), nil
+	case "set_rit":
+		if len(req.Args) < 1 {
+			return protocol.NoResponse, fmt.Errorf("set_rit: %w", errNoArguments)
+		}
+		offset, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_rit: %w", invalidArgument("invalid offset: %w", err))
+		}
+		if offset != 0 {
+			err = c.tciClient.SetRITOffset(c.trxData.trx, offset)
+			if err != nil {
+				return protocol.NoResponse, fmt.Errorf("set_rit: %w", tciCommandFailed(err))
+			}
+		}
+		err = c.tciClient.SetRITEnable(c.trxData.trx, offset != 0)
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_rit: %w", tciCommandFailed(err))
+		}
+		return protocol.OKResponse(req.Key()), nil
+	case "get_xit":
+		return getXITResponse(c.trxData.XIT()), nil
+	case "set_xit":
+		if len(req.Args) < 1 {
+			return protocol.NoResponse, fmt.Errorf("set_xit: %w", errNoArguments)
+		}
+		offset, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_xit: %w", invalidArgument("invalid offset: %w", err))
+		}
+		err = c.checkTXChange(func(s *TRXState) {
+			s.XITEnabled = offset != 0
+			if offset != 0 {
+				s.XITOffset = offset
+			}
+		})
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_xit: %w", err)
+		}
+		if offset != 0 {
+			err = c.tciClient.SetXITOffset(c.trxData.trx, offset)
+			if err != nil {
+				return protocol.NoResponse, fmt.Errorf("set_xit: %w", tciCommandFailed(err))
+			}
+		}
+		err = c.tciClient.SetXITEnable(c.trxData.trx, offset != 0)
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_xit: %w", tciCommandFailed(err))
+		}
 		return protocol.OKResponse(req.Key()), nil
 	case "get_ptt":
 		return protocol.GetPTTResponse(c.trxData.TX()), nil
 	case "set_ptt":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("set_split_vfo: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_ptt: %w", errNoArguments)
 		}
 		var enabled bool
 		var source tci.SignalSource
 		switch req.Args[0] {
+		case "0":
+			enabled = false
 		case "1":
 			enabled = true
 			source = tci.SignalSourceDefault
@@ -278,75 +639,109 @@ func (c *inboundConnection) handleRequest(req protocol.Request) (protocol.Respon
 		case "3":
 			enabled = true
 			source = tci.SignalSourceVAC
+		default:
+			return protocol.NoResponse, fmt.Errorf("set_ptt: %w", invalidArgument("invalid PTT %s", req.Args[0]))
+		}
+		if enabled {
+			err := c.checkTX()
+			if err != nil {
+				return protocol.NoResponse, fmt.Errorf("set_ptt: %w", err)
+			}
 		}
 		err := c.tciClient.SetTX(c.trxData.trx, enabled, source)
+		c.watchdog.txSent(c.trxData.trx, c.txOwner(), enabled, err)
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_ptt: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_ptt: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "send_morse":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("send_morse: no arguments")
+			return protocol.NoResponse, fmt.Errorf("send_morse: %w", errNoArguments)
 		}
-		err := c.tciClient.SendCWMacro(c.trxData.trx, req.Args[0])
+		err := c.checkTX()
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("send_morse: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("send_morse: %w", err)
+		}
+		err = c.tciClient.SendCWMacro(c.trxData.trx, req.Args[0])
+		c.watchdog.cwSent(c.trxData.trx, c.txOwner(), err)
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("send_morse: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "stop_morse":
 		err := c.tciClient.StopCW()
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("stop_morse: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("stop_morse: %w", tciCommandFailed(err))
 		}
 		return protocol.OKResponse(req.Key()), nil
 	case "wait_morse":
 		c.trxData.WaitForTransmissionEnd()
 		return protocol.OKResponse(req.Key()), nil
-	case "set_level_keyspd":
-		if len(req.Args) < 2 {
-			return protocol.NoResponse, fmt.Errorf("set_level: no arguments")
-		}
-		wpm, err := strconv.Atoi(req.Args[1])
-		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_level: invalid keyer speed in WPM: %w", err)
+	case "set_lock_mode":
+		if len(req.Args) < 1 {
+			return protocol.NoResponse, fmt.Errorf("set_lock_mode: %w", errNoArguments)
 		}
-		err = c.tciClient.SetCWMacrosSpeed(wpm)
+		modeLocked, err := parseHamlibBool(req.Args[0])
 		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("set_level: cannot send TCI command: %w", err)
+			return protocol.NoResponse, fmt.Errorf("set_lock_mode: %w", err)
 		}
+		c.modeLocked = modeLocked
 		return protocol.OKResponse(req.Key()), nil
-	case "get_level_keyspd":
-		wpm, err := c.tciClient.CWMacrosSpeed()
-		if err != nil {
-			return protocol.NoResponse, fmt.Errorf("get_level: cannot send TCI command: %w", err)
-		}
-		return protocol.GetLevelKeyspdResponse(wpm), nil
-	case "set_lock_mode":
+	case "get_lock_mode":
+		return getLockModeResponse(c.modeLocked), nil
+	case "set_trn":
 		if len(req.Args) < 1 {
-			return protocol.NoResponse, fmt.Errorf("set_lock_mode: no arguments")
+			return protocol.NoResponse, fmt.Errorf("set_trn: %w", errNoArguments)
+		}
+		err := c.setTransceive(req.Args[0])
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_trn: %w", err)
 		}
-		c.modeLocked = (req.Args[0] == "1")
 		return protocol.OKResponse(req.Key()), nil
-	case "get_lock_mode":
-		return protocol.GetPTTResponse(c.modeLocked), nil
+	case "get_trn":
+		return getTRNResponse(c.getTransceive()), nil
 	default:
+		switch {
+		case strings.HasPrefix(key, "get_level_"):
+			return c.getLevel(req, vfo)
+		case strings.HasPrefix(key, "set_level_"):
+			return c.setLevel(req, vfo)
+		case strings.HasPrefix(key, "get_func_"):
+			return c.getFunc(req)
+		case strings.HasPrefix(key, "set_func_"):
+			return c.setFunc(req)
+		}
 		log.Printf("unsupported request: %v", req.LongFormat())
 		return notImplementedResponse(req.Key()), nil
 	}
 }
 
-func (c *inboundConnection) overrideDigimode(mode tci.Mode) tci.Mode {
-	if !c.noDigimodes {
-		return mode
+// resolveVFO maps the given Hamlib VFO onto the corresponding TCI VFO. An empty VFO refers to the current VFO of this connection.
+func (c *inboundConnection) resolveVFO(vfo string) (tci.VFO, error) {
+	switch hamlib.VFO(vfo) {
+	case "", hamlib.CurrVFO, hamlib.VFOVFO, hamlib.RXVFO:
+		return c.getCurrentVFO(), nil
+	case hamlib.TXVFO:
+		if c.trxData.SplitEnable() {
+			return tci.VFOB, nil
+		}
+		return c.getCurrentVFO(), nil
 	}
-	switch mode {
-	case tci.ModeDIGL:
-		return tci.ModeLSB
-	case tci.ModeDIGU:
-		return tci.ModeUSB
-	default:
-		return mode
+	result, ok := hamlibToTCIVFO[hamlib.VFO(vfo)]
+	if !ok {
+		return 0, invalidVFO(vfo)
 	}
+	return result, nil
+}
+
+// txOwner returns the name of this connection as owner of the transmissions that it starts.
+func (c *inboundConnection) txOwner() string {
+	return fmt.Sprintf("Hamlib connection %d", c.connID)
+}
+
+// getCurrentVFO returns the current VFO of this connection.
+func (c *inboundConnection) getCurrentVFO() tci.VFO {
+	return tci.VFO(c.currentVFO.Load())
 }
 
 func (c *inboundConnection) Close() {
@@ -368,290 +763,3 @@ var tciToHamlibVFO = map[tci.VFO]hamlib.VFO{
 	tci.VFOA: hamlib.VFOA,
 	tci.VFOB: hamlib.VFOB,
 }
-
-var hamlibToTCIMode = map[hamlib.Mode]tci.Mode{
-	hamlib.ModeUSB:     tci.ModeUSB,
-	hamlib.ModeLSB:     tci.ModeLSB,
-	hamlib.ModeCW:      tci.ModeCW,
-	hamlib.ModeCWR:     tci.ModeCW,
-	hamlib.ModeRTTY:    tci.ModeDIGU,
-	hamlib.ModeRTTYR:   tci.ModeDIGL,
-	hamlib.ModeAM:      tci.ModeAM,
-	hamlib.ModeFM:      tci.ModeNFM,
-	hamlib.ModeWFM:     tci.ModeWFM,
-	hamlib.ModePKTLSB:  tci.ModeDIGL,
-	hamlib.ModePKTUSB:  tci.ModeDIGU,
-	hamlib.ModePKTFM:   tci.ModeNFM,
-	hamlib.ModeECSSLSB: tci.ModeDIGL,
-	hamlib.ModeECSSUSB: tci.ModeDIGU,
-	hamlib.ModeFAX:     tci.ModeDIGU,
-	hamlib.ModeSAM:     tci.ModeSAM,
-	hamlib.ModeDSB:     tci.ModeDSB,
-}
-
-var tciToHamlibMode = map[tci.Mode]hamlib.Mode{
-	tci.ModeAM:   hamlib.ModeAM,
-	tci.ModeSAM:  hamlib.ModeSAM,
-	tci.ModeDSB:  hamlib.ModeDSB,
-	tci.ModeLSB:  hamlib.ModeLSB,
-	tci.ModeUSB:  hamlib.ModeUSB,
-	tci.ModeCW:   hamlib.ModeCW,
-	tci.ModeNFM:  hamlib.ModeFM,
-	tci.ModeWFM:  hamlib.ModeWFM,
-	tci.ModeDIGL: hamlib.ModePKTLSB,
-	tci.ModeDIGU: hamlib.ModePKTUSB,
-}
-
-func notImplementedResponse(cmd protocol.CommandKey) protocol.Response {
-	return protocol.Response{Command: cmd, Result: "-4"}
-}
-
-func dumpCapsResponse(version string) protocol.Response {
-	return protocol.Response{
-		Command: "dump_caps",
-		Data: []string{`Caps dump for model: 1
-Model name:	tciadapter
-Mfg name:	dl3ney
-Backend version:	` + version + `
-Backend copyright:	MIT
-Backend status:	Stable
-Rig type:	Other
-PTT type:	None
-DCD type:	Rig capable
-Port type:	None
-Write delay: 0mS, timeout 0mS, 0 retry
-Post Write delay: 0mS
-Has targetable VFO: Y
-Has async data support: N
-Announce: 0x0
-Max RIT: -9.990kHz/+9.990kHz
-Max XIT: -9.990kHz/+9.990kHz
-Max IF-SHIFT: -10.0kHz/+10.0kHz
-Preamp: 10dB
-Attenuator: 10dB 20dB 30dB
-AGC levels: 0=OFF 1=SUPERFAST 2=FAST 5=MEDIUM 3=SLOW 6=AUTO 4=USER
-CTCSS: 67.0 69.3 71.9 74.4 77.0 79.7 82.5 85.4 88.5 91.5 94.8 97.4 100.0 103.5 107.2 110.9 114.8 118.8 123.0 127.3 131.8 136.5 141.3 146.2 151.4 156.7 159.8 162.2 165.5 167.9 171.3 173.8 177.3 179.9 183.5 186.2 189.9 192.8 196.6 199.5 203.5 206.5 210.7 218.1 225.7 229.1 233.6 241.8 250.3 254.1 Hz, 50 tones
-DCS: 17 23 25 26 31 32 36 43 47 50 51 53 54 65 71 72 73 74 114 115 116 122 125 131 132 134 143 145 152 155 156 162 165 172 174 205 212 223 225 226 243 244 245 246 251 252 255 261 263 265 266 271 274 306 311 315 325 331 332 343 346 351 356 364 365 371 411 412 413 423 431 432 445 446 452 454 455 462 464 465 466 503 506 516 523 526 532 546 565 606 612 624 627 631 632 654 662 664 703 712 723 731 732 734 743 754, 106 codes
-Get functions: FAGC NB COMP VOX TONE TSQL SBKIN FBKIN ANF NR AIP APF MON MN RF ARO LOCK MUTE VSC REV SQL ABM BC MBC RIT AFC SATMODE SCOPE RESUME TBURST TUNER XIT NB2 CSQL AFLT ANL BC2 DUAL_WATCH DIVERSITY DSQL SCEN TRANSCEIVE SPECTRUM SPECTRUM_HOLD SEND_MORSE SEND_VOICE_MEM 
-Set functions: FAGC NB COMP VOX TONE TSQL SBKIN FBKIN ANF NR AIP APF MON MN RF ARO LOCK MUTE VSC REV SQL ABM BC MBC RIT AFC SATMODE SCOPE RESUME TBURST TUNER XIT NB2 CSQL AFLT ANL BC2 DUAL_WATCH DIVERSITY DSQL SCEN TRANSCEIVE SPECTRUM SPECTRUM_HOLD SEND_MORSE SEND_VOICE_MEM 
-Extra functions:
-	MGEF
-		Type: CHECKBUTTON
-		Default: 
-		Label: Magic ext func
-		Tooltip: Magic ext function, as an example
-Get level: PREAMP(0..0/0) ATT(0..0/0) VOXDELAY(0..0/0) AF(0..0/0) RF(0..0/0) SQL(0..0/0) IF(0..0/0) APF(0..0/0) NR(0..0/0) PBT_IN(0..0/0) PBT_OUT(0..0/0) CWPITCH(0..0/10) RFPOWER(0..0/0) MICGAIN(0..0/0) KEYSPD(0..0/0) NOTCHF(0..0/0) COMP(0..0/0) AGC(0..0/0) BKINDL(0..0/0) BAL(0..0/0) METER(0..0/0) VOXGAIN(0..0/0) ANTIVOX(0..0/0) SLOPE_LOW(0..0/0) SLOPE_HIGH(0..0/0) BKIN_DLYMS(0..0/0) RAWSTR(0..0/0) SWR(0..0/0) ALC(0..0/0) STRENGTH(0..0/0) RFPOWER_METER(0..0/0) COMP_METER(0..0/0) VD_METER(0..0/0) ID_METER(0..0/0) NOTCHF_RAW(0..0/0) MONITOR_GAIN(0..0/0) NB(0..0/0) RFPOWER_METER_WATTS(0..0/0) SPECTRUM_MODE(0..0/0) SPECTRUM_SPAN(0..0/0) SPECTRUM_EDGE_LOW(0..0/0) SPECTRUM_EDGE_HIGH(0..0/0) SPECTRUM_SPEED(0..2/1) SPECTRUM_REF(-30..10/0.5) SPECTRUM_AVG(0..3/1) SPECTRUM_ATT(0..0/0) TEMP_METER(0..0/0) BAND_SELECT(0..0/0) 
-Set level: PREAMP(0..0/0) ATT(0..0/0) VOXDELAY(0..0/0) AF(0..0/0) RF(0..0/0) SQL(0..0/0) IF(0..0/0) APF(0..0/0) NR(0..0/0) PBT_IN(0..0/0) PBT_OUT(0..0/0) CWPITCH(0..0/10) RFPOWER(0..0/0) MICGAIN(0..0/0) KEYSPD(0..0/0) NOTCHF(0..0/0) COMP(0..0/0) AGC(0..0/0) BKINDL(0..0/0) BAL(0..0/0) METER(0..0/0) VOXGAIN(0..0/0) ANTIVOX(0..0/0) SLOPE_LOW(0..0/0) SLOPE_HIGH(0..0/0) BKIN_DLYMS(0..0/0) NOTCHF_RAW(0..0/0) MONITOR_GAIN(0..0/0) NB(0..0/0) RFPOWER_METER_WATTS(0..0/0) SPECTRUM_MODE(0..0/0) SPECTRUM_SPAN(0..0/0) SPECTRUM_EDGE_LOW(0..0/0) SPECTRUM_EDGE_HIGH(0..0/0) SPECTRUM_SPEED(0..2/1) SPECTRUM_REF(-30..10/0.5) SPECTRUM_AVG(0..3/1) SPECTRUM_ATT(0..0/0) TEMP_METER(0..0/0) BAND_SELECT(0..0/0) 
-Extra levels:
-	MGL
-		Type: NUMERIC
-		Default: 
-		Label: Magic level
-		Tooltip: Magic level, as an example
-		Range: 0..1/0.001
-	MGF
-		Type: CHECKBUTTON
-		Default: 
-		Label: Magic func
-		Tooltip: Magic function, as an example
-	MGO
-		Type: BUTTON
-		Default: 
-		Label: Magic Op
-		Tooltip: Magic Op, as an example
-	MGC
-		Type: COMBO
-		Default: VALUE1
-		Label: Magic combo
-		Tooltip: Magic combo, as an example
-		Values: 0="VALUE1" 1="VALUE2" 2="NONE"
-Get parameters: ANN(0..0/0) APO(0..0/0) BACKLIGHT(0..0/0) BEEP(0..0/0) TIME(0..0/0) BAT(0..0/0) KEYLIGHT(0..0/0) SCREENSAVER(0..0/0) 
-Set parameters: ANN(0..0/0) APO(0..0/0) BACKLIGHT(0..0/0) BEEP(0..0/0) TIME(0..0/0) KEYLIGHT(0..0/0) SCREENSAVER(0..0/0) 
-Extra parameters:
-	MGP
-		Type: NUMERIC
-		Default: 
-		Label: Magic parm
-		Tooltip: Magic parameter, as an example
-		Range: 0..1/0.001
-Mode list: AM CW USB LSB RTTY FM WFM CWR RTTYR 
-VFO list: VFOA VFOB VFOC SubA SubB MainA MainB Sub Main MEM currVFO 
-VFO Ops: CPY XCHG FROM_VFO TO_VFO MCL UP DOWN BAND_UP BAND_DOWN LEFT RIGHT TUNE TOGGLE 
-Scan Ops: MEM SLCT PRIO PROG DELTA VFO PLT STOP 
-Number of banks:	0
-Memory name desc size:	0
-Memories:
-	0..18:   	MEM
-		Mem caps: BANK ANT FREQ MODE WIDTH TXFREQ TXMODE TXWIDTH SPLIT RPTRSHIFT RPTROFS TS RIT XIT FUNC LEVEL TONE CTCSS DCSCODE DCSSQL SCANGRP FLAG NAME EXTLVL 
-	19..19:   	CALL
-		Mem caps: 
-	20..21:   	EDGE
-		Mem caps: 
-TX ranges #1 for Dummy#1:
-	150000 Hz - 1500000000 Hz
-		VFO list: VFOA VFOB VFOC SubA SubB MainA MainB Sub Main MEM currVFO 
-		Mode list: AM CW USB LSB RTTY FM WFM CWR RTTYR 
-		Antenna list: ANT1 ANT2 ANT3 ANT4 
-		Low power: 5 W, High power: 100 W
-RX ranges #1 for Dummy#1:
-	150000 Hz - 1500000000 Hz
-		VFO list: VFOA VFOB VFOC SubA SubB MainA MainB Sub Main MEM currVFO 
-		Mode list: AM CW USB LSB RTTY FM WFM CWR RTTYR 
-		Antenna list: ANT1 ANT2 ANT3 ANT4 
-TX ranges #2 for Dummy#2:
-RX ranges #2 for Dummy#2:
-	150000 Hz - 1500000000 Hz
-		VFO list: VFOA VFOB VFOC SubA SubB MainA MainB Sub Main MEM currVFO 
-		Mode list: AM CW USB LSB RTTY FM WFM CWR RTTYR 
-		Antenna list: ANT1 ANT2 ANT3 ANT4 
-TX ranges #3 for TBD:
-RX ranges #3 for TBD:
-TX ranges #4 for TBD:
-RX ranges #4 for TBD:
-TX ranges #5 for TBD:
-RX ranges #5 for TBD:
-TX ranges #1 status for Dummy#1:	OK (0)
-RX ranges #1 status for Dummy#1:	OK (0)
-TX ranges #2 status for Dummy#2:	OK (0)
-RX ranges #2 status for Dummy#2:	OK (0)
-TX ranges #3 status for TBD:	OK (0)
-RX ranges #3 status for TBD:	OK (0)
-TX ranges #4 status for TBD:	OK (0)
-RX ranges #4 status for TBD:	OK (0)
-TX ranges #5 status for TBD:	OK (0)
-RX ranges #5 status for TBD:	OK (0)
-Tuning steps:
-	1.0 Hz:   	AM CW USB LSB RTTY FM WFM CWR RTTYR 
-	ANY:   	AM CW USB LSB RTTY FM WFM CWR RTTYR 
-Tuning steps status:	OK (0)
-Filters:
-	2.4000 kHz:   	USB LSB 
-	1.8000 kHz:   	USB LSB 
-	3.0000 kHz:   	USB LSB 
-	ANY:   	USB LSB 
-	500.0 Hz:   	CW 
-	2.4000 kHz:   	CW 
-	50.0 Hz:   	CW 
-	ANY:   	CW 
-	300.0 Hz:   	RTTY 
-	2.4000 kHz:   	RTTY 
-	50.0 Hz:   	RTTY 
-	ANY:   	RTTY 
-	8.0000 kHz:   	AM 
-	2.4000 kHz:   	AM 
-	10.0000 kHz:   	AM 
-	15.0000 kHz:   	FM 
-	8.0000 kHz:   	FM 
-	230.0000 kHz:   	WFM 
-Bandwidths:
-	AM	Normal: 8.0000 kHz,	Narrow: 2.4000 kHz,	Wide: 10.0000 kHz
-	CW	Normal: 500.0 Hz,	Narrow: 50.0 Hz,	Wide: 2.4000 kHz
-	USB	Normal: 2.4000 kHz,	Narrow: 1.8000 kHz,	Wide: 3.0000 kHz
-	LSB	Normal: 2.4000 kHz,	Narrow: 1.8000 kHz,	Wide: 3.0000 kHz
-	RTTY	Normal: 300.0 Hz,	Narrow: 50.0 Hz,	Wide: 2.4000 kHz
-	FM	Normal: 15.0000 kHz,	Narrow: 8.0000 kHz,	Wide: 0.0 Hz
-	WFM	Normal: 230.0000 kHz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	CWR	Normal: 500.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	RTTYR	Normal: 300.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	AMS	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	PKTLSB	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	PKTUSB	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	FM-D	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	ECSSUSB	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	ECSSLSB	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	FAX	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	SAM	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	SAL	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	SAH	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	DSB	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-		Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	FMN	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	AM-D	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	P25	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	D-STAR	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	DPMR	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	NXDN-VN	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	NXDN-N	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	DCR	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	AMN	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-	PSK	Normal: 0.0 Hz,	Narrow: 0.0 Hz,	Wide: 0.0 Hz
-Spectrum scopes: 0="Main" 1="Sub"
-Spectrum modes: 1=CENTER 2=FIXED 3=CENTER_SCROLL 4=FIXED_SCROLL 
-Spectrum spans: 5000 10000 20000 50000 100000 200000 500000 1000000 2000000 5000000 
-Spectrum averaging modes: 0="OFF" 1="2" 2="3" 3="4" 
-Spectrum attenuator: 10dB 20dB 30dB
-Has priv data:	N
-Has Init:	N
-Has Cleanup:	N
-Has Open:	Y
-Has Close:	Y
-Can set Conf:	N
-Can get Conf:	N
-Can set Frequency:	Y
-Can get Frequency:	Y
-Can set Mode:	Y
-Can get Mode:	Y
-Can set VFO:	Y
-Can get VFO:	Y
-Can set PTT:	Y
-Can get PTT:	Y
-Can get DCD:	N
-Can set Repeater Duplex:	N
-Can get Repeater Duplex:	N
-Can set Repeater Offset:	N
-Can get Repeater Offset:	N
-Can set Split Freq:	Y
-Can get Split Freq:	Y
-Can set Split Mode:	Y
-Can get Split Mode:	Y
-Can set Split VFO:	Y
-Can get Split VFO:	Y
-Can set Tuning Step:	N
-Can get Tuning Step:	N
-Can set RIT:	N
-Can get RIT:	N
-Can set XIT:	N
-Can get XIT:	N
-Can set CTCSS:	N
-Can get CTCSS:	N
-Can set DCS:	N
-Can get DCS:	N
-Can set CTCSS Squelch:	N
-Can get CTCSS Squelch:	N
-Can set DCS Squelch:	N
-Can get DCS Squelch:	N
-Can set Power Stat:	N
-Can get Power Stat:	N
-Can Reset:	N
-Can get Ant:	N
-Can set Ant:	N
-Can set Transceive:	Y
-Can get Transceive:	Y
-Can set Func:	N
-Can get Func:	N
-Can set Level:	N
-Can get Level:	N
-Can set Param:	N
-Can get Param:	N
-Can send DTMF:	N
-Can recv DTMF:	N
-Can send Morse:	Y
-Can send Voice:	N
-Can decode Events:	N
-Can set Bank:	N
-Can set Mem:	N
-Can get Mem:	N
-Can set Channel:	N
-Can get Channel:	N
-Can ctl Mem/VFO:	N
-Can Scan:	N
-Can get Info:	N
-Can get power2mW:	N
-Can get mW2power:	N
-
-Overall backend warnings: 0
-`},
-		Keys:   []string{""},
-		Result: "0",
-	}
-}
@@ -0,0 +1,196 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentVFOIsSelectedPerConnection(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+
+	client1 := openTestConnection(t, trxData, false)
+	client2 := openTestConnection(t, trxData, false)
+
+	assert.Equal(t, "RPRT 0", client1.request(t, `V VFOB`))
+	assert.Equal(t, "VFOB", client1.request(t, `v`))
+	assert.Equal(t, "14074000", client1.request(t, `f`))
+
+	assert.Equal(t, "VFOA", client2.request(t, `v`))
+	assert.Equal(t, "7074000", client2.request(t, `f`))
+
+	assert.Equal(t, "RPRT 0", client2.request(t, `\set_vfo currVFO`))
+	assert.Equal(t, "VFOA", client2.request(t, `v`))
+	assert.Equal(t, "14074000", client1.request(t, `f`))
+}
+
+func TestVFOMode(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+	trxData.SetRXSMeter(0, tci.VFOB, -63)
+
+	vfoClient := openTestConnection(t, trxData, true)
+	plainClient := openTestConnection(t, trxData, false)
+
+	assert.Equal(t, "1", vfoClient.request(t, `\chk_vfo`))
+	assert.Equal(t, "14074000", vfoClient.request(t, `f VFOB`))
+	assert.Equal(t, "7074000", vfoClient.request(t, `f currVFO`))
+	assert.Equal(t, "10", vfoClient.request(t, `l VFOB STRENGTH`))
+	assert.Equal(t, "VFOA", vfoClient.request(t, `v`))
+
+	assert.Equal(t, "0", plainClient.request(t, `\chk_vfo`))
+	assert.Equal(t, "7074000", plainClient.request(t, `f`))
+	assert.Equal(t, "RPRT 0", plainClient.request(t, `\set_vfo_opt 1`))
+	assert.Equal(t, "1", plainClient.request(t, `\chk_vfo`))
+	assert.Equal(t, "14074000", plainClient.request(t, `f Sub`))
+}
+
+type testClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func openTestConnection(t *testing.T, trxData *TRXData, vfoMode bool) *testClient {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	adapterClosed := make(chan struct{})
+	conn := &inboundConnection{
+		conn:          serverSide,
+		trxData:       trxData,
+		adapterClosed: adapterClosed,
+		closed:        make(chan struct{}),
+		vfoMode:       vfoMode,
+	}
+	go conn.run()
+	t.Cleanup(func() {
+		close(adapterClosed)
+		clientSide.Close()
+	})
+
+	return &testClient{
+		conn:   clientSide,
+		reader: bufio.NewReader(clientSide),
+	}
+}
+
+func (c *testClient) request(t *testing.T, request string) string {
+	t.Helper()
+	response, err := c.tryRequest(request)
+	require.NoError(t, err)
+	return response
+}
+
+// tryRequest sends the given request and returns the first line of the response. Unlike request, it does not stop
+// the test on errors, so it can be used in other goroutines than the test's goroutine.
+func (c *testClient) tryRequest(request string) (string, error) {
+	_, err := fmt.Fprintln(c.conn, request)
+	if err != nil {
+		return "", err
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return line[:len(line)-1], nil
+}
+
+func TestTransceiveDoesNotPushChanges(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+
+	client := openTestConnection(t, trxData, false)
+	assert.Equal(t, "OFF", client.request(t, `\get_trn`))
+	assert.Equal(t, "RPRT 0", client.request(t, `\set_trn RIG`))
+	assert.Equal(t, "RIG", client.request(t, `\get_trn`))
+
+	// the changes between a request and its response do not add lines to the response
+	trxData.SetVFOFrequency(0, tci.VFOA, 14074000)
+	trxData.SetTX(0, true)
+	assert.Equal(t, "1", client.request(t, `t`))
+	trxData.SetSplitEnable(0, true)
+	assert.Equal(t, "14074000", client.request(t, `f`))
+
+	assert.Equal(t, "RPRT 0", client.request(t, `\set_trn OFF`))
+	assert.Equal(t, "OFF", client.request(t, `\get_trn`))
+}
+
+func (c *testClient) readLines(t *testing.T, count int) []string {
+	t.Helper()
+	result := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		c.conn.SetReadDeadline(time.Now().Add(time.Second))
+		line, err := c.reader.ReadString('\n')
+		require.NoError(t, err)
+		result = append(result, line[:len(line)-1])
+	}
+	c.conn.SetReadDeadline(time.Time{})
+	return result
+}
+
+func TestRITAndXIT(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetRITOffset(0, 120)
+	trxData.SetRITEnable(0, true)
+	trxData.SetXITOffset(0, -300)
+
+	client := openTestConnection(t, trxData, false)
+
+	tt := []struct {
+		request  string
+		expected []string
+	}{
+		{`j`, []string{"120"}},
+		{`\get_rit`, []string{"120"}},
+		{`+\get_rit`, []string{"get_rit:", "RIT: 120", "RPRT 0"}},
+		{`z`, []string{"0"}},
+		{`+\get_xit`, []string{"get_xit:", "XIT: 0", "RPRT 0"}},
+		{`;\get_rit`, []string{"get_rit:;RIT: 120;RPRT 0"}},
+	}
+	for _, tc := range tt {
+		_, err := fmt.Fprintln(client.conn, tc.request)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, client.readLines(t, len(tc.expected)), tc.request)
+	}
+}
+
+func TestSetRITAndXIT(t *testing.T) {
+	setup := startE2E(t, 0)
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// the requests are sent in this order on the same connection
+	tt := []struct {
+		request        string
+		expectedEnable string
+		expectedOffset string
+	}{
+		{`J 150`, "true", "150"},
+		{`J -50`, "true", "-50"},
+		{`J 0`, "false", "-50"},
+		{`Z 200`, "true", "200"},
+		{`Z 0`, "false", "200"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, "RPRT 0", client.request(t, tc.request), tc.request)
+		command := "rit"
+		if tc.request[0] == 'Z' {
+			command = "xit"
+		}
+		assert.Eventually(t, func() bool {
+			enable := setup.sdr.Get(command+"_enable", 0)
+			offset := setup.sdr.Get(command+"_offset", 0)
+			return len(enable) == 1 && enable[0] == tc.expectedEnable && len(offset) == 1 && offset[0] == tc.expectedOffset
+		}, e2eTimeout, e2eTick, tc.request)
+	}
+}
@@ -0,0 +1,67 @@
+package adapter
+
+import (
+	"strings"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// The transceive modes of Hamlib's set_trn command.
+const (
+	transceiveOff  = "OFF"
+	transceiveRig  = "RIG"
+	transceivePoll = "POLL"
+)
+
+var transceiveModes = map[string]string{
+	"0":            transceiveOff,
+	"1":            transceiveRig,
+	"2":            transceivePoll,
+	transceiveOff:  transceiveOff,
+	transceiveRig:  transceiveRig,
+	transceivePoll: transceivePoll,
+}
+
+// asyncState contains the parts of the TRX state that are published to the clients when they change.
+type asyncState struct {
+	frequencies [vfoCount]int
+	mode        tci.Mode
+	reversed    bool
+	passband    int
+	ptt         bool
+	split       bool
+}
+
+func newAsyncState(state TRXState) asyncState {
+	result := asyncState{
+		mode:     state.Mode,
+		reversed: reversedFilter(state.Mode, state.RXFilterMin, state.RXFilterMax),
+		passband: state.Passband(),
+		ptt:      state.Transmitting,
+		split:    state.SplitEnabled,
+	}
+	for i, vfo := range state.VFOs {
+		result.frequencies[i] = vfo.Frequency
+	}
+	return result
+}
+
+// setTransceive sets the transceive mode of this connection. Like rigctld, the adapter does not push the changes of
+// the TRX state through the Hamlib connection: the clients take every line as the response to their last request, an
+// unsolicited line between a request and its response would be taken as the wrong response. In the RIG mode, the
+// clients receive the changes through the multicast publisher instead.
+func (c *inboundConnection) setTransceive(arg string) error {
+	mode, ok := transceiveModes[strings.ToUpper(arg)]
+	if !ok {
+		return invalidArgument("invalid transceive mode %s", arg)
+	}
+	c.transceive = mode
+	return nil
+}
+
+func (c *inboundConnection) getTransceive() string {
+	if c.transceive == "" {
+		return transceiveOff
+	}
+	return c.transceive
+}
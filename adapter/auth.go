@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+)
+
+// passwordCommand is rigctld's command to authenticate a client. It is not part of the protocol package.
+var passwordCommand = protocol.Command{
+	Short: 0x98,
+	Long:  "password",
+	Args:  1,
+}
+
+// redactedPassword replaces the password in logs and recordings.
+const redactedPassword = "***"
+
+// maxPasswordAttempts is the number of wrong passwords after which the adapter closes the connection.
+const maxPasswordAttempts = 3
+
+// notAuthenticated returns an error for a request of a client that did not send the password yet.
+func notAuthenticated() error {
+	return newRequestError(resultSecurity, "password not provided")
+}
+
+// authenticate checks the password of the given request. Without password, every client is authenticated. Wrong
+// passwords are counted, see tooManyWrongPasswords.
+func (c *inboundConnection) authenticate(req request, password string) (protocol.Response, error) {
+	if len(req.Args) < 1 {
+		return protocol.NoResponse, fmt.Errorf("password: %w", errNoArguments)
+	}
+	if password != "" && subtle.ConstantTimeCompare([]byte(req.Args[0]), []byte(password)) != 1 {
+		c.wrongPasswords++
+		return protocol.NoResponse, fmt.Errorf("password: %w", newRequestError(resultSecurity, "wrong password"))
+	}
+	c.authenticated = true
+	return protocol.OKResponse(req.Key()), nil
+}
+
+// tooManyWrongPasswords indicates if the client sent a wrong password too often, then the connection is closed.
+func (c *inboundConnection) tooManyWrongPasswords() bool {
+	return c.wrongPasswords >= maxPasswordAttempts
+}
+
+// logFormat returns the request in the long format for logs and the dashboard, without the password.
+func (r request) logFormat() string {
+	if r.Long == passwordCommand.Long {
+		return `\` + passwordCommand.Long + " " + redactedPassword
+	}
+	return r.LongFormat()
+}
+
+// redactPassword removes the password from the given line of Hamlib requests, the rest of the line is dropped.
+func redactPassword(line string) string {
+	for _, command := range []string{`\` + passwordCommand.Long, string([]byte{passwordCommand.Short})} {
+		i := strings.Index(line, command)
+		if i >= 0 {
+			return line[:i+len(command)] + " " + redactedPassword
+		}
+	}
+	return line
+}
@@ -0,0 +1,183 @@
+package adapter
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactPassword(t *testing.T) {
+	tt := []struct {
+		line     string
+		expected string
+	}{
+		{`f`, `f`},
+		{`\password secret`, `\password ***`},
+		{`+\password secret`, `+\password ***`},
+		{"\x98 secret", "\x98 ***"},
+		{`f;\password secret;F 7074000`, `f;\password ***`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.line, func(t *testing.T) {
+			assert.Equal(t, tc.expected, redactPassword(tc.line))
+		})
+	}
+}
+
+func TestRequest_LogFormat(t *testing.T) {
+	reader := newRequestReader(strings.NewReader("\\password secret\nF 7074000\n"))
+
+	req, err := reader.ReadRequest(false)
+	require.NoError(t, err)
+	assert.Equal(t, `\password ***`, req.logFormat())
+
+	req, err = reader.ReadRequest(false)
+	require.NoError(t, err)
+	assert.Equal(t, `\set_freq 7074000`, req.logFormat())
+}
+
+func TestE2E_Password(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Password: "secret"})
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	// the requests are sent in this order on the same connection
+	tt := []struct {
+		request  string
+		expected string
+	}{
+		{`f`, "RPRT -19"},
+		{`\password`, "RPRT -1"},
+		{`\password wrong`, "RPRT -19"},
+		{`F 14074000`, "RPRT -19"},
+		{`\password secret`, "RPRT 0"},
+		{`F 14074000`, "RPRT 0"},
+	}
+	for _, tc := range tt {
+		_, err := fmt.Fprintln(conn, tc.request)
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err, tc.request)
+		assert.Equal(t, tc.expected, line[:len(line)-1], tc.request)
+	}
+}
+
+func TestE2E_PasswordAttempts(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Password: "secret"})
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	for i := 0; i < maxPasswordAttempts; i++ {
+		_, err := fmt.Fprintln(conn, `\password wrong`)
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "RPRT -19\n", line)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF, "the connection is closed by the adapter")
+}
+
+func TestE2E_TLS(t *testing.T) {
+	setup := startE2E(t, 0)
+	ca := newTestCertificate(t, "Test CA", nil)
+	server := newTestCertificate(t, "localhost", ca)
+	client := newTestCertificate(t, "client", ca)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+	setup.adapter.Reconfigure(Settings{TLS: &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate()},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}})
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.certificate)
+
+	dial := func(certificates ...tls.Certificate) (string, error) {
+		conn, err := tls.Dial("tcp", setup.adapter.Addr(0).String(), &tls.Config{
+			RootCAs:      rootCAs,
+			ServerName:   "localhost",
+			Certificates: certificates,
+		})
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(e2eTimeout))
+		_, err = fmt.Fprintln(conn, "f")
+		if err != nil {
+			return "", err
+		}
+		return bufio.NewReader(conn).ReadString('\n')
+	}
+
+	line, err := dial(client.tlsCertificate())
+	require.NoError(t, err)
+	assert.Equal(t, "7074000\n", line)
+
+	_, err = dial()
+	assert.Error(t, err, "no client certificate")
+}
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// newTestCertificate creates a certificate for the given name, signed by the given parent. Without parent, the
+// result is a self-signed CA.
+func newTestCertificate(t *testing.T, name string, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer := &testCertificate{certificate: template, key: key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.certificate, &key.PublicKey, signer.key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.certificate.Raw}, PrivateKey: c.key, Leaf: c.certificate}
+}
@@ -0,0 +1,518 @@
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	"github.com/ftl/rigproxy/pkg/protocol"
+)
+
+// The default VFO limits are used as long as the TCI server did not announce its VFO_LIMITS.
+const (
+	defaultMinVFOFrequency = 10000
+	defaultMaxVFOFrequency = 30000000
+)
+
+// defaultMaxRIT is the maximum RIT/XIT offset in Hz, if the TCI server's IF_LIMITS do not restrict it further.
+const defaultMaxRIT = 9999
+
+// implementedCommands lists all Hamlib commands that are handled by inboundConnection.handleRequest.
+var implementedCommands = map[string]bool{
+	"set_freq":       true,
+	"get_freq":       true,
+	"set_mode":       true,
+	"get_mode":       true,
+	"set_vfo":        true,
+	"get_vfo":        true,
+	"set_ptt":        true,
+	"get_ptt":        true,
+	"set_split_freq": true,
+	"get_split_freq": true,
+	"set_split_mode": true,
+	"get_split_mode": true,
+	"set_split_vfo":  true,
+	"get_split_vfo":  true,
+	"set_rit":        true,
+	"get_rit":        true,
+	"set_xit":        true,
+	"get_xit":        true,
+	"send_morse":     true,
+	"stop_morse":     true,
+	"wait_morse":     true,
+	"set_lock_mode":  true,
+	"get_lock_mode":  true,
+	"chk_vfo":        true,
+	"set_vfo_opt":    true,
+	"dump_caps":      true,
+	"dump_state":     true,
+	"get_func":       true,
+	"set_func":       true,
+	"set_trn":        true,
+	"get_trn":        true,
+}
+
+// Bit masks of the Hamlib modes as defined in rig.h.
+var hamlibModeBits = map[hamlib.Mode]uint64{
+	hamlib.ModeAM:      1 << 0,
+	hamlib.ModeCW:      1 << 1,
+	hamlib.ModeUSB:     1 << 2,
+	hamlib.ModeLSB:     1 << 3,
+	hamlib.ModeRTTY:    1 << 4,
+	hamlib.ModeFM:      1 << 5,
+	hamlib.ModeWFM:     1 << 6,
+	hamlib.ModeCWR:     1 << 7,
+	hamlib.ModeRTTYR:   1 << 8,
+	hamlib.ModeAMS:     1 << 9,
+	hamlib.ModePKTLSB:  1 << 10,
+	hamlib.ModePKTUSB:  1 << 11,
+	hamlib.ModePKTFM:   1 << 12,
+	hamlib.ModeECSSUSB: 1 << 13,
+	hamlib.ModeECSSLSB: 1 << 14,
+	hamlib.ModeFAX:     1 << 15,
+	hamlib.ModeSAM:     1 << 16,
+	hamlib.ModeSAL:     1 << 17,
+	hamlib.ModeSAH:     1 << 18,
+	hamlib.ModeDSB:     1 << 19,
+}
+
+// Bit masks of the Hamlib VFOs as defined in rig.h.
+var hamlibVFOBits = map[hamlib.VFO]uint64{
+	hamlib.VFOA: 1 << 0,
+	hamlib.VFOB: 1 << 1,
+}
+
+// Bit masks of the Hamlib levels as defined in rig.h.
+var hamlibLevelBits = map[string]uint64{
+	"PREAMP":              1 << 0,
+	"ATT":                 1 << 1,
+	"VOXDELAY":            1 << 2,
+	"AF":                  1 << 3,
+	"RF":                  1 << 4,
+	"SQL":                 1 << 5,
+	"IF":                  1 << 6,
+	"APF":                 1 << 7,
+	"NR":                  1 << 8,
+	"PBT_IN":              1 << 9,
+	"PBT_OUT":             1 << 10,
+	"CWPITCH":             1 << 11,
+	"RFPOWER":             1 << 12,
+	"MICGAIN":             1 << 13,
+	"KEYSPD":              1 << 14,
+	"NOTCHF":              1 << 15,
+	"COMP":                1 << 16,
+	"AGC":                 1 << 17,
+	"BKINDL":              1 << 18,
+	"BAL":                 1 << 19,
+	"METER":               1 << 20,
+	"VOXGAIN":             1 << 21,
+	"ANTIVOX":             1 << 22,
+	"SLOPE_LOW":           1 << 23,
+	"SLOPE_HIGH":          1 << 24,
+	"BKIN_DLYMS":          1 << 25,
+	"RAWSTR":              1 << 26,
+	"SWR":                 1 << 28,
+	"ALC":                 1 << 29,
+	"STRENGTH":            1 << 30,
+	"RFPOWER_METER":       1 << 32,
+	"COMP_METER":          1 << 33,
+	"VD_METER":            1 << 34,
+	"ID_METER":            1 << 35,
+	"NOTCHF_RAW":          1 << 36,
+	"MONITOR_GAIN":        1 << 37,
+	"NB":                  1 << 38,
+	"RFPOWER_METER_WATTS": 1 << 39,
+}
+
+// capabilities describes what the adapter can do with the currently connected TCI device.
+type capabilities struct {
+	modelName    string
+	version      string
+	trx          int
+	rxOnly       bool
+	minFrequency int
+	maxFrequency int
+	maxRIT       int
+	modes        []hamlib.Mode
+	passbands    map[hamlib.Mode]int
+	vfos         []hamlib.VFO
+	levels       []levelCapability
+	functions    []string
+	commands     map[string]bool
+}
+
+func (c *inboundConnection) capabilities() capabilities {
+	device := c.tciDevice.get()
+	result := capabilities{
+		modelName:    "tciadapter",
+		version:      c.version,
+		trx:          c.trxData.trx,
+		rxOnly:       device.RXOnly,
+		minFrequency: device.MinVFOFrequency,
+		maxFrequency: device.MaxVFOFrequency,
+		maxRIT:       defaultMaxRIT,
+		levels:       levelCapabilities,
+		functions:    supportedFunctions(),
+		commands:     implementedCommands,
+	}
+	if device.DeviceName != "" {
+		result.modelName = fmt.Sprintf("tciadapter (%s)", device.DeviceName)
+	}
+	if result.maxFrequency == 0 {
+		result.minFrequency = defaultMinVFOFrequency
+		result.maxFrequency = defaultMaxVFOFrequency
+	}
+	if device.MaxIFFrequency > 0 {
+		result.maxRIT = min(result.maxRIT, device.MaxIFFrequency, -device.MinIFFrequency)
+	}
+	if device.TRXCount == 0 || c.trxData.trx < device.TRXCount {
+		result.vfos = []hamlib.VFO{hamlib.VFOA, hamlib.VFOB}
+	}
+	settings := c.settings.current()
+	result.modes = settings.modes.hamlibModes(device.Modes, settings.noDigimodes)
+	result.passbands = make(map[hamlib.Mode]int, len(result.modes))
+	frequency := c.trxData.VFOFrequency(c.getCurrentVFO())
+	for _, mode := range result.modes {
+		tciMode, _, _ := settings.modes.tciMode(mode, frequency)
+		if settings.noDigimodes {
+			tciMode = overrideDigimode(tciMode)
+		}
+		result.passbands[mode] = defaultPassbands[tciMode]
+	}
+
+	return result
+}
+
+// sortHamlibModes orders the given Hamlib modes like in rig.h.
+func sortHamlibModes(modes []hamlib.Mode) {
+	sort.Slice(modes, func(i, j int) bool {
+		return hamlibModeBits[modes[i]] < hamlibModeBits[modes[j]]
+	})
+}
+
+// supportedFunctions returns the names of all supported Hamlib functions, ordered like in rig.h. Some of them can only
+// be read, see canSetFunction.
+func supportedFunctions() []string {
+	result := make([]string, 0, len(hamlibFunctions))
+	for name := range hamlibFunctions {
+		result = append(result, name)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return hamlibFunctionBits[result[i]] < hamlibFunctionBits[result[j]]
+	})
+	return result
+}
+
+// passbandGroups groups the available modes by their normal passband, ordered by passband.
+func (c capabilities) passbandGroups() ([]int, map[int][]hamlib.Mode) {
+	passbands := make([]int, 0)
+	modes := make(map[int][]hamlib.Mode)
+	for _, mode := range c.modes {
+		passband := c.passbands[mode]
+		if passband == 0 {
+			continue
+		}
+		if _, ok := modes[passband]; !ok {
+			passbands = append(passbands, passband)
+		}
+		modes[passband] = append(modes[passband], mode)
+	}
+	sort.Ints(passbands)
+	return passbands, modes
+}
+
+func modeBits(modes []hamlib.Mode) uint64 {
+	var result uint64
+	for _, mode := range modes {
+		result |= hamlibModeBits[mode]
+	}
+	return result
+}
+
+func (c capabilities) modeBits() uint64 {
+	return modeBits(c.modes)
+}
+
+func (c capabilities) vfoBits() uint64 {
+	var result uint64
+	for _, vfo := range c.vfos {
+		result |= hamlibVFOBits[vfo]
+	}
+	return result
+}
+
+func (c capabilities) levelBits(set bool) uint64 {
+	var result uint64
+	for _, level := range c.levels {
+		if (set && level.set) || (!set && level.get) {
+			result |= hamlibLevelBits[level.name]
+		}
+	}
+	return result
+}
+
+func (c capabilities) functionBits(set bool) uint64 {
+	var result uint64
+	for _, function := range c.functions {
+		if !set || canSetFunction(function) {
+			result |= hamlibFunctionBits[function]
+		}
+	}
+	return result
+}
+
+// canSetFunction indicates if the given Hamlib function can be set through set_func.
+func canSetFunction(name string) bool {
+	return hamlibFunctions[name].set != nil
+}
+
+func (c capabilities) can(command string) string {
+	return yesNo(c.commands[command])
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "Y"
+	}
+	return "N"
+}
+
+func joinModes(modes []hamlib.Mode) string {
+	var result strings.Builder
+	for _, mode := range modes {
+		fmt.Fprintf(&result, "%s ", mode)
+	}
+	return result.String()
+}
+
+func joinVFOs(vfos []hamlib.VFO) string {
+	var result strings.Builder
+	for _, vfo := range vfos {
+		fmt.Fprintf(&result, "%s ", vfo)
+	}
+	return result.String()
+}
+
+func joinFunctions(functions []string, set bool) string {
+	var result strings.Builder
+	for _, function := range functions {
+		if !set || canSetFunction(function) {
+			fmt.Fprintf(&result, "%s ", function)
+		}
+	}
+	return result.String()
+}
+
+func joinLevels(levels []levelCapability, set bool) string {
+	var result strings.Builder
+	for _, level := range levels {
+		if (set && !level.set) || (!set && !level.get) {
+			continue
+		}
+		fmt.Fprintf(&result, "%s(%s..%s/%s) ", level.name, formatRange(level.min), formatRange(level.max), formatRange(level.step))
+	}
+	return result.String()
+}
+
+func formatPassband(passband int) string {
+	if passband < 1000 {
+		return fmt.Sprintf("%.1f Hz", float64(passband))
+	}
+	return fmt.Sprintf("%.4f kHz", float64(passband)/1000)
+}
+
+func formatRange(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func dumpCapsResponse(caps capabilities) protocol.Response {
+	var result strings.Builder
+	rigType := "Transceiver"
+	if caps.rxOnly {
+		rigType = "Receiver"
+	}
+
+	fmt.Fprintf(&result, "Caps dump for model: 1\n")
+	fmt.Fprintf(&result, "Model name:\t%s\n", caps.modelName)
+	fmt.Fprintf(&result, "Mfg name:\tdl3ney\n")
+	fmt.Fprintf(&result, "Backend version:\t%s\n", caps.version)
+	fmt.Fprintf(&result, "Backend copyright:\tMIT\n")
+	fmt.Fprintf(&result, "Backend status:\tStable\n")
+	fmt.Fprintf(&result, "Rig type:\t%s\n", rigType)
+	fmt.Fprintf(&result, "PTT type:\tRig capable\n")
+	fmt.Fprintf(&result, "DCD type:\tNone\n")
+	fmt.Fprintf(&result, "Port type:\tNetwork link\n")
+	fmt.Fprintf(&result, "Write delay: 0mS, timeout 0mS, 0 retry\n")
+	fmt.Fprintf(&result, "Post Write delay: 0mS\n")
+	fmt.Fprintf(&result, "Has targetable VFO: Y\n")
+	fmt.Fprintf(&result, "Has async data support: Y\n")
+	fmt.Fprintf(&result, "Announce: 0x0\n")
+	fmt.Fprintf(&result, "Max RIT: -%.3fkHz/+%.3fkHz\n", float64(caps.maxRIT)/1000, float64(caps.maxRIT)/1000)
+	fmt.Fprintf(&result, "Max XIT: -%.3fkHz/+%.3fkHz\n", float64(caps.maxRIT)/1000, float64(caps.maxRIT)/1000)
+	fmt.Fprintf(&result, "Max IF-SHIFT: -0.0kHz/+0.0kHz\n")
+	fmt.Fprintf(&result, "Preamp: None\n")
+	fmt.Fprintf(&result, "Attenuator: None\n")
+	fmt.Fprintf(&result, "Get functions: %s\n", joinFunctions(caps.functions, false))
+	fmt.Fprintf(&result, "Set functions: %s\n", joinFunctions(caps.functions, true))
+	fmt.Fprintf(&result, "Get level: %s\n", joinLevels(caps.levels, false))
+	fmt.Fprintf(&result, "Set level: %s\n", joinLevels(caps.levels, true))
+	fmt.Fprintf(&result, "Get parameters: \n")
+	fmt.Fprintf(&result, "Set parameters: \n")
+	fmt.Fprintf(&result, "Mode list: %s\n", joinModes(caps.modes))
+	fmt.Fprintf(&result, "VFO list: %s\n", joinVFOs(caps.vfos))
+	fmt.Fprintf(&result, "VFO Ops: \n")
+	fmt.Fprintf(&result, "Scan Ops: \n")
+	fmt.Fprintf(&result, "Number of banks:\t0\n")
+	fmt.Fprintf(&result, "Memory name desc size:\t0\n")
+	fmt.Fprintf(&result, "Memories: None\n")
+	if len(caps.vfos) > 0 {
+		if !caps.rxOnly {
+			fmt.Fprintf(&result, "TX ranges #1 for TRX %d:\n", caps.trx)
+			fmt.Fprintf(&result, "\t%d Hz - %d Hz\n", caps.minFrequency, caps.maxFrequency)
+			fmt.Fprintf(&result, "\t\tVFO list: %s\n", joinVFOs(caps.vfos))
+			fmt.Fprintf(&result, "\t\tMode list: %s\n", joinModes(caps.modes))
+			fmt.Fprintf(&result, "\t\tAntenna list: ANT1 \n")
+			fmt.Fprintf(&result, "\t\tLow power: 0 W, High power: %g W\n", nominalTXPower)
+		}
+		fmt.Fprintf(&result, "RX ranges #1 for TRX %d:\n", caps.trx)
+		fmt.Fprintf(&result, "\t%d Hz - %d Hz\n", caps.minFrequency, caps.maxFrequency)
+		fmt.Fprintf(&result, "\t\tVFO list: %s\n", joinVFOs(caps.vfos))
+		fmt.Fprintf(&result, "\t\tMode list: %s\n", joinModes(caps.modes))
+		fmt.Fprintf(&result, "\t\tAntenna list: ANT1 \n")
+	}
+	fmt.Fprintf(&result, "Tuning steps:\n")
+	fmt.Fprintf(&result, "\t1.0 Hz:   \t%s\n", joinModes(caps.modes))
+	passbands, passbandModes := caps.passbandGroups()
+	fmt.Fprintf(&result, "Filters:\n")
+	for _, passband := range passbands {
+		fmt.Fprintf(&result, "\t%s:   \t%s\n", formatPassband(passband), joinModes(passbandModes[passband]))
+	}
+	fmt.Fprintf(&result, "\tANY:   \t%s\n", joinModes(caps.modes))
+	fmt.Fprintf(&result, "Bandwidths:\n")
+	for _, mode := range caps.modes {
+		fmt.Fprintf(&result, "\t%s\tNormal: %s,\tNarrow: 0.0 Hz,\tWide: 0.0 Hz\n", mode, formatPassband(caps.passbands[mode]))
+	}
+	fmt.Fprintf(&result, "Has priv data:\tN\n")
+	fmt.Fprintf(&result, "Has Init:\tN\n")
+	fmt.Fprintf(&result, "Has Cleanup:\tN\n")
+	fmt.Fprintf(&result, "Has Open:\tY\n")
+	fmt.Fprintf(&result, "Has Close:\tY\n")
+	fmt.Fprintf(&result, "Can set Conf:\tN\n")
+	fmt.Fprintf(&result, "Can get Conf:\tN\n")
+	fmt.Fprintf(&result, "Can set Frequency:\t%s\n", caps.can("set_freq"))
+	fmt.Fprintf(&result, "Can get Frequency:\t%s\n", caps.can("get_freq"))
+	fmt.Fprintf(&result, "Can set Mode:\t%s\n", caps.can("set_mode"))
+	fmt.Fprintf(&result, "Can get Mode:\t%s\n", caps.can("get_mode"))
+	fmt.Fprintf(&result, "Can set VFO:\t%s\n", caps.can("set_vfo"))
+	fmt.Fprintf(&result, "Can get VFO:\t%s\n", caps.can("get_vfo"))
+	fmt.Fprintf(&result, "Can set PTT:\t%s\n", yesNo(caps.commands["set_ptt"] && !caps.rxOnly))
+	fmt.Fprintf(&result, "Can get PTT:\t%s\n", caps.can("get_ptt"))
+	fmt.Fprintf(&result, "Can get DCD:\tN\n")
+	fmt.Fprintf(&result, "Can set Repeater Duplex:\tN\n")
+	fmt.Fprintf(&result, "Can get Repeater Duplex:\tN\n")
+	fmt.Fprintf(&result, "Can set Repeater Offset:\tN\n")
+	fmt.Fprintf(&result, "Can get Repeater Offset:\tN\n")
+	fmt.Fprintf(&result, "Can set Split Freq:\t%s\n", caps.can("set_split_freq"))
+	fmt.Fprintf(&result, "Can get Split Freq:\t%s\n", caps.can("get_split_freq"))
+	fmt.Fprintf(&result, "Can set Split Mode:\t%s\n", caps.can("set_split_mode"))
+	fmt.Fprintf(&result, "Can get Split Mode:\t%s\n", caps.can("get_split_mode"))
+	fmt.Fprintf(&result, "Can set Split VFO:\t%s\n", caps.can("set_split_vfo"))
+	fmt.Fprintf(&result, "Can get Split VFO:\t%s\n", caps.can("get_split_vfo"))
+	fmt.Fprintf(&result, "Can set Tuning Step:\tN\n")
+	fmt.Fprintf(&result, "Can get Tuning Step:\tN\n")
+	fmt.Fprintf(&result, "Can set RIT:\t%s\n", caps.can("set_rit"))
+	fmt.Fprintf(&result, "Can get RIT:\t%s\n", caps.can("get_rit"))
+	fmt.Fprintf(&result, "Can set XIT:\t%s\n", caps.can("set_xit"))
+	fmt.Fprintf(&result, "Can get XIT:\t%s\n", caps.can("get_xit"))
+	fmt.Fprintf(&result, "Can set CTCSS:\tN\n")
+	fmt.Fprintf(&result, "Can get CTCSS:\tN\n")
+	fmt.Fprintf(&result, "Can set DCS:\tN\n")
+	fmt.Fprintf(&result, "Can get DCS:\tN\n")
+	fmt.Fprintf(&result, "Can set CTCSS Squelch:\tN\n")
+	fmt.Fprintf(&result, "Can get CTCSS Squelch:\tN\n")
+	fmt.Fprintf(&result, "Can set DCS Squelch:\tN\n")
+	fmt.Fprintf(&result, "Can get DCS Squelch:\tN\n")
+	fmt.Fprintf(&result, "Can set Power Stat:\tN\n")
+	fmt.Fprintf(&result, "Can get Power Stat:\tN\n")
+	fmt.Fprintf(&result, "Can Reset:\tN\n")
+	fmt.Fprintf(&result, "Can get Ant:\tN\n")
+	fmt.Fprintf(&result, "Can set Ant:\tN\n")
+	fmt.Fprintf(&result, "Can set Transceive:\t%s\n", caps.can("set_trn"))
+	fmt.Fprintf(&result, "Can get Transceive:\t%s\n", caps.can("get_trn"))
+	fmt.Fprintf(&result, "Can set Func:\t%s\n", caps.can("set_func"))
+	fmt.Fprintf(&result, "Can get Func:\t%s\n", caps.can("get_func"))
+	fmt.Fprintf(&result, "Can set Level:\t%s\n", yesNo(caps.levelBits(true) != 0))
+	fmt.Fprintf(&result, "Can get Level:\t%s\n", yesNo(caps.levelBits(false) != 0))
+	fmt.Fprintf(&result, "Can set Param:\tN\n")
+	fmt.Fprintf(&result, "Can get Param:\tN\n")
+	fmt.Fprintf(&result, "Can send DTMF:\tN\n")
+	fmt.Fprintf(&result, "Can recv DTMF:\tN\n")
+	fmt.Fprintf(&result, "Can send Morse:\t%s\n", yesNo(caps.commands["send_morse"] && !caps.rxOnly))
+	fmt.Fprintf(&result, "Can send Voice:\tN\n")
+	fmt.Fprintf(&result, "Can decode Events:\tN\n")
+	fmt.Fprintf(&result, "Can set Bank:\tN\n")
+	fmt.Fprintf(&result, "Can set Mem:\tN\n")
+	fmt.Fprintf(&result, "Can get Mem:\tN\n")
+	fmt.Fprintf(&result, "Can set Channel:\tN\n")
+	fmt.Fprintf(&result, "Can get Channel:\tN\n")
+	fmt.Fprintf(&result, "Can ctl Mem/VFO:\tN\n")
+	fmt.Fprintf(&result, "Can Scan:\tN\n")
+	fmt.Fprintf(&result, "Can get Info:\tN\n")
+	fmt.Fprintf(&result, "Can get power2mW:\tN\n")
+	fmt.Fprintf(&result, "Can get mW2power:\tN\n")
+	fmt.Fprintf(&result, "\nOverall backend warnings: 0\n")
+
+	return protocol.Response{
+		Command: "dump_caps",
+		Data:    []string{result.String()},
+		Keys:    []string{""},
+		Result:  "0",
+	}
+}
+
+func dumpStateResponse(caps capabilities) protocol.Response {
+	var result strings.Builder
+	modes := caps.modeBits()
+	vfos := caps.vfoBits()
+
+	fmt.Fprintf(&result, "0\n") // protocol version
+	fmt.Fprintf(&result, "1\n") // rig model
+	fmt.Fprintf(&result, "2\n") // ITU region
+	if len(caps.vfos) > 0 {
+		fmt.Fprintf(&result, "%d.000000 %d.000000 0x%x -1 -1 0x%x 0x1\n", caps.minFrequency, caps.maxFrequency, modes, vfos)
+	}
+	fmt.Fprintf(&result, "0 0 0 0 0 0 0\n")
+	if len(caps.vfos) > 0 && !caps.rxOnly {
+		fmt.Fprintf(&result, "%d.000000 %d.000000 0x%x 0 %d 0x%x 0x1\n", caps.minFrequency, caps.maxFrequency, modes, int(nominalTXPower*1000), vfos)
+	}
+	fmt.Fprintf(&result, "0 0 0 0 0 0 0\n")
+	fmt.Fprintf(&result, "0x%x 1\n", modes) // tuning steps
+	fmt.Fprintf(&result, "0 0\n")
+	passbands, passbandModes := caps.passbandGroups()
+	for _, passband := range passbands {
+		fmt.Fprintf(&result, "0x%x %d\n", modeBits(passbandModes[passband]), passband)
+	}
+	fmt.Fprintf(&result, "0x%x 0\n", modes) // 0 = any passband
+	fmt.Fprintf(&result, "0 0\n")
+	fmt.Fprintf(&result, "%d\n", caps.maxRIT)
+	fmt.Fprintf(&result, "%d\n", caps.maxRIT)
+	fmt.Fprintf(&result, "0\n") // max IF shift
+	fmt.Fprintf(&result, "0\n") // announces
+	fmt.Fprintf(&result, "\n")  // preamps
+	fmt.Fprintf(&result, "\n")  // attenuators
+	fmt.Fprintf(&result, "0x%x\n", caps.functionBits(false))
+	fmt.Fprintf(&result, "0x%x\n", caps.functionBits(true))
+	fmt.Fprintf(&result, "0x%x\n", caps.levelBits(false))
+	fmt.Fprintf(&result, "0x%x\n", caps.levelBits(true))
+	fmt.Fprintf(&result, "0x0\n")
+	fmt.Fprintf(&result, "0x0\n")
+
+	return protocol.Response{
+		Command: "dump_state",
+		Data:    []string{result.String()},
+		Keys:    []string{""},
+		Result:  "0",
+	}
+}
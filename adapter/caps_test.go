@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"testing"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCapabilities(rxOnly bool, vfos ...hamlib.VFO) capabilities {
+	return capabilities{
+		modelName:    "tciadapter",
+		rxOnly:       rxOnly,
+		minFrequency: 10000,
+		maxFrequency: 30000000,
+		maxRIT:       defaultMaxRIT,
+		modes:        []hamlib.Mode{hamlib.ModeCW, hamlib.ModeUSB, hamlib.ModePKTUSB},
+		passbands:    map[hamlib.Mode]int{hamlib.ModeCW: 500, hamlib.ModeUSB: 2400, hamlib.ModePKTUSB: 3000},
+		vfos:         vfos,
+		levels:       levelCapabilities,
+		functions:    supportedFunctions(),
+		commands:     implementedCommands,
+	}
+}
+
+func TestCapabilitiesBits(t *testing.T) {
+	caps := testCapabilities(false, hamlib.VFOA, hamlib.VFOB)
+
+	tt := []struct {
+		name     string
+		value    uint64
+		expected uint64
+	}{
+		{"modes", caps.modeBits(), 0x806},
+		{"VFOs", caps.vfoBits(), 0x3},
+		{"get levels", caps.levelBits(false), 0x8154005828},
+		{"set levels", caps.levelBits(true), 0x5028},
+		{"get functions", caps.functionBits(false), 0x40030b02},
+		{"set functions without LOCK", caps.functionBits(true), 0x40020b02},
+		{"no modes", modeBits(nil), 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.value, "0x%x", tc.value)
+		})
+	}
+}
+
+func TestDumpStateResponse(t *testing.T) {
+	const (
+		header     = "0\n1\n2\n"
+		rxRange    = "10000.000000 30000000.000000 0x806 -1 -1 0x3 0x1\n"
+		txRange    = "10000.000000 30000000.000000 0x806 0 100000 0x3 0x1\n"
+		endOfRange = "0 0 0 0 0 0 0\n"
+		steps      = "0x806 1\n0 0\n"
+		filters    = "0x2 500\n0x4 2400\n0x800 3000\n0x806 0\n0 0\n"
+		noFilters  = "0x806 0\n0 0\n"
+		limits     = "9999\n9999\n0\n0\n\n\n"
+		masks      = "0x40030b02\n0x40020b02\n0x8154005828\n0x5028\n0x0\n0x0\n"
+	)
+	tt := []struct {
+		name     string
+		caps     capabilities
+		expected string
+	}{
+		{"transceiver", testCapabilities(false, hamlib.VFOA, hamlib.VFOB), header + rxRange + endOfRange + txRange + endOfRange + steps + filters + limits + masks},
+		{"receiver", testCapabilities(true, hamlib.VFOA, hamlib.VFOB), header + rxRange + endOfRange + endOfRange + steps + filters + limits + masks},
+		{"TRX not available", testCapabilities(false), header + endOfRange + endOfRange + steps + filters + limits + masks},
+		{"no passbands", func() capabilities {
+			caps := testCapabilities(false, hamlib.VFOA, hamlib.VFOB)
+			caps.passbands = nil
+			return caps
+		}(), header + rxRange + endOfRange + txRange + endOfRange + steps + noFilters + limits + masks},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			response := dumpStateResponse(tc.caps)
+
+			assert.Equal(t, []string{tc.expected}, response.Data)
+		})
+	}
+}
+
+func TestDumpCapsResponse(t *testing.T) {
+	tt := []struct {
+		name     string
+		caps     capabilities
+		expected []string
+	}{
+		{"transceiver", testCapabilities(false, hamlib.VFOA, hamlib.VFOB), []string{
+			"Rig type:\tTransceiver\n",
+			"Max RIT: -9.999kHz/+9.999kHz\n",
+			"Get functions: NB ANF NR APF LOCK MUTE TUNER \n",
+			"Set functions: NB ANF NR APF MUTE TUNER \n",
+			"Set level: AF(0..1/0.016666666666666666) SQL(0..1/0.007142857142857143) RFPOWER(0..1/0.01) KEYSPD(1..60/1) \n",
+			"Mode list: CW USB PKTUSB \n",
+			"VFO list: VFOA VFOB \n",
+			"TX ranges #1 for TRX 0:\n",
+			"\t500.0 Hz:   \tCW \n",
+			"\tPKTUSB\tNormal: 3.0000 kHz,\tNarrow: 0.0 Hz,\tWide: 0.0 Hz\n",
+			"Can set PTT:\tY\n",
+			"Can set Level:\tY\n",
+			"Can send Morse:\tY\n",
+		}},
+		{"receiver", testCapabilities(true, hamlib.VFOA, hamlib.VFOB), []string{
+			"Rig type:\tReceiver\n",
+			"RX ranges #1 for TRX 0:\n",
+			"Can set PTT:\tN\n",
+			"Can send Morse:\tN\n",
+		}},
+		{"TRX not available", testCapabilities(false), []string{
+			"VFO list: \n",
+			"Memories: None\nTuning steps:\n",
+		}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			response := dumpCapsResponse(tc.caps)
+
+			for _, line := range tc.expected {
+				assert.Contains(t, response.Data[0], line)
+			}
+		})
+	}
+}
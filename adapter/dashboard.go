@@ -0,0 +1,319 @@
+package adapter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+const (
+	// dashboardUpdateInterval limits how often the dashboard is updated, the S-meter readings change continuously.
+	dashboardUpdateInterval = 250 * time.Millisecond
+	// dashboardRefreshInterval defines how often the dashboard is updated without any change of the TRX state,
+	// to show the changes of the clients and the TCI connection.
+	dashboardRefreshInterval = 2 * time.Second
+	// recentErrorsSize is the number of errors that are shown on the dashboard.
+	recentErrorsSize = 20
+)
+
+// hamlibClient describes a connected Hamlib client.
+type hamlibClient struct {
+	id              int64
+	trx             int
+	remoteAddress   string
+	connectedSince  time.Time
+	lastCommand     string
+	lastCommandTime time.Time
+}
+
+// hamlibClients keeps track of the connected Hamlib clients.
+type hamlibClients struct {
+	mutex   sync.Mutex
+	clients map[int64]*hamlibClient
+}
+
+func newHamlibClients() *hamlibClients {
+	return &hamlibClients{
+		clients: make(map[int64]*hamlibClient),
+	}
+}
+
+func (c *hamlibClients) add(id int64, trx int, remoteAddress string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clients[id] = &hamlibClient{
+		id:             id,
+		trx:            trx,
+		remoteAddress:  remoteAddress,
+		connectedSince: time.Now(),
+	}
+}
+
+func (c *hamlibClients) remove(id int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.clients, id)
+}
+
+func (c *hamlibClients) commandReceived(id int64, command string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	client, ok := c.clients[id]
+	if !ok {
+		return
+	}
+	client.lastCommand = command
+	client.lastCommandTime = time.Now()
+}
+
+func (c *hamlibClients) list() []hamlibClient {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	result := make([]hamlibClient, 0, len(c.clients))
+	for _, client := range c.clients {
+		result = append(result, *client)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].id < result[j].id
+	})
+	return result
+}
+
+type recentError struct {
+	time    time.Time
+	message string
+}
+
+// recentErrors keeps the latest errors in a ring buffer.
+type recentErrors struct {
+	mutex  sync.Mutex
+	errors []recentError
+	next   int
+}
+
+func newRecentErrors(size int) *recentErrors {
+	return &recentErrors{
+		errors: make([]recentError, 0, size),
+	}
+}
+
+func (e *recentErrors) add(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	entry := recentError{time: time.Now(), message: err.Error()}
+	if len(e.errors) < cap(e.errors) {
+		e.errors = append(e.errors, entry)
+		return
+	}
+	e.errors[e.next] = entry
+	e.next = (e.next + 1) % len(e.errors)
+}
+
+// list returns the recent errors, the latest error first.
+func (e *recentErrors) list() []recentError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	result := make([]recentError, 0, len(e.errors))
+	for i := len(e.errors) - 1; i >= 0; i-- {
+		result = append(result, e.errors[(e.next+i)%len(e.errors)])
+	}
+	return result
+}
+
+// dashboardStatus is the state of the adapter as it is shown on the dashboard.
+type dashboardStatus struct {
+	Version      string            `json:"version"`
+	TCIConnected bool              `json:"tci_connected"`
+	TRX          []dashboardTRX    `json:"trx"`
+	Clients      []dashboardClient `json:"clients"`
+	Errors       []dashboardError  `json:"errors"`
+}
+
+type dashboardTRX struct {
+	TRX          int    `json:"trx"`
+	VFOA         int    `json:"vfo_a"`
+	VFOB         int    `json:"vfo_b"`
+	Mode         string `json:"mode"`
+	FilterMin    int    `json:"filter_min"`
+	FilterMax    int    `json:"filter_max"`
+	SMeter       int    `json:"smeter"`
+	Split        bool   `json:"split"`
+	Transmitting bool   `json:"tx"`
+}
+
+type dashboardClient struct {
+	TRX             int        `json:"trx"`
+	RemoteAddress   string     `json:"remote_address"`
+	ConnectedSince  time.Time  `json:"connected_since"`
+	LastCommand     string     `json:"last_command,omitempty"`
+	LastCommandTime *time.Time `json:"last_command_time,omitempty"`
+}
+
+type dashboardError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+func (a *Adapter) dashboardStatus() dashboardStatus {
+	result := dashboardStatus{
+		Version:      a.version,
+		TCIConnected: a.metrics.connected(),
+		TRX:          make([]dashboardTRX, 0, len(a.trxListeners)),
+		Clients:      []dashboardClient{},
+		Errors:       []dashboardError{},
+	}
+	for _, trxListener := range a.trxListeners {
+		state := trxListener.trxData.Snapshot()
+		result.TRX = append(result.TRX, dashboardTRX{
+			TRX:          state.TRX,
+			VFOA:         state.VFOs[0].Frequency,
+			VFOB:         state.VFOs[1].Frequency,
+			Mode:         strings.ToUpper(string(state.Mode)),
+			FilterMin:    state.RXFilterMin,
+			FilterMax:    state.RXFilterMax,
+			SMeter:       state.SMeter(tci.VFOA),
+			Split:        state.SplitEnabled,
+			Transmitting: state.Transmitting,
+		})
+	}
+	for _, client := range a.clients.list() {
+		entry := dashboardClient{
+			TRX:            client.trx,
+			RemoteAddress:  client.remoteAddress,
+			ConnectedSince: client.connectedSince,
+			LastCommand:    client.lastCommand,
+		}
+		if !client.lastCommandTime.IsZero() {
+			lastCommandTime := client.lastCommandTime
+			entry.LastCommandTime = &lastCommandTime
+		}
+		result.Clients = append(result.Clients, entry)
+	}
+	for _, recentError := range a.errors.list() {
+		result.Errors = append(result.Errors, dashboardError{Time: recentError.time, Message: recentError.message})
+	}
+	return result
+}
+
+// ListenDashboard opens the given local address to provide a web dashboard that shows the live state of the adapter.
+func (a *Adapter) ListenDashboard(localAddress string) error {
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return fmt.Errorf("cannot open local port %s for the dashboard: %w", localAddress, err)
+	}
+	log.Printf("providing the dashboard on http://%s/", listener.Addr())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", a.serveDashboard)
+	mux.HandleFunc("/status", a.serveDashboardStatus)
+	mux.HandleFunc("/events", a.serveDashboardEvents)
+	httpServer := &http.Server{Handler: a.acceptHTTP("Dashboard", mux)}
+
+	go func() {
+		<-a.closed
+		httpServer.Close()
+	}()
+	go func() {
+		err := httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("dashboard listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (a *Adapter) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+func (a *Adapter) serveDashboardStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.dashboardStatus())
+}
+
+// serveDashboardEvents sends the status of the adapter as server-sent events, whenever the state of a TRX changes.
+func (a *Adapter) serveDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	changed := make(chan struct{}, 1)
+	for _, trxListener := range a.trxListeners {
+		changes, unsubscribe := trxListener.trxData.Subscribe()
+		defer unsubscribe()
+		go func() {
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-changes:
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	update := time.NewTicker(dashboardUpdateInterval)
+	defer update.Stop()
+	lastSent := time.Time{}
+	dirty := true
+	for {
+		if dirty || time.Since(lastSent) >= dashboardRefreshInterval {
+			data, err := json.Marshal(a.dashboardStatus())
+			if err != nil {
+				log.Printf("dashboard: %v", err)
+				return
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+			lastSent = time.Now()
+			dirty = false
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.closed:
+			return
+		case <-changed:
+			dirty = true
+			// wait for the next update, to limit the update rate
+			select {
+			case <-r.Context().Done():
+				return
+			case <-a.closed:
+				return
+			case <-update.C:
+			}
+		case <-update.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentErrors_KeepsTheLatestErrors(t *testing.T) {
+	recentErrors := newRecentErrors(3)
+	for i := 1; i <= 5; i++ {
+		recentErrors.add(fmt.Errorf("error %d", i))
+	}
+
+	var messages []string
+	for _, recentError := range recentErrors.list() {
+		messages = append(messages, recentError.message)
+	}
+	assert.Equal(t, []string{"error 5", "error 4", "error 3"}, messages)
+}
+
+func TestE2E_DashboardStatus(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	require.NoError(t, rig.SetFrequency(e2eContext(t), 14074000))
+	setup.adapter.errors.add(errors.New("something failed"))
+
+	assert.Eventually(t, func() bool {
+		return setup.adapter.dashboardStatus().TRX[0].VFOA == 14074000
+	}, e2eTimeout, e2eTick)
+
+	recorder := httptest.NewRecorder()
+	setup.adapter.serveDashboardStatus(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status dashboardStatus
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+
+	assert.True(t, status.TCIConnected)
+	require.Len(t, status.TRX, 1)
+	assert.Equal(t, "USB", status.TRX[0].Mode)
+	require.Len(t, status.Clients, 1)
+	assert.Equal(t, `\set_freq 14074000`, status.Clients[0].LastCommand)
+	require.Len(t, status.Errors, 1)
+	assert.Equal(t, "something failed", status.Errors[0].Message)
+}
+
+func TestE2E_DashboardEvents(t *testing.T) {
+	setup := startE2E(t, 0)
+	server := httptest.NewServer(http.HandlerFunc(setup.adapter.serveDashboardEvents))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan dashboardStatus, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var status dashboardStatus
+			if json.Unmarshal([]byte(data), &status) == nil {
+				events <- status
+			}
+		}
+	}()
+
+	setup.sdr.Set("vfo", 0, 1, 3573000)
+
+	timeout := time.After(e2eTimeout)
+	for {
+		select {
+		case status := <-events:
+			if len(status.TRX) == 1 && status.TRX[0].VFOB == 3573000 {
+				return
+			}
+		case <-timeout:
+			require.Fail(t, "the change of VFO B was not sent to the dashboard")
+		}
+	}
+}
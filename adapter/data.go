@@ -1,111 +1,609 @@
 package adapter
 
 import (
+	"math"
 	"sync"
 
 	tci "github.com/ftl/tci/client"
 )
 
+// defaultCWPitch is the CW pitch in Hz that is assumed for the TRX until the TCI server reports its CW pitch.
+const defaultCWPitch = 600
+
+// vfoCount is the number of VFOs of each TRX in TCI.
+const vfoCount = 2
+
 func newTRXData(trx int) *TRXData {
+	txDone := make(chan struct{})
+	close(txDone)
 	return &TRXData{
-		trx:    trx,
-		vfos:   make(map[tci.VFO]vfoData),
-		txSync: new(sync.WaitGroup),
+		trx: trx,
+		state: TRXState{
+			TRX:     trx,
+			CWPitch: defaultCWPitch,
+		},
+		txDone:      txDone,
+		subscribers: make(map[chan struct{}]bool),
+	}
+}
+
+// VFOState is the state of one VFO.
+type VFOState struct {
+	Frequency   int
+	SMeter      int
+	SMeterSet   bool
+	RXVolume    int
+	RXVolumeSet bool
+}
+
+// TRXState is the state of one TRX. TRXData.Snapshot returns a copy that can be used without further synchronization.
+type TRXState struct {
+	TRX          int
+	VFOs         [vfoCount]VFOState
+	Mode         tci.Mode
+	RXFilterMin  int
+	RXFilterMax  int
+	SplitEnabled bool
+	RITEnabled   bool
+	RITOffset    int
+	XITEnabled   bool
+	XITOffset    int
+	Drive        int
+	TRXDriveSet  bool
+	MainVolume   int
+	SquelchLevel int
+	CWPitch      int
+	TXPower      float64
+	TXSWR        float64
+	RXSensor     int
+	NBEnabled    bool
+	NREnabled    bool
+	ANFEnabled   bool
+	APFEnabled   bool
+	RXMuted      bool
+	Locked       bool
+	Tuning       bool
+	SendingCW    bool
+	Transmitting bool
+}
+
+func validVFO(vfo tci.VFO) bool {
+	return vfo >= 0 && int(vfo) < vfoCount
+}
+
+func (s TRXState) vfo(vfo tci.VFO) VFOState {
+	if !validVFO(vfo) {
+		return VFOState{}
 	}
+	return s.VFOs[vfo]
 }
 
-type vfoData struct {
-	frequency int
+func (s TRXState) VFOFrequency(vfo tci.VFO) int {
+	return s.vfo(vfo).Frequency
+}
+
+// SMeter returns the signal strength of the given VFO in dBm. If the TCI server does not report the S-meter of the
+// VFO, the RX sensor of the TRX is used.
+func (s TRXState) SMeter(vfo tci.VFO) int {
+	data := s.vfo(vfo)
+	if data.SMeterSet {
+		return data.SMeter
+	}
+	return s.RXSensor
+}
+
+// Volume returns the volume of the given VFO in dB. If the TCI server does not report the volume of the
+// VFO, the main volume is used.
+func (s TRXState) Volume(vfo tci.VFO) int {
+	data := s.vfo(vfo)
+	if data.RXVolumeSet {
+		return data.RXVolume
+	}
+	return s.MainVolume
+}
+
+// Passband returns the width of the RX filter in Hz.
+func (s TRXState) Passband() int {
+	return s.RXFilterMax - s.RXFilterMin
+}
+
+// RIT returns the effective RIT offset, which is 0 if RIT is disabled.
+func (s TRXState) RIT() int {
+	if !s.RITEnabled {
+		return 0
+	}
+	return s.RITOffset
 }
 
+// XIT returns the effective XIT offset, which is 0 if XIT is disabled.
+func (s TRXState) XIT() int {
+	if !s.XITEnabled {
+		return 0
+	}
+	return s.XITOffset
+}
+
+// TXFrequency returns the frequency on which the TRX transmits: the frequency of VFO B in split mode, otherwise the
+// frequency of VFO A, including the XIT offset.
+func (s TRXState) TXFrequency() int {
+	vfo := tci.VFOA
+	if s.SplitEnabled {
+		vfo = tci.VFOB
+	}
+	return s.VFOFrequency(vfo) + s.XIT()
+}
+
+// Mute indicates if the TRX is muted. The mute of the main volume is not included, because set_func MUTE changes
+// only the mute of the TRX.
+func (s TRXState) Mute() bool {
+	return s.RXMuted
+}
+
+// Lock indicates if the VFOs of the TRX are locked on the TCI server.
+func (s TRXState) Lock() bool {
+	return s.Locked
+}
+
+// TRXData keeps the state of one TRX. It is updated by the notifications of the TCI client and read by all
+// inbound connections, all methods are safe for concurrent use.
 type TRXData struct {
-	trx          int
-	currentVFO   tci.VFO
-	vfos         map[tci.VFO]vfoData
-	mode         tci.Mode
-	rxFilterMin  int
-	rxFilterMax  int
-	splitEnabled bool
-	transmitting bool
-	txSync       *sync.WaitGroup
+	trx                    int
+	mutex                  sync.RWMutex
+	state                  TRXState
+	txDone                 chan struct{}
+	subscribers            map[chan struct{}]bool
+	cwMacrosEmptyAnnounced bool
+}
+
+// Snapshot returns a consistent copy of the current state.
+func (t *TRXData) Snapshot() TRXState {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.state
+}
+
+func (t *TRXData) update(f func(*TRXState)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	old := t.state
+	f(&t.state)
+	if t.state != old {
+		t.notifySubscribers()
+	}
+}
+
+// Subscribe returns a channel that receives a signal whenever the state changes. The signals are coalesced:
+// while a subscriber is busy, all further changes result in only one pending signal. Subscribers use Snapshot
+// to read the new state. The returned function ends the subscription.
+func (t *TRXData) Subscribe() (<-chan struct{}, func()) {
+	changes := make(chan struct{}, 1)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.subscribers[changes] = true
+
+	return changes, func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		delete(t.subscribers, changes)
+	}
+}
+
+// notifySubscribers must only be called while holding the lock.
+func (t *TRXData) notifySubscribers() {
+	for changes := range t.subscribers {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
 }
 
-func (t *TRXData) CurrentVFO() tci.VFO {
-	return t.currentVFO
+func (t *TRXData) updateVFO(vfo tci.VFO, f func(*VFOState)) {
+	if !validVFO(vfo) {
+		return
+	}
+	t.update(func(s *TRXState) {
+		f(&s.VFOs[vfo])
+	})
 }
 
 func (t *TRXData) SetVFOFrequency(trx int, vfo tci.VFO, frequency int) {
 	if trx != t.trx {
 		return
 	}
-	data := t.vfos[vfo]
-	data.frequency = frequency
-	t.vfos[vfo] = data
+	t.updateVFO(vfo, func(v *VFOState) {
+		v.Frequency = frequency
+	})
 }
 
 func (t *TRXData) VFOFrequency(vfo tci.VFO) int {
-	data := t.vfos[vfo]
-	return data.frequency
-}
-
-func (t *TRXData) CurrentVFOFrequency() int {
-	data := t.vfos[t.currentVFO]
-	return data.frequency
+	return t.Snapshot().VFOFrequency(vfo)
 }
 
 func (t *TRXData) SetMode(trx int, mode tci.Mode) {
 	if trx != t.trx {
 		return
 	}
-	t.mode = mode
+	t.update(func(s *TRXState) {
+		s.Mode = mode
+	})
 }
 
 func (t *TRXData) Mode() tci.Mode {
-	return t.mode
+	return t.Snapshot().Mode
 }
 
 func (t *TRXData) SetRXFilterBand(trx int, min, max int) {
 	if trx != t.trx {
 		return
 	}
-	t.rxFilterMin = min
-	t.rxFilterMax = max
+	t.update(func(s *TRXState) {
+		s.RXFilterMin = min
+		s.RXFilterMax = max
+	})
 }
 
 func (t *TRXData) RXFilterBand() (int, int) {
-	return t.rxFilterMin, t.rxFilterMax
+	state := t.Snapshot()
+	return state.RXFilterMin, state.RXFilterMax
 }
 
 func (t *TRXData) SetSplitEnable(trx int, enabled bool) {
 	if trx != t.trx {
 		return
 	}
-	t.splitEnabled = enabled
+	t.update(func(s *TRXState) {
+		s.SplitEnabled = enabled
+	})
 }
 
 func (t *TRXData) SplitEnable() bool {
-	return t.splitEnabled
+	return t.Snapshot().SplitEnabled
+}
+
+func (t *TRXData) SetRITEnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.RITEnabled = enabled
+	})
+}
+
+func (t *TRXData) SetRITOffset(trx int, offset int) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.RITOffset = offset
+	})
+}
+
+// RIT returns the effective RIT offset, which is 0 if RIT is disabled.
+func (t *TRXData) RIT() int {
+	return t.Snapshot().RIT()
+}
+
+func (t *TRXData) SetXITEnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.XITEnabled = enabled
+	})
+}
+
+func (t *TRXData) SetXITOffset(trx int, offset int) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.XITOffset = offset
+	})
+}
+
+// XIT returns the effective XIT offset, which is 0 if XIT is disabled.
+func (t *TRXData) XIT() int {
+	return t.Snapshot().XIT()
+}
+
+func (t *TRXData) SetRXSMeter(trx int, vfo tci.VFO, level int) {
+	if trx != t.trx {
+		return
+	}
+	t.updateVFO(vfo, func(v *VFOState) {
+		v.SMeter = level
+		v.SMeterSet = true
+	})
+}
+
+// SetRXSensors keeps the signal strength of the TRX apart from the S-meters of the VFOs.
+func (t *TRXData) SetRXSensors(trx int, dBm float64) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.RXSensor = int(math.Round(dBm))
+	})
+}
+
+// SMeter returns the signal strength of the given VFO in dBm, see TRXState.SMeter.
+func (t *TRXData) SMeter(vfo tci.VFO) int {
+	return t.Snapshot().SMeter(vfo)
+}
+
+// SetDrive applies the global drive to the TRX, unless the TCI server reported a drive for this TRX.
+func (t *TRXData) SetDrive(percent int) {
+	t.update(func(s *TRXState) {
+		if !s.TRXDriveSet {
+			s.Drive = percent
+		}
+	})
+}
+
+func (t *TRXData) SetTRXDrive(trx int, percent int) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.Drive = percent
+		s.TRXDriveSet = true
+	})
+}
+
+// Drive returns the output power in percent.
+func (t *TRXData) Drive() int {
+	return t.Snapshot().Drive
+}
+
+func (t *TRXData) SetVolume(dB int) {
+	t.update(func(s *TRXState) {
+		s.MainVolume = dB
+	})
+}
+
+func (t *TRXData) SetRXVolume(trx int, vfo tci.VFO, dB int) {
+	if trx != t.trx {
+		return
+	}
+	t.updateVFO(vfo, func(v *VFOState) {
+		v.RXVolume = dB
+		v.RXVolumeSet = true
+	})
+}
+
+// Volume returns the volume of the given VFO in dB. If the TCI server does not report the volume of the
+// VFO, the main volume is used.
+func (t *TRXData) Volume(vfo tci.VFO) int {
+	return t.Snapshot().Volume(vfo)
+}
+
+// HasRXVolume indicates if the TCI server reports the volume of the given VFO.
+func (t *TRXData) HasRXVolume(vfo tci.VFO) bool {
+	return t.Snapshot().vfo(vfo).RXVolumeSet
+}
+
+func (t *TRXData) SetSquelchLevel(dB int) {
+	t.update(func(s *TRXState) {
+		s.SquelchLevel = dB
+	})
+}
+
+// SquelchLevel returns the squelch threshold in dB.
+func (t *TRXData) SquelchLevel() int {
+	return t.Snapshot().SquelchLevel
+}
+
+// CWPitch returns the CW pitch in Hz.
+func (t *TRXData) CWPitch() int {
+	return t.Snapshot().CWPitch
+}
+
+func (t *TRXData) SetTXPower(watts float64) {
+	t.update(func(s *TRXState) {
+		s.TXPower = watts
+	})
+}
+
+// TXPower returns the current output power in W.
+func (t *TRXData) TXPower() float64 {
+	return t.Snapshot().TXPower
+}
+
+func (t *TRXData) SetTXSWR(ratio float64) {
+	t.update(func(s *TRXState) {
+		s.TXSWR = ratio
+	})
+}
+
+func (t *TRXData) TXSWR() float64 {
+	return t.Snapshot().TXSWR
+}
+
+func (t *TRXData) SetRXNBEnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.NBEnabled = enabled
+	})
+}
+
+func (t *TRXData) RXNBEnable() bool {
+	return t.Snapshot().NBEnabled
+}
+
+func (t *TRXData) SetRXNREnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.NREnabled = enabled
+	})
+}
+
+func (t *TRXData) RXNREnable() bool {
+	return t.Snapshot().NREnabled
+}
+
+func (t *TRXData) SetRXANFEnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.ANFEnabled = enabled
+	})
+}
+
+func (t *TRXData) RXANFEnable() bool {
+	return t.Snapshot().ANFEnabled
+}
+
+func (t *TRXData) SetRXAPFEnable(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.APFEnabled = enabled
+	})
+}
+
+func (t *TRXData) RXAPFEnable() bool {
+	return t.Snapshot().APFEnabled
+}
+
+func (t *TRXData) SetRXMute(trx int, muted bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.RXMuted = muted
+	})
+}
+
+// Mute indicates if the TRX is muted.
+func (t *TRXData) Mute() bool {
+	return t.Snapshot().Mute()
+}
+
+// Lock indicates if the VFOs of the TRX are locked on the TCI server.
+func (t *TRXData) Lock() bool {
+	return t.Snapshot().Lock()
+}
+
+func (t *TRXData) SetTune(trx int, enabled bool) {
+	if trx != t.trx {
+		return
+	}
+	t.update(func(s *TRXState) {
+		s.Tuning = enabled
+	})
+}
+
+func (t *TRXData) Tune() bool {
+	return t.Snapshot().Tuning
+}
+
+// Message handles the TCI messages that are not supported by the TCI client library.
+func (t *TRXData) Message(msg tci.Message) {
+	switch msg.Name() {
+	case "lock":
+		trx, err := msg.ToInt(0)
+		if err != nil || trx != t.trx {
+			return
+		}
+		locked, err := msg.ToBool(1)
+		if err != nil {
+			return
+		}
+		t.update(func(s *TRXState) {
+			s.Locked = locked
+		})
+	case "cw_macros":
+		trx, err := msg.ToInt(0)
+		if err != nil || trx != t.trx {
+			return
+		}
+		t.update(func(s *TRXState) {
+			s.SendingCW = true
+		})
+	case "cw_macros_stop":
+		t.CWMacrosEmpty()
+	case "cw_pitch":
+		// the CW pitch applies either to all TRX (cw_pitch:600) or to one TRX (cw_pitch:0,600)
+		trx := t.trx
+		pitchArg := 0
+		if len(msg.Args()) > 1 {
+			var err error
+			trx, err = msg.ToInt(0)
+			if err != nil {
+				return
+			}
+			pitchArg = 1
+		}
+		pitch, err := msg.ToInt(pitchArg)
+		if err != nil || trx != t.trx || pitch <= 0 {
+			return
+		}
+		t.update(func(s *TRXState) {
+			s.CWPitch = pitch
+		})
+	}
+}
+
+// CWMacrosEmpty implements the tci.CWMacrosEmptyListener interface.
+func (t *TRXData) CWMacrosEmpty() {
+	t.update(func(s *TRXState) {
+		s.SendingCW = false
+	})
+}
+
+// SetProtocol implements the tci.ProtocolListener interface, the protocol version tells if the TCI server announces
+// the end of the CW macros.
+func (t *TRXData) SetProtocol(name string, version string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.cwMacrosEmptyAnnounced = protocolAnnouncesCWMacrosEmpty(version)
+}
+
+// SendingCW indicates if the TCI server is still sending a CW macro. The TCI server announces the end of a CW macro
+// since TCI 1.5, with older versions the CW macro is taken as sent when the transmission ends.
+func (t *TRXData) SendingCW() bool {
+	return t.Snapshot().SendingCW
 }
 
+// SetTX keeps track of the transmission state. Repeated notifications with the same state are ignored,
+// e.g. when the TCI server sends its state again after a reconnect.
 func (t *TRXData) SetTX(trx int, enabled bool) {
 	if trx != t.trx {
 		return
 	}
-	if t.transmitting == enabled {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state.Transmitting == enabled {
 		return
 	}
-	t.transmitting = enabled
+	t.state.Transmitting = enabled
 	if enabled {
-		t.txSync.Add(1)
+		t.txDone = make(chan struct{})
 	} else {
-		t.txSync.Done()
+		if !t.cwMacrosEmptyAnnounced {
+			t.state.SendingCW = false
+		}
+		close(t.txDone)
 	}
+	t.notifySubscribers()
 }
 
 func (t *TRXData) TX() bool {
-	return t.transmitting
+	return t.Snapshot().Transmitting
 }
 
+// WaitForTransmissionEnd blocks until the current transmission ends. It returns immediately if the TRX
+// does not transmit.
 func (t *TRXData) WaitForTransmissionEnd() {
-	t.txSync.Wait()
+	t.mutex.RLock()
+	txDone := t.txDone
+	t.mutex.RUnlock()
+	<-txDone
 }
@@ -0,0 +1,249 @@
+package adapter
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTRXData_IgnoresOtherTRX(t *testing.T) {
+	trxData := newTRXData(1)
+
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetMode(0, tci.ModeCW)
+	trxData.SetVFOFrequency(1, tci.VFOA, 14074000)
+
+	assert.Equal(t, 14074000, trxData.VFOFrequency(tci.VFOA))
+	assert.Equal(t, tci.Mode(""), trxData.Mode())
+}
+
+func TestTRXData_IgnoresInvalidVFO(t *testing.T) {
+	trxData := newTRXData(0)
+
+	trxData.SetVFOFrequency(0, tci.VFO(2), 7074000)
+
+	assert.Equal(t, 0, trxData.VFOFrequency(tci.VFO(2)))
+	assert.Equal(t, 0, trxData.VFOFrequency(tci.VFOA))
+}
+
+func TestTRXData_GlobalDrive(t *testing.T) {
+	trxData := newTRXData(1)
+
+	trxData.SetDrive(40)
+	assert.Equal(t, 40, trxData.Drive(), "global drive without TRX drive")
+
+	trxData.SetTRXDrive(1, 60)
+	trxData.SetDrive(30)
+	assert.Equal(t, 60, trxData.Drive(), "the TRX drive wins")
+}
+
+func TestTRXData_Mute(t *testing.T) {
+	trxData := newTRXData(1)
+
+	assert.False(t, trxData.Mute())
+
+	trxData.SetRXMute(1, true)
+	assert.True(t, trxData.Mute())
+
+	trxData.SetRXMute(1, false)
+	assert.False(t, trxData.Mute())
+}
+
+func TestTRXData_RXSensors(t *testing.T) {
+	trxData := newTRXData(0)
+
+	trxData.SetRXSensors(0, -90.4)
+	assert.Equal(t, -90, trxData.SMeter(tci.VFOA), "RX sensor without S-meter")
+
+	trxData.SetRXSMeter(0, tci.VFOA, -70)
+	trxData.SetRXSensors(0, -100)
+	assert.Equal(t, -70, trxData.SMeter(tci.VFOA), "the S-meter wins")
+	assert.Equal(t, -100, trxData.SMeter(tci.VFOB))
+}
+
+func TestTRXData_SnapshotIsACopy(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+
+	snapshot := trxData.Snapshot()
+	trxData.SetVFOFrequency(0, tci.VFOA, 14074000)
+
+	assert.Equal(t, 7074000, snapshot.VFOFrequency(tci.VFOA))
+	assert.Equal(t, 14074000, trxData.VFOFrequency(tci.VFOA))
+}
+
+func TestTRXData_RepeatedTXOff(t *testing.T) {
+	trxData := newTRXData(0)
+
+	assert.NotPanics(t, func() {
+		trxData.SetTX(0, false)
+		trxData.SetTX(0, true)
+		trxData.SetTX(0, true)
+		trxData.SetTX(0, false)
+		trxData.SetTX(0, false)
+	})
+	trxData.WaitForTransmissionEnd()
+}
+
+func TestTRXData_SendingCW(t *testing.T) {
+	tt := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.4", false},
+		{"1.5", true},
+		{"1.9", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.version, func(t *testing.T) {
+			data := newTRXData(0)
+			data.SetProtocol("ExpertSDR3", tc.version)
+			data.SetTX(0, true)
+			data.Message(tci.NewCommandMessage("cw_macros", 0, "CQ"))
+
+			data.SetTX(0, false)
+
+			assert.Equal(t, tc.expected, data.SendingCW(), "sending CW after the TX ends")
+			data.CWMacrosEmpty()
+			assert.False(t, data.SendingCW())
+		})
+	}
+}
+
+func TestTRXData_WaitForTransmissionEnd(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetTX(0, true)
+
+	waitReturned := make(chan struct{})
+	go func() {
+		trxData.WaitForTransmissionEnd()
+		close(waitReturned)
+	}()
+
+	select {
+	case <-waitReturned:
+		require.Fail(t, "wait returned while transmitting")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	trxData.SetTX(0, false)
+
+	select {
+	case <-waitReturned:
+	case <-time.After(time.Second):
+		require.Fail(t, "wait did not return after the transmission ended")
+	}
+}
+
+func TestTRXData_ConcurrentNotificationsAndSnapshots(t *testing.T) {
+	const iterations = 1000
+	trxData := newTRXData(0)
+	trxData.SetRXFilterBand(0, 0, 2400)
+
+	var notifier sync.WaitGroup
+	notifier.Add(1)
+	go func() {
+		defer notifier.Done()
+		for i := 0; i < iterations; i++ {
+			trxData.SetVFOFrequency(0, tci.VFOA, 7000000+i)
+			trxData.SetVFOFrequency(0, tci.VFOB, 14000000+i)
+			trxData.SetRXFilterBand(0, i, i+2400)
+			trxData.SetRXSMeter(0, tci.VFOA, -100+i%50)
+			trxData.SetTX(0, i%2 == 0)
+			trxData.SetRITEnable(0, i%3 == 0)
+			trxData.SetRITOffset(0, i%100)
+			trxData.SetTRXDrive(0, i%100)
+			trxData.SetRXVolume(0, tci.VFOA, -(i % 60))
+			trxData.SetSplitEnable(0, i%5 == 0)
+			trxData.Message(tci.NewCommandMessage("lock", 0, i%7 == 0))
+		}
+		trxData.SetTX(0, false)
+	}()
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < iterations; i++ {
+				snapshot := trxData.Snapshot()
+				assert.Equal(t, 2400, snapshot.Passband())
+				trxData.VFOFrequency(tci.VFOB)
+				trxData.SMeter(tci.VFOA)
+				trxData.Volume(tci.VFOA)
+				trxData.RIT()
+				trxData.TX()
+				trxData.Lock()
+			}
+		}()
+	}
+
+	notifier.Wait()
+	readers.Wait()
+	trxData.WaitForTransmissionEnd()
+
+	assert.Equal(t, 7000000+iterations-1, trxData.VFOFrequency(tci.VFOA))
+	assert.Equal(t, 14000000+iterations-1, trxData.VFOFrequency(tci.VFOB))
+	assert.False(t, trxData.TX())
+}
+
+func TestTRXData_ConcurrentNotificationsAndHamlibReads(t *testing.T) {
+	const iterations = 200
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7000000)
+
+	done := make(chan struct{})
+	var notifier sync.WaitGroup
+	notifier.Add(1)
+	go func() {
+		defer notifier.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			trxData.SetVFOFrequency(0, tci.VFOA, 7000000+i%1000)
+			trxData.SetMode(0, tci.ModeUSB)
+			trxData.SetRXFilterBand(0, 100, 2500)
+			trxData.SetRXSMeter(0, tci.VFOA, -73)
+			trxData.SetTX(0, i%2 == 0)
+			runtime.Gosched()
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for c := 0; c < 3; c++ {
+		client := openTestConnection(t, trxData, false)
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for i := 0; i < iterations; i++ {
+				response, err := client.tryRequest("f")
+				if !assert.NoError(t, err) {
+					return
+				}
+				frequency, err := strconv.Atoi(response)
+				assert.NoError(t, err)
+				assert.GreaterOrEqual(t, frequency, 7000000)
+				assert.Less(t, frequency, 7001000)
+				for _, request := range []string{"t", "l STRENGTH"} {
+					_, err = client.tryRequest(request)
+					if !assert.NoError(t, err, request) {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	clients.Wait()
+	close(done)
+	notifier.Wait()
+}
@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"strconv"
+	"sync"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// announcedDevice keeps the device info that the TCI server announces on each connection and checks the configured
+// mode rules against the announced modulations list. The device info is kept by the adapter, because the TCI client
+// updates its own device info without synchronization.
+type announcedDevice struct {
+	adapter *Adapter
+	mutex   sync.RWMutex
+	info    tci.DeviceInfo
+}
+
+// get returns a copy of the announced device info.
+func (d *announcedDevice) get() tci.DeviceInfo {
+	if d == nil {
+		return tci.DeviceInfo{}
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.info
+}
+
+// modes returns the announced modes, or nil if the TCI server did not announce its modes yet.
+func (d *announcedDevice) modes() []tci.Mode {
+	return d.get().Modes
+}
+
+// announcesCWMacrosEmpty indicates if the TCI server announces the end of the CW macros, see
+// protocolAnnouncesCWMacrosEmpty.
+func (d *announcedDevice) announcesCWMacrosEmpty() bool {
+	return protocolAnnouncesCWMacrosEmpty(d.get().ProtocolVersion)
+}
+
+// protocolAnnouncesCWMacrosEmpty indicates if a TCI server with the given protocol version announces the end of the
+// CW macros with cw_macros_empty, this is the case since TCI 1.5.
+func protocolAnnouncesCWMacrosEmpty(version string) bool {
+	value, err := strconv.ParseFloat(version, 64)
+	return err == nil && value >= 1.5
+}
+
+func (d *announcedDevice) update(f func(*tci.DeviceInfo)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	f(&d.info)
+}
+
+func (d *announcedDevice) SetDeviceName(name string) {
+	d.update(func(info *tci.DeviceInfo) { info.SetDeviceName(name) })
+}
+
+func (d *announcedDevice) SetProtocol(name string, version string) {
+	d.update(func(info *tci.DeviceInfo) { info.SetProtocol(name, version) })
+}
+
+func (d *announcedDevice) SetVFOLimits(min, max int) {
+	d.update(func(info *tci.DeviceInfo) { info.SetVFOLimits(min, max) })
+}
+
+func (d *announcedDevice) SetIFLimits(min, max int) {
+	d.update(func(info *tci.DeviceInfo) { info.SetIFLimits(min, max) })
+}
+
+func (d *announcedDevice) SetTRXCount(count int) {
+	d.update(func(info *tci.DeviceInfo) { info.SetTRXCount(count) })
+}
+
+func (d *announcedDevice) SetChannelCount(count int) {
+	d.update(func(info *tci.DeviceInfo) { info.SetChannelCount(count) })
+}
+
+func (d *announcedDevice) SetRXOnly(value bool) {
+	d.update(func(info *tci.DeviceInfo) { info.SetRXOnly(value) })
+}
+
+func (d *announcedDevice) SetModes(modes []tci.Mode) {
+	modes = append([]tci.Mode{}, modes...)
+	d.update(func(info *tci.DeviceInfo) { info.SetModes(modes) })
+	d.adapter.validateModes(d.adapter.settings.Load(), modes)
+}
@@ -0,0 +1,28 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestE2E_AnnouncedDevice(t *testing.T) {
+	setup := startE2E(t, 0)
+
+	require.Eventually(t, func() bool {
+		return len(setup.adapter.tciDevice.modes()) > 0
+	}, e2eTimeout, e2eTick)
+	device := setup.adapter.tciDevice.get()
+	assert.Equal(t, "SunSDR2PRO", device.DeviceName)
+	assert.Equal(t, 2, device.TRXCount)
+	assert.Equal(t, 30000000, device.MaxVFOFrequency)
+	assert.Equal(t, 48000, device.MaxIFFrequency)
+}
+
+func TestAnnouncedDevice_NotConnected(t *testing.T) {
+	var device *announcedDevice
+
+	assert.Empty(t, device.get().DeviceName)
+	assert.Nil(t, device.modes())
+}
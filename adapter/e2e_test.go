@@ -0,0 +1,310 @@
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/tciadapter/simulator"
+)
+
+const (
+	e2eTimeout = 2 * time.Second
+	e2eTick    = 10 * time.Millisecond
+)
+
+type e2eSetup struct {
+	sdr     *simulator.Server
+	adapter *Adapter
+}
+
+func startE2E(t *testing.T, trx ...int) *e2eSetup {
+	t.Helper()
+	sdr, err := simulator.Start("127.0.0.1:0", simulator.DefaultConfig)
+	require.NoError(t, err)
+
+	trxAddresses := make([]TRXAddress, len(trx))
+	for i, n := range trx {
+		trxAddresses[i] = TRXAddress{TRX: n, LocalAddress: "127.0.0.1:0"}
+	}
+	done := make(chan struct{})
+	adapter, err := Listen(trxAddresses, TCIHostAt(sdr.Addr()), done, Settings{}, "test", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		close(done)
+		sdr.Close()
+		adapter.tciClient.Disconnect()
+	})
+
+	require.Eventually(t, adapter.metrics.connected, e2eTimeout, e2eTick, "no connection to the TCI simulator")
+	return &e2eSetup{sdr: sdr, adapter: adapter}
+}
+
+func (s *e2eSetup) openHamlib(t *testing.T, trx int) *hamlib.Conn {
+	t.Helper()
+	addr := s.adapter.Addr(trx)
+	require.NotNil(t, addr)
+	conn, err := hamlib.Open(addr.(*net.TCPAddr).String())
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func e2eContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), e2eTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestE2E_InitialStateFromHandshake(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	assert.Eventually(t, func() bool {
+		frequency, err := rig.Frequency(e2eContext(t))
+		return err == nil && frequency == 7074000
+	}, e2eTimeout, e2eTick)
+
+	mode, passband, err := rig.ModeAndPassband(e2eContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, hamlib.ModeUSB, mode)
+	assert.Equal(t, hamlib.Frequency(2700), passband)
+}
+
+func TestE2E_SetFrequency(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	err := rig.SetFrequency(e2eContext(t), 14074000)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return setup.sdr.VFOFrequency(0, 0) == 14074000
+	}, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool {
+		frequency, err := rig.Frequency(e2eContext(t))
+		return err == nil && frequency == 14074000
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_FrequencyChangedOnTheSDR(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	setup.sdr.Set("vfo", 0, 0, 3573000)
+
+	assert.Eventually(t, func() bool {
+		frequency, err := rig.Frequency(e2eContext(t))
+		return err == nil && frequency == 3573000
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_SetModeAndPassband(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	err := rig.SetModeAndPassband(e2eContext(t), hamlib.ModeCW, 500)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return setup.sdr.Mode(0) == "cw"
+	}, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool {
+		mode, passband, err := rig.ModeAndPassband(e2eContext(t))
+		return err == nil && mode == hamlib.ModeCW && passband == 500
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_PTT(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	err := rig.SetPTT(e2eContext(t), hamlib.PTTTx)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return setup.sdr.TX(0)
+	}, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool {
+		ptt, err := rig.PTT(e2eContext(t))
+		return err == nil && ptt == hamlib.PTTTx
+	}, e2eTimeout, e2eTick)
+
+	err = rig.SetPTT(e2eContext(t), hamlib.PTTRx)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return !setup.sdr.TX(0)
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_Split(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	err := rig.Set(e2eContext(t), "set_split_vfo", "1", "VFOB")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return setup.sdr.SplitEnable(0)
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_SplitTXVFO(t *testing.T) {
+	setup := startE2E(t, 0)
+	tcpConn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { tcpConn.Close() })
+	conn := &testClient{conn: tcpConn, reader: bufio.NewReader(tcpConn)}
+
+	assert.Equal(t, "RPRT -16", conn.request(t, `S 1 VFOA`))
+	assert.Equal(t, "RPRT -16", conn.request(t, `S 1 Main`))
+	assert.Equal(t, "RPRT -16", conn.request(t, `S 1 VFOC`))
+	assert.False(t, setup.sdr.SplitEnable(0))
+
+	assert.Equal(t, "RPRT 0", conn.request(t, `S 1 Sub`))
+	assert.Eventually(t, func() bool {
+		return setup.sdr.SplitEnable(0)
+	}, e2eTimeout, e2eTick)
+
+	assert.Equal(t, "RPRT 0", conn.request(t, `S 0 VFOA`))
+	assert.Eventually(t, func() bool {
+		return !setup.sdr.SplitEnable(0)
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_SendMorse(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	err := rig.SendMorse(e2eContext(t), "CQ TEST DL1ABC")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		macros := setup.sdr.CWMacros()
+		return len(macros) == 1 && macros[0] == "CQ TEST DL1ABC"
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_MultipleTRX(t *testing.T) {
+	setup := startE2E(t, 0, 1)
+	rig0 := setup.openHamlib(t, 0)
+	rig1 := setup.openHamlib(t, 1)
+
+	require.NoError(t, rig0.SetFrequency(e2eContext(t), 7030000))
+	require.NoError(t, rig1.SetFrequency(e2eContext(t), 21074000))
+
+	assert.Eventually(t, func() bool {
+		return setup.sdr.VFOFrequency(0, 0) == 7030000 && setup.sdr.VFOFrequency(1, 0) == 21074000
+	}, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool {
+		frequency, err := rig1.Frequency(e2eContext(t))
+		return err == nil && frequency == 21074000
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_FrequencyOutsideOfTheVFOLimits(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	require.NoError(t, rig.SetFrequency(e2eContext(t), 50313000))
+	require.NoError(t, rig.SetFrequency(e2eContext(t), 14074000))
+
+	assert.Eventually(t, func() bool {
+		return setup.sdr.VFOFrequency(0, 0) == 14074000
+	}, e2eTimeout, e2eTick)
+	frequency, err := rig.Frequency(e2eContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, hamlib.Frequency(14074000), frequency)
+}
+
+func TestE2E_SwitchTCIHost(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	otherSDR, err := simulator.Start("127.0.0.1:0", simulator.DefaultConfig)
+	require.NoError(t, err)
+	t.Cleanup(otherSDR.Close)
+	otherSDR.Set("vfo", 0, 0, 3573000)
+
+	setup.adapter.SetTCIHost(TCIHostAt(otherSDR.Addr()))
+
+	assert.Eventually(t, func() bool {
+		frequency, err := rig.Frequency(e2eContext(t))
+		return err == nil && frequency == 3573000
+	}, e2eTimeout, e2eTick)
+	err = rig.SetFrequency(e2eContext(t), 14074000)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return otherSDR.VFOFrequency(0, 0) == 14074000
+	}, e2eTimeout, e2eTick)
+	assert.Equal(t, 7074000, setup.sdr.VFOFrequency(0, 0))
+}
+
+func TestE2E_RebindKeepsOpenConnections(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	oldAddr := setup.adapter.Addr(0).String()
+
+	err := setup.adapter.Rebind([]TRXAddress{{TRX: 0, LocalAddress: "127.0.0.1:0"}})
+	require.NoError(t, err)
+
+	assert.NotEqual(t, oldAddr, setup.adapter.Addr(0).String())
+	_, err = net.Dial("tcp", oldAddr)
+	assert.Error(t, err)
+	otherRig := setup.openHamlib(t, 0)
+	err = otherRig.SetFrequency(e2eContext(t), 14074000)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		frequency, err := rig.Frequency(e2eContext(t))
+		return err == nil && frequency == 14074000
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_ReconfigureOpenConnection(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	settings := setup.adapter.Settings()
+	settings.NoDigimodes = true
+	setup.adapter.Reconfigure(settings)
+
+	err := rig.SetModeAndPassband(e2eContext(t), hamlib.ModePKTUSB, 0)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return setup.sdr.Mode(0) == "usb"
+	}, e2eTimeout, e2eTick)
+}
+
+func TestE2E_ModeRules(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	settings := setup.adapter.Settings()
+	settings.Modes = []ModeRule{
+		{Hamlib: hamlib.ModeCWR, TCI: tci.ModeCW, Reverse: true},
+		{Hamlib: hamlib.ModeFAX, TCI: tci.ModeSPEC},
+	}
+	setup.adapter.Reconfigure(settings)
+
+	err := rig.SetModeAndPassband(e2eContext(t), hamlib.ModeCWR, 500)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		min, max := setup.sdr.RXFilterBand(0)
+		return setup.sdr.Mode(0) == "cw" && min < 0 && max <= 0
+	}, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool {
+		mode, passband, err := rig.ModeAndPassband(e2eContext(t))
+		return err == nil && mode == hamlib.ModeCWR && passband == 500
+	}, e2eTimeout, e2eTick)
+
+	err = rig.SetModeAndPassband(e2eContext(t), hamlib.ModeFAX, 0)
+	assert.Error(t, err, "not in the modulations list")
+	err = rig.SetModeAndPassband(e2eContext(t), hamlib.Mode("XYZ"), 0)
+	assert.Error(t, err, "unknown mode")
+	assert.Equal(t, "cw", setup.sdr.Mode(0))
+}
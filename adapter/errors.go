@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+	tci "github.com/ftl/tci/client"
+)
+
+// resultCode is the result of a Hamlib request as reported in the RPRT line. The error codes are the negative values
+// of the error codes defined in Hamlib's rig.h.
+type resultCode int
+
+const (
+	resultOK             resultCode = 0
+	resultInvalid        resultCode = -1  // RIG_EINVAL: invalid parameter
+	resultNotImplemented resultCode = -4  // RIG_ENIMPL: function not implemented
+	resultTimeout        resultCode = -5  // RIG_ETIMEOUT: communication timed out
+	resultIO             resultCode = -6  // RIG_EIO: IO error
+	resultInternal       resultCode = -7  // RIG_EINTERNAL: internal error
+	resultRejected       resultCode = -9  // RIG_ERJCTED: command rejected by the rig
+	resultNotAvailable   resultCode = -11 // RIG_ENAVAIL: function not available
+	resultInvalidVFO     resultCode = -16 // RIG_EVFO: invalid VFO
+	resultSecurity       resultCode = -19 // RIG_ESECURITY: security error
+)
+
+func (c resultCode) String() string {
+	return strconv.Itoa(int(c))
+}
+
+// requestError is the error of a Hamlib request together with the result code that is reported to the client.
+type requestError struct {
+	code resultCode
+	err  error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func newRequestError(code resultCode, format string, args ...any) error {
+	return &requestError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// errNoArguments is returned if a request misses some of its arguments.
+var errNoArguments = newRequestError(resultInvalid, "no arguments")
+
+// invalidArgument returns an error for an argument that cannot be parsed or is out of range.
+func invalidArgument(format string, args ...any) error {
+	return newRequestError(resultInvalid, format, args...)
+}
+
+// invalidVFO returns an error for a VFO that is unknown to the adapter.
+func invalidVFO(vfo string) error {
+	return newRequestError(resultInvalidVFO, "unknown VFO %s", vfo)
+}
+
+// rejected returns an error for a request that is valid, but cannot be executed in the current state of the TRX.
+func rejected(format string, args ...any) error {
+	return newRequestError(resultRejected, format, args...)
+}
+
+// notAvailable returns an error for a request that is valid, but not supported by the TCI host.
+func notAvailable(format string, args ...any) error {
+	return newRequestError(resultNotAvailable, format, args...)
+}
+
+// tciCommandFailed returns an error for a TCI command that could not be sent.
+func tciCommandFailed(err error) error {
+	return &requestError{code: resultIO, err: fmt.Errorf("cannot send TCI command: %w", err)}
+}
+
+// resultOf returns the result code for the given error of a request. TCI servers do not confirm every set command,
+// so a timeout of a set command is reported as success.
+func resultOf(key protocol.CommandKey, err error) resultCode {
+	if err == nil {
+		return resultOK
+	}
+	if errors.Is(err, tci.ErrTimeout) {
+		if strings.HasPrefix(string(key), "set_") {
+			return resultOK
+		}
+		return resultTimeout
+	}
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		return requestErr.code
+	}
+	if errors.Is(err, tci.ErrNotConnected) {
+		return resultIO
+	}
+	return resultInternal
+}
+
+func resultResponse(cmd protocol.CommandKey, result resultCode) protocol.Response {
+	return protocol.Response{Command: cmd, Result: result.String()}
+}
+
+func notImplementedResponse(cmd protocol.CommandKey) protocol.Response {
+	return resultResponse(cmd, resultNotImplemented)
+}
@@ -0,0 +1,113 @@
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultOf(t *testing.T) {
+	tt := []struct {
+		name     string
+		key      protocol.CommandKey
+		err      error
+		expected resultCode
+	}{
+		{"no error", "set_freq", nil, resultOK},
+		{"timeout of a set command", "set_freq", fmt.Errorf("set_freq: %w", tciCommandFailed(tci.ErrTimeout)), resultOK},
+		{"timeout of a get command", "get_level", fmt.Errorf("get_level: %w", tciCommandFailed(tci.ErrTimeout)), resultTimeout},
+		{"not connected", "set_freq", fmt.Errorf("set_freq: %w", tciCommandFailed(tci.ErrNotConnected)), resultIO},
+		{"unwrapped not connected", "set_freq", tci.ErrNotConnected, resultIO},
+		{"no arguments", "set_freq", fmt.Errorf("set_freq: %w", errNoArguments), resultInvalid},
+		{"invalid argument", "set_ptt", invalidArgument("invalid PTT %s", "5"), resultInvalid},
+		{"invalid VFO", "set_vfo", invalidVFO("VFOC"), resultInvalidVFO},
+		{"rejected", "set_freq", rejected("VFO is locked"), resultRejected},
+		{"not available", "set_mode", notAvailable("the TCI host does not support mode %s", "SPEC"), resultNotAvailable},
+		{"other error", "get_freq", errors.New("something went wrong"), resultInternal},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, resultOf(tc.key, tc.err))
+		})
+	}
+}
+
+func TestRequestErrorKeepsMessage(t *testing.T) {
+	err := fmt.Errorf("set_mode: %w", invalidArgument("invalid passband: %w", errors.New("not a number")))
+
+	assert.Equal(t, "set_mode: invalid passband: not a number", err.Error())
+}
+
+func TestHandleRequest_Results(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Modes: []ModeRule{{Hamlib: "FAX", TCI: tci.ModeSPEC}}})
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	// the requests are sent in this order on the same connection
+	tt := []struct {
+		request  string
+		expected string
+	}{
+		{`F`, "RPRT -1"},
+		{`F abc`, "RPRT -1"},
+		{`F 14074000`, "RPRT 0"},
+		{`V VFOC`, "RPRT -16"},
+		{`V VFOB`, "RPRT 0"},
+		{`V VFOA`, "RPRT 0"},
+		{`M XYZ 0`, "RPRT -1"},
+		{`M USB abc`, "RPRT -1"},
+		{`M FAX 0`, "RPRT -11"},
+		{`M USB 0`, "RPRT 0"},
+		{`X XYZ 0`, "RPRT -1"},
+		{`T 5`, "RPRT -1"},
+		{`T 1`, "RPRT 0"},
+		{`T 0`, "RPRT 0"},
+		{`\set_lock_mode 1`, "RPRT 0"},
+		{`\get_lock_mode`, "1"},
+		{`\set_trn FOO`, "RPRT -1"},
+		{`L AF abc`, "RPRT -1"},
+		{`L FOO 1`, "RPRT -4"},
+		{`l FOO`, "RPRT -4"},
+		{`U FOO 1`, "RPRT -4"},
+		{`U NB foo`, "RPRT -1"},
+		{`U NB 1`, "RPRT 0"},
+		{`U LOCK 1`, "RPRT -11"},
+		{`S foo VFOB`, "RPRT -1"},
+		{`\set_vfo_opt 2`, "RPRT -1"},
+		{"#lock", ""},
+		{`u LOCK`, "1"},
+		{`F 7074000`, "RPRT -9"},
+		{`I 7074000`, "RPRT -9"},
+		{"#unlock", ""},
+		{`F 7074000`, "RPRT 0"},
+	}
+	for _, tc := range tt {
+		switch tc.request {
+		case "#lock", "#unlock":
+			// the VFOs can only be locked on the TCI server
+			locked := tc.request == "#lock"
+			setup.sdr.Set("lock", 0, locked)
+			require.Eventually(t, func() bool {
+				return setup.adapter.trxListeners[0].trxData.Lock() == locked
+			}, e2eTimeout, e2eTick)
+			continue
+		}
+		_, err := fmt.Fprintln(conn, tc.request)
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(e2eTimeout))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err, tc.request)
+		assert.Equal(t, tc.expected, line[:len(line)-1], tc.request)
+	}
+}
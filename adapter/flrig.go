@@ -0,0 +1,582 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// flrigVersion is the FLRig version that is reported to the clients. Some clients enable features depending on
+// the version.
+const flrigVersion = "1.4.7"
+
+// flrigMaxRequestSize is the maximum size of an XML-RPC request in bytes.
+const flrigMaxRequestSize = 64 * 1024
+
+// flrigModes lists the TCI modes in the order in which they are reported through rig.get_modes.
+var flrigModes = []tci.Mode{
+	tci.ModeLSB,
+	tci.ModeUSB,
+	tci.ModeCW,
+	tci.ModeAM,
+	tci.ModeSAM,
+	tci.ModeDSB,
+	tci.ModeNFM,
+	tci.ModeWFM,
+	tci.ModeDIGL,
+	tci.ModeDIGU,
+	tci.ModeDRM,
+	tci.ModeSPEC,
+}
+
+// flrigBandwidths lists the passbands in Hz that are reported through rig.get_bws.
+var flrigBandwidths = []int{50, 100, 200, 300, 400, 500, 600, 800, 1000, 1200, 1500, 1800, 2100, 2400, 2700, 3000, 3500, 4000, 5000, 6000, 8000, 10000, 12000}
+
+type flrigMethod func(s *flrigServer, params []any) (any, error)
+
+// flrigMethods maps the supported methods of FLRig's XML-RPC interface onto the TCI client and the TRX state.
+var flrigMethods = map[string]flrigMethod{
+	"main.get_version": func(s *flrigServer, params []any) (any, error) {
+		return flrigVersion, nil
+	},
+	"rig.get_xcvr": func(s *flrigServer, params []any) (any, error) {
+		return s.deviceName(), nil
+	},
+	"rig.get_info": (*flrigServer).getInfo,
+	"rig.get_vfo": func(s *flrigServer, params []any) (any, error) {
+		return strconv.Itoa(s.trxData.VFOFrequency(s.getCurrentVFO())), nil
+	},
+	"rig.get_vfoA": func(s *flrigServer, params []any) (any, error) {
+		return strconv.Itoa(s.trxData.VFOFrequency(tci.VFOA)), nil
+	},
+	"rig.get_vfoB": func(s *flrigServer, params []any) (any, error) {
+		return strconv.Itoa(s.trxData.VFOFrequency(tci.VFOB)), nil
+	},
+	"rig.set_vfo": func(s *flrigServer, params []any) (any, error) {
+		return s.setFrequency(s.getCurrentVFO(), params)
+	},
+	"rig.set_frequency": func(s *flrigServer, params []any) (any, error) {
+		return s.setFrequency(s.getCurrentVFO(), params)
+	},
+	"rig.set_vfoA": func(s *flrigServer, params []any) (any, error) {
+		return s.setFrequency(tci.VFOA, params)
+	},
+	"rig.set_vfoB": func(s *flrigServer, params []any) (any, error) {
+		return s.setFrequency(tci.VFOB, params)
+	},
+	"rig.get_AB": func(s *flrigServer, params []any) (any, error) {
+		if s.getCurrentVFO() == tci.VFOB {
+			return "B", nil
+		}
+		return "A", nil
+	},
+	"rig.set_AB": func(s *flrigServer, params []any) (any, error) {
+		vfo, err := stringParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(vfo) {
+		case "A":
+			s.currentVFO.Store(int32(tci.VFOA))
+		case "B":
+			s.currentVFO.Store(int32(tci.VFOB))
+		default:
+			return nil, fmt.Errorf("invalid VFO %s", vfo)
+		}
+		return nil, nil
+	},
+	"rig.get_mode":  (*flrigServer).getMode,
+	"rig.get_modeA": (*flrigServer).getMode,
+	"rig.get_modeB": (*flrigServer).getMode,
+	"rig.set_mode":  (*flrigServer).setMode,
+	"rig.set_modeA": (*flrigServer).setMode,
+	"rig.set_modeB": (*flrigServer).setMode,
+	"rig.get_modes": (*flrigServer).getModes,
+	"rig.get_sideband": func(s *flrigServer, params []any) (any, error) {
+		switch s.trxData.Mode() {
+		case tci.ModeLSB, tci.ModeDIGL:
+			return "L", nil
+		default:
+			return "U", nil
+		}
+	},
+	"rig.get_bw":  (*flrigServer).getBandwidth,
+	"rig.get_bwA": (*flrigServer).getBandwidth,
+	"rig.get_bwB": (*flrigServer).getBandwidth,
+	"rig.set_bw":  (*flrigServer).setBandwidth,
+	"rig.set_bwA": (*flrigServer).setBandwidth,
+	"rig.set_bwB": (*flrigServer).setBandwidth,
+	"rig.get_bws": func(s *flrigServer, params []any) (any, error) {
+		bandwidths := []string{"Bandwidth"}
+		for _, bandwidth := range flrigBandwidths {
+			bandwidths = append(bandwidths, strconv.Itoa(bandwidth))
+		}
+		return []any{bandwidths}, nil
+	},
+	"rig.get_ptt": func(s *flrigServer, params []any) (any, error) {
+		return flrigBool(s.trxData.TX()), nil
+	},
+	"rig.set_ptt":      (*flrigServer).setPTT,
+	"rig.set_ptt_fast": (*flrigServer).setPTT,
+	"rig.get_split": func(s *flrigServer, params []any) (any, error) {
+		return flrigBool(s.trxData.SplitEnable()), nil
+	},
+	"rig.set_split": func(s *flrigServer, params []any) (any, error) {
+		enabled, err := intParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = s.checkTXChange(func(state *TRXState) { state.SplitEnabled = enabled != 0 })
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.tciClient.SetSplitEnable(s.trxData.trx, enabled != 0)
+	},
+	"rig.get_power": func(s *flrigServer, params []any) (any, error) {
+		return s.trxData.Drive(), nil
+	},
+	"rig.set_power": func(s *flrigServer, params []any) (any, error) {
+		percent, err := intParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		percent = min(max(percent, 0), 100)
+		err = s.checkTXChange(func(state *TRXState) { state.Drive = percent })
+		if err != nil {
+			return nil, err
+		}
+		return nil, setDrive(s.tciClient, s.trxData.trx, percent)
+	},
+	"rig.get_pwrmeter": func(s *flrigServer, params []any) (any, error) {
+		return int(math.Round(s.trxData.TXPower())), nil
+	},
+	"rig.get_smeter": func(s *flrigServer, params []any) (any, error) {
+		// FLRig's S-meter scale is 0..100, S9 is at 50
+		smeter := s.trxData.SMeter(s.getCurrentVFO())
+		return int(math.Round(normalize(smeter, s9Level-54, s9Level+54) * 100)), nil
+	},
+	"rig.get_volume": func(s *flrigServer, params []any) (any, error) {
+		return int(math.Round(normalize(s.trxData.Volume(s.getCurrentVFO()), minVolume, 0) * 100)), nil
+	},
+	"rig.set_volume": func(s *flrigServer, params []any) (any, error) {
+		volume, err := intParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		dB := denormalize(float64(volume)/100, minVolume, 0)
+		vfo := s.getCurrentVFO()
+		if s.trxData.HasRXVolume(vfo) {
+			return nil, s.tciClient.SetRXVolume(s.trxData.trx, vfo, dB)
+		}
+		return nil, s.tciClient.SetVolume(dB)
+	},
+	"rig.cat_string": (*flrigServer).catString,
+}
+
+func init() {
+	flrigMethods["system.listMethods"] = func(s *flrigServer, params []any) (any, error) {
+		result := make([]string, 0, len(flrigMethods))
+		for name := range flrigMethods {
+			result = append(result, name)
+		}
+		sort.Strings(result)
+		return result, nil
+	}
+}
+
+// flrigMethodKeys maps the FLRig methods that change the state of the TRX onto the keys of the corresponding Hamlib
+// requests. The role of a client is checked with these keys. The Kenwood commands of rig.cat_string are checked
+// one by one.
+var flrigMethodKeys = map[string]string{
+	"rig.set_vfo":       "set_freq",
+	"rig.set_frequency": "set_freq",
+	"rig.set_vfoA":      "set_freq",
+	"rig.set_vfoB":      "set_freq",
+	"rig.set_AB":        "set_vfo",
+	"rig.set_mode":      "set_mode",
+	"rig.set_modeA":     "set_mode",
+	"rig.set_modeB":     "set_mode",
+	"rig.set_bw":        "set_mode",
+	"rig.set_bwA":       "set_mode",
+	"rig.set_bwB":       "set_mode",
+	"rig.set_ptt":       "set_ptt",
+	"rig.set_ptt_fast":  "set_ptt",
+	"rig.set_split":     "set_split_vfo",
+	"rig.set_power":     "set_level_rfpower",
+	"rig.set_volume":    "set_level_af",
+}
+
+// ListenFLRig opens the given local address to accept XML-RPC requests that use the interface of FLRig to control
+// the given TRX.
+func (a *Adapter) ListenFLRig(trx int, localAddress string) error {
+	trxData, err := a.trxData(trx)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return fmt.Errorf("cannot open local port %s for FLRig connections: %w", localAddress, err)
+	}
+	log.Printf("listening for FLRig connections to TRX %d on %s", trx, listener.Addr())
+
+	httpServer := &http.Server{Handler: &flrigListener{adapter: a, trxData: trxData, state: &flrigState{}}}
+
+	go func() {
+		<-a.closed
+		httpServer.Close()
+	}()
+	go func() {
+		err := httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("FLRig listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+// flrigListener checks the access lists for each XML-RPC request and handles the request with the settings of the
+// requesting client.
+type flrigListener struct {
+	adapter *Adapter
+	trxData *TRXData
+	state   *flrigState
+}
+
+func (l *flrigListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remoteAddress := parseRemoteAddress(r.RemoteAddr)
+	if !l.adapter.acceptConnection("FLRig", remoteAddress) {
+		http.Error(w, "refused by the access lists", http.StatusForbidden)
+		return
+	}
+	settings := l.adapter.settingsSource(l.trxData.trx, remoteAddress)
+	server := &flrigServer{
+		tciClient:  l.adapter.tciClient,
+		tciDevice:  l.adapter.tciDevice,
+		watchdog:   l.adapter.watchdog,
+		trxData:    l.trxData,
+		settings:   settings,
+		flrigState: l.state,
+		kenwood: &kenwoodConnection{
+			tciClient: l.adapter.tciClient,
+			tciDevice: l.adapter.tciDevice,
+			watchdog:  l.adapter.watchdog,
+			txOwner:   flrigTXOwner,
+			trxData:   l.trxData,
+			settings:  settings,
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// parseRemoteAddress returns the TCP address of the given remote address of an HTTP request, or nil if the address
+// cannot be parsed.
+func parseRemoteAddress(remoteAddress string) net.Addr {
+	addrPort, err := netip.ParseAddrPort(remoteAddress)
+	if err != nil {
+		return nil
+	}
+	return net.TCPAddrFromAddrPort(addrPort)
+}
+
+// flrigState is the state that all FLRig clients of one TRX share.
+type flrigState struct {
+	currentVFO atomic.Int32
+
+	kenwoodLock sync.Mutex
+}
+
+// flrigServer handles one XML-RPC request of an FLRig client.
+type flrigServer struct {
+	tciClient *tci.Client
+	tciDevice *announcedDevice
+	watchdog  *txWatchdog
+	trxData   *TRXData
+	settings  settingsSource
+	kenwood   *kenwoodConnection
+
+	*flrigState
+}
+
+func (s *flrigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+
+	methodName, params, err := readXMLRPCMethodCall(io.LimitReader(r.Body, flrigMaxRequestSize))
+	if err != nil {
+		log.Printf("FLRig request failed: %v", err)
+		w.Write(formatXMLRPCFault(1, err.Error()))
+		return
+	}
+	if s.settings.current().trace && strings.Contains(methodName, ".set_") {
+		log.Printf("< %s %v", methodName, params)
+	}
+
+	method, ok := flrigMethods[methodName]
+	if !ok {
+		log.Printf("unsupported FLRig method: %s", methodName)
+		w.Write(formatXMLRPCFault(1, fmt.Sprintf("unknown method %s", methodName)))
+		return
+	}
+	if key, ok := flrigMethodKeys[methodName]; ok {
+		role := s.settings.current().role
+		if !role.permits(key) {
+			err := fmt.Errorf("FLRig request %s: %w", methodName, notPermitted(role))
+			log.Print(err)
+			w.Write(formatXMLRPCFault(1, err.Error()))
+			return
+		}
+	}
+	result, err := method(s, params)
+	if errors.Is(err, tci.ErrTimeout) && strings.Contains(methodName, ".set_") {
+		err = nil
+	}
+	if err != nil {
+		log.Printf("FLRig request %s failed: %v", methodName, err)
+		w.Write(formatXMLRPCFault(1, err.Error()))
+		return
+	}
+	w.Write(formatXMLRPCResponse(result))
+}
+
+func (s *flrigServer) getCurrentVFO() tci.VFO {
+	return tci.VFO(s.currentVFO.Load())
+}
+
+func (s *flrigServer) deviceName() string {
+	name := s.tciDevice.get().DeviceName
+	if name == "" {
+		return "tciadapter"
+	}
+	return name
+}
+
+func (s *flrigServer) getInfo(params []any) (any, error) {
+	state := s.trxData.Snapshot()
+	vfo := s.getCurrentVFO()
+	name := "A"
+	if vfo == tci.VFOB {
+		name = "B"
+	}
+	ptt := "R"
+	if state.Transmitting {
+		ptt = "T"
+	}
+	var result strings.Builder
+	fmt.Fprintf(&result, "R:%s\n", s.deviceName())
+	fmt.Fprintf(&result, "T:%s\n", ptt)
+	fmt.Fprintf(&result, "F%s:%d\n", name, state.VFOFrequency(vfo))
+	fmt.Fprintf(&result, "M:%s\n", strings.ToUpper(string(state.Mode)))
+	fmt.Fprintf(&result, "L:%d\n", state.RXFilterMin)
+	fmt.Fprintf(&result, "U:%d\n", state.RXFilterMax)
+	return result.String(), nil
+}
+
+func (s *flrigServer) setFrequency(vfo tci.VFO, params []any) (any, error) {
+	frequency, err := floatParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if s.trxData.Lock() {
+		return nil, fmt.Errorf("VFO is locked")
+	}
+	err = s.checkTXChange(func(state *TRXState) { state.VFOs[vfo].Frequency = int(frequency) })
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.tciClient.SetVFOFrequency(s.trxData.trx, vfo, int(frequency))
+}
+
+func (s *flrigServer) getMode(params []any) (any, error) {
+	return strings.ToUpper(string(s.trxData.Mode())), nil
+}
+
+func (s *flrigServer) setMode(params []any) (any, error) {
+	name, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	mode, reverse, err := s.tciMode(name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTXChange(func(state *TRXState) { state.Mode = mode })
+	if err != nil {
+		return nil, err
+	}
+	return nil, setModeKeepingRXFilter(s.tciClient, s.trxData, mode, reverse)
+}
+
+// tciMode returns the TCI mode for the given FLRig mode. The FLRig modes are the TCI modes, they are mapped onto the
+// Hamlib modes to apply the mode rules like for the Hamlib requests. The modes without Hamlib mode are used as they
+// are.
+func (s *flrigServer) tciMode(name string) (tci.Mode, bool, error) {
+	mode, ok := parseFLRigMode(name)
+	if !ok {
+		return tci.ModeNone, false, fmt.Errorf("invalid mode %s", name)
+	}
+	settings := s.settings.current()
+	hamlibMode, ok := tciToHamlibMode[mode]
+	if !ok {
+		mode, err := settings.availableTCIMode(mode, s.tciDevice.modes())
+		return mode, false, err
+	}
+	return settings.tciMode(hamlibMode, s.trxData.VFOFrequency(s.getCurrentVFO()), s.tciDevice.modes())
+}
+
+func (s *flrigServer) getModes(params []any) (any, error) {
+	available := make(map[tci.Mode]bool)
+	for _, mode := range s.tciDevice.modes() {
+		available[mode] = true
+	}
+	noDigimodes := s.settings.current().noDigimodes
+	result := make([]string, 0, len(flrigModes))
+	for _, mode := range flrigModes {
+		if len(available) > 0 && !available[mode] {
+			continue
+		}
+		if noDigimodes && overrideDigimode(mode) != mode {
+			continue
+		}
+		result = append(result, strings.ToUpper(string(mode)))
+	}
+	return result, nil
+}
+
+func (s *flrigServer) getBandwidth(params []any) (any, error) {
+	return []string{strconv.Itoa(s.trxData.Snapshot().Passband()), ""}, nil
+}
+
+func (s *flrigServer) setBandwidth(params []any) (any, error) {
+	passband, err := intParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if passband <= 0 {
+		return nil, fmt.Errorf("invalid bandwidth %d", passband)
+	}
+	min, max := filterBand(s.trxData.Mode(), passband, s.trxData.CWPitch())
+	return nil, s.tciClient.SetRXFilterBand(s.trxData.trx, min, max)
+}
+
+func (s *flrigServer) setPTT(params []any) (any, error) {
+	enabled, err := intParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if enabled != 0 {
+		err = s.checkTX()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = s.tciClient.SetTX(s.trxData.trx, enabled != 0, tci.SignalSourceDefault)
+	s.watchdog.txSent(s.trxData.trx, flrigTXOwner, enabled != 0, err)
+	return nil, err
+}
+
+// checkTX returns an error if the TX policy does not allow FLRig clients to key the TRX in its current state.
+func (s *flrigServer) checkTX() error {
+	return s.settings.current().txPolicy.check(s.trxData.Snapshot(), flrigTXOwner)
+}
+
+// checkTXChange returns an error if the TX policy does not allow FLRig clients to apply the given change while the
+// TRX transmits.
+func (s *flrigServer) checkTXChange(change func(*TRXState)) error {
+	return s.settings.current().txPolicy.checkChange(s.trxData.Snapshot(), flrigTXOwner, change)
+}
+
+// catString executes the given Kenwood CAT commands and returns the concatenated answers.
+func (s *flrigServer) catString(params []any) (any, error) {
+	commands, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.kenwoodLock.Lock()
+	defer s.kenwoodLock.Unlock()
+
+	var result strings.Builder
+	for _, command := range strings.Split(commands, ";") {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		answer, err := s.kenwood.handleCommand(command)
+		if err != nil {
+			log.Printf("Kenwood command %s failed: %v", command, err)
+			answer = kenwoodErrorAnswer
+		}
+		result.WriteString(answer)
+	}
+	return result.String(), nil
+}
+
+func parseFLRigMode(name string) (tci.Mode, bool) {
+	mode := tci.Mode(strings.ToLower(strings.TrimSpace(name)))
+	if mode == "fm" {
+		return tci.ModeNFM, true
+	}
+	for _, flrigMode := range flrigModes {
+		if mode == flrigMode {
+			return mode, true
+		}
+	}
+	return tci.ModeNone, false
+}
+
+func flrigBool(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func stringParam(params []any, i int) (string, error) {
+	if i >= len(params) {
+		return "", fmt.Errorf("missing parameter %d", i)
+	}
+	switch value := params[i].(type) {
+	case string:
+		return value, nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+func floatParam(params []any, i int) (float64, error) {
+	if i >= len(params) {
+		return 0, fmt.Errorf("missing parameter %d", i)
+	}
+	switch value := params[i].(type) {
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	default:
+		return 0, fmt.Errorf("parameter %d is not a number: %v", i, value)
+	}
+}
+
+func intParam(params []any, i int) (int, error) {
+	value, err := floatParam(params, i)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(value)), nil
+}
@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFLRigReadMethods(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+	trxData.SetMode(0, tci.ModeDIGU)
+	trxData.SetRXFilterBand(0, 100, 3100)
+	trxData.SetSplitEnable(0, true)
+	server := httptest.NewServer(&flrigServer{
+		trxData:    trxData,
+		kenwood:    &kenwoodConnection{trxData: trxData},
+		flrigState: &flrigState{},
+	})
+	defer server.Close()
+
+	tt := []struct {
+		call     string
+		expected string
+	}{
+		{
+			call:     `<methodCall><methodName>rig.get_vfoA</methodName></methodCall>`,
+			expected: `<value><string>7074000</string></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.get_vfoB</methodName><params></params></methodCall>`,
+			expected: `<value><string>14074000</string></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.get_mode</methodName></methodCall>`,
+			expected: `<value><string>DIGU</string></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.get_bw</methodName></methodCall>`,
+			expected: `<value><array><data><value><string>3000</string></value><value><string></string></value></data></array></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.get_split</methodName></methodCall>`,
+			expected: `<value><i4>1</i4></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.get_ptt</methodName></methodCall>`,
+			expected: `<value><i4>0</i4></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.cat_string</methodName><params><param><value>FA;FB;</value></param></params></methodCall>`,
+			expected: `<value><string>FA00007074000;FB00014074000;</string></value>`,
+		},
+		{
+			call:     `<methodCall><methodName>rig.unknown</methodName></methodCall>`,
+			expected: `<name>faultString</name><value><string>unknown method rig.unknown</string></value>`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.call, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/RPC2", "text/xml", strings.NewReader(`<?xml version="1.0"?>`+tc.call))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Contains(t, string(body), tc.expected)
+		})
+	}
+}
+
+func TestFLRigSetAB(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+	server := &flrigServer{trxData: trxData, flrigState: &flrigState{}}
+
+	_, err := flrigMethods["rig.set_AB"](server, []any{"B"})
+	require.NoError(t, err)
+	vfo, err := flrigMethods["rig.get_AB"](server, nil)
+	require.NoError(t, err)
+	frequency, err := flrigMethods["rig.get_vfo"](server, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "B", vfo)
+	assert.Equal(t, "14074000", frequency)
+}
+
+func TestFLRigTXPolicyWhileTransmitting(t *testing.T) {
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{TXPolicy: testTXPolicy()})
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7030000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 10110000)
+	trxData.SetMode(0, tci.ModeCW)
+	trxData.SetDrive(50)
+	trxData.SetTX(0, true)
+	server := &flrigServer{trxData: trxData, settings: adapter.settingsSource(0, nil), flrigState: &flrigState{}}
+
+	tt := []struct {
+		method string
+		params []any
+	}{
+		{"rig.set_power", []any{60}},
+		{"rig.set_vfoA", []any{7100000.0}},
+		{"rig.set_split", []any{1}},
+		{"rig.set_mode", []any{"USB"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method, func(t *testing.T) {
+			_, err := flrigMethods[tc.method](server, tc.params)
+			assert.ErrorContains(t, err, "TX policy")
+		})
+	}
+}
+
+func TestE2E_FLRigAccessAndRoles(t *testing.T) {
+	setup := startE2E(t, 0)
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	server := httptest.NewServer(&flrigListener{adapter: setup.adapter, trxData: trxData, state: &flrigState{}})
+	t.Cleanup(server.Close)
+	_, localhost, _ := net.ParseCIDR("127.0.0.1/32")
+
+	tt := []struct {
+		name           string
+		settings       Settings
+		call           string
+		expectedStatus int
+		expected       string
+	}{
+		{"denied", Settings{Deny: []*net.IPNet{localhost}}, `<methodCall><methodName>rig.get_vfo</methodName></methodCall>`, http.StatusForbidden, "refused"},
+		{"allowed", Settings{Allow: []*net.IPNet{localhost}}, `<methodCall><methodName>rig.get_vfo</methodName></methodCall>`, http.StatusOK, "<string>7074000</string>"},
+		{"read only", Settings{Role: RoleReadOnly}, `<methodCall><methodName>rig.set_vfoA</methodName><params><param><value><double>14074000</double></value></param></params></methodCall>`, http.StatusOK, "does not permit"},
+		{"tune", Settings{Role: RoleTune}, `<methodCall><methodName>rig.set_ptt</methodName><params><param><value><int>1</int></value></param></params></methodCall>`, http.StatusOK, "does not permit"},
+		{"client role", Settings{Role: RoleFull, Clients: []ClientSettings{{Networks: []*net.IPNet{localhost}, Role: RoleTune}}}, `<methodCall><methodName>rig.set_power</methodName><params><param><value><int>50</int></value></param></params></methodCall>`, http.StatusOK, "does not permit"},
+		{"Kenwood command", Settings{Role: RoleTune}, `<methodCall><methodName>rig.cat_string</methodName><params><param><value>FA;TX;</value></param></params></methodCall>`, http.StatusOK, "<string>FA00007074000;?;</string>"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			setup.adapter.Reconfigure(tc.settings)
+
+			resp, err := http.Post(server.URL+"/RPC2", "text/xml", strings.NewReader(`<?xml version="1.0"?>`+tc.call))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			assert.Contains(t, string(body), tc.expected)
+		})
+	}
+	assert.False(t, setup.sdr.TX(0))
+}
+
+func TestReadXMLRPCMethodCall(t *testing.T) {
+	call := `<?xml version="1.0"?>
+<methodCall>
+	<methodName>rig.set_vfoA</methodName>
+	<params>
+		<param><value><double>14074000.5</double></value></param>
+		<param><value><i4>2</i4></value></param>
+		<param><value><int>3</int></value></param>
+		<param><value><string>USB</string></value></param>
+		<param><value>LSB</value></param>
+		<param><value><boolean>1</boolean></value></param>
+	</params>
+</methodCall>`
+
+	methodName, params, err := readXMLRPCMethodCall(strings.NewReader(call))
+
+	require.NoError(t, err)
+	assert.Equal(t, "rig.set_vfoA", methodName)
+	assert.Equal(t, []any{14074000.5, 2, 3, "USB", "LSB", true}, params)
+}
+
+func TestE2E_FLRigModeRules(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Modes: testModeRules()})
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	server := &flrigServer{
+		tciClient:  setup.adapter.tciClient,
+		tciDevice:  setup.adapter.tciDevice,
+		trxData:    trxData,
+		settings:   setup.adapter.settingsSource(0, nil),
+		flrigState: &flrigState{},
+	}
+	setMode := func(mode string) error {
+		_, err := flrigMethods["rig.set_mode"](server, []any{mode})
+		return err
+	}
+
+	require.NoError(t, setMode("CW"))
+	require.Eventually(t, func() bool { return trxData.Mode() == tci.ModeCW }, e2eTimeout, e2eTick)
+	require.NoError(t, setMode("DIGU"))
+	assert.Eventually(t, func() bool { return setup.sdr.Mode(0) == "usb" }, e2eTimeout, e2eTick, "DIGU is USB on 40m")
+	assert.ErrorContains(t, setMode("DIGL"), "does not support mode SPEC")
+	assert.ErrorContains(t, setMode("SPEC"), "does not support mode SPEC")
+	require.NoError(t, setMode("DRM"))
+	assert.Eventually(t, func() bool { return setup.sdr.Mode(0) == "drm" }, e2eTimeout, e2eTick, "DRM has no Hamlib mode")
+}
+
+func TestE2E_FLRigSetPower(t *testing.T) {
+	setup := startE2E(t, 0)
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	server := &flrigServer{tciClient: setup.adapter.tciClient, trxData: trxData, flrigState: &flrigState{}}
+
+	tt := []struct {
+		percent  int
+		expected int
+	}{
+		{30, 30},
+		{150, 100},
+		{-20, 0},
+	}
+	for _, tc := range tt {
+		_, err := flrigMethods["rig.set_power"](server, []any{tc.percent})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return trxData.Drive() == tc.expected }, e2eTimeout, e2eTick, "%d%%", tc.percent)
+	}
+}
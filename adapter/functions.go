@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+)
+
+type hamlibFunction struct {
+	get func(*TRXData) bool
+	set func(*inboundConnection, bool) error
+	// keysTX indicates that enabling the function lets the TRX transmit, which needs the permission of the TX policy.
+	keysTX bool
+}
+
+// hamlibFunctions maps the supported Hamlib functions onto the corresponding TCI switches. Functions without set
+// can only be read.
+var hamlibFunctions = map[string]hamlibFunction{
+	"NB": {
+		get: (*TRXData).RXNBEnable,
+		set: func(c *inboundConnection, enabled bool) error {
+			return c.tciClient.SetRXNBEnable(c.trxData.trx, enabled)
+		},
+	},
+	"NR": {
+		get: (*TRXData).RXNREnable,
+		set: func(c *inboundConnection, enabled bool) error {
+			return c.tciClient.SetRXNREnable(c.trxData.trx, enabled)
+		},
+	},
+	"ANF": {
+		get: (*TRXData).RXANFEnable,
+		set: func(c *inboundConnection, enabled bool) error {
+			return c.tciClient.SetRXANFEnable(c.trxData.trx, enabled)
+		},
+	},
+	"APF": {
+		get: (*TRXData).RXAPFEnable,
+		set: func(c *inboundConnection, enabled bool) error {
+			return c.tciClient.SetRXAPFEnable(c.trxData.trx, enabled)
+		},
+	},
+	"MUTE": {
+		get: (*TRXData).Mute,
+		set: func(c *inboundConnection, enabled bool) error {
+			return c.tciClient.SetRXMute(c.trxData.trx, enabled)
+		},
+	},
+	"LOCK": {
+		// the TCI client library cannot send the LOCK command, the lock can only be changed on the TCI server
+		get: (*TRXData).Lock,
+	},
+	"TUNER": {
+		get: (*TRXData).Tune,
+		set: func(c *inboundConnection, enabled bool) error {
+			err := c.tciClient.SetTune(c.trxData.trx, enabled)
+			c.watchdog.tuneSent(c.trxData.trx, c.txOwner(), enabled, err)
+			return err
+		},
+		keysTX: true,
+	},
+}
+
+// Bit masks of the Hamlib functions as defined in rig.h.
+var hamlibFunctionBits = map[string]uint64{
+	"FAGC":    1 << 0,
+	"NB":      1 << 1,
+	"COMP":    1 << 2,
+	"VOX":     1 << 3,
+	"TONE":    1 << 4,
+	"TSQL":    1 << 5,
+	"SBKIN":   1 << 6,
+	"FBKIN":   1 << 7,
+	"ANF":     1 << 8,
+	"NR":      1 << 9,
+	"AIP":     1 << 10,
+	"APF":     1 << 11,
+	"MON":     1 << 12,
+	"MN":      1 << 13,
+	"RF":      1 << 14,
+	"ARO":     1 << 15,
+	"LOCK":    1 << 16,
+	"MUTE":    1 << 17,
+	"VSC":     1 << 18,
+	"REV":     1 << 19,
+	"SQL":     1 << 20,
+	"ABM":     1 << 21,
+	"BC":      1 << 22,
+	"MBC":     1 << 23,
+	"RIT":     1 << 24,
+	"AFC":     1 << 25,
+	"SATMODE": 1 << 26,
+	"SCOPE":   1 << 27,
+	"RESUME":  1 << 28,
+	"TBURST":  1 << 29,
+	"TUNER":   1 << 30,
+	"XIT":     1 << 31,
+}
+
+func (c *inboundConnection) getFunc(req request) (protocol.Response, error) {
+	if len(req.Args) < 1 {
+		return protocol.NoResponse, fmt.Errorf("get_func: %w", errNoArguments)
+	}
+	name := strings.ToUpper(req.Args[0])
+	function, ok := hamlibFunctions[name]
+	if !ok {
+		log.Printf("unsupported function: %v", req.LongFormat())
+		return notImplementedResponse(req.Key()), nil
+	}
+	return getFuncResponse(name, function.get(c.trxData)), nil
+}
+
+func (c *inboundConnection) setFunc(req request) (protocol.Response, error) {
+	if len(req.Args) < 2 {
+		return protocol.NoResponse, fmt.Errorf("set_func: %w", errNoArguments)
+	}
+	name := strings.ToUpper(req.Args[0])
+	function, ok := hamlibFunctions[name]
+	if !ok {
+		log.Printf("unsupported function: %v", req.LongFormat())
+		return notImplementedResponse(req.Key()), nil
+	}
+	if function.set == nil {
+		return protocol.NoResponse, fmt.Errorf("set_func: %w", notAvailable("the function %s cannot be set through TCI", name))
+	}
+	enabled, err := parseHamlibBool(req.Args[1])
+	if err != nil {
+		return protocol.NoResponse, fmt.Errorf("set_func: %w", err)
+	}
+	if enabled && function.keysTX {
+		err = c.checkTX()
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_func: %w", err)
+		}
+	}
+	err = function.set(c, enabled)
+	if err != nil {
+		return protocol.NoResponse, fmt.Errorf("set_func: %w", tciCommandFailed(err))
+	}
+	return protocol.OKResponse(req.Key()), nil
+}
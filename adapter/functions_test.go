@@ -0,0 +1,77 @@
+package adapter
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFunc(t *testing.T) {
+	tt := []struct {
+		name     string
+		prepare  func(*TRXData)
+		request  string
+		expected string
+	}{
+		{"NB off", func(d *TRXData) {}, `u NB`, "0"},
+		{"NB on", func(d *TRXData) { d.SetRXNBEnable(0, true) }, `u NB`, "1"},
+		{"NR on", func(d *TRXData) { d.SetRXNREnable(0, true) }, `u NR`, "1"},
+		{"ANF on", func(d *TRXData) { d.SetRXANFEnable(0, true) }, `u ANF`, "1"},
+		{"APF on", func(d *TRXData) { d.SetRXAPFEnable(0, true) }, `u APF`, "1"},
+		{"MUTE on", func(d *TRXData) { d.SetRXMute(0, true) }, `u MUTE`, "1"},
+		{"LOCK on", func(d *TRXData) { d.Message(tci.NewCommandMessage("lock", 0, true)) }, `u LOCK`, "1"},
+		{"TUNER on", func(d *TRXData) { d.SetTune(0, true) }, `u TUNER`, "1"},
+		{"other TRX", func(d *TRXData) { d.SetRXNBEnable(1, true) }, `u NB`, "0"},
+		{"lower case function", func(d *TRXData) { d.SetRXNREnable(0, true) }, `u nr`, "1"},
+		{"unsupported function", func(d *TRXData) {}, `u VOX`, "RPRT -4"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			trxData := newTRXData(0)
+			tc.prepare(trxData)
+			client := openTestConnection(t, trxData, false)
+
+			assert.Equal(t, tc.expected, client.request(t, tc.request))
+		})
+	}
+}
+
+func TestSetFunc(t *testing.T) {
+	setup := startE2E(t, 0)
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// the requests are sent in this order on the same connection
+	tt := []struct {
+		request  string
+		command  string
+		expected string
+	}{
+		{`U NB 1`, "rx_nb_enable", "true"},
+		{`U NB 0`, "rx_nb_enable", "false"},
+		{`U NR 1`, "rx_nr_enable", "true"},
+		{`U ANF 1`, "rx_anf_enable", "true"},
+		{`U APF 1`, "rx_apf_enable", "true"},
+		{`U MUTE 1`, "rx_mute", "true"},
+		{`U MUTE 0`, "rx_mute", "false"},
+		{`U TUNER 1`, "tune", "true"},
+		{`U TUNER 0`, "tune", "false"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, "RPRT 0", client.request(t, tc.request), tc.request)
+		assert.Eventually(t, func() bool {
+			value := setup.sdr.Get(tc.command, 0)
+			return len(value) == 1 && value[0] == tc.expected
+		}, e2eTimeout, e2eTick, tc.request)
+	}
+
+	assert.Equal(t, "RPRT -11", client.request(t, `U LOCK 1`))
+	assert.Equal(t, "RPRT -1", client.request(t, `U NB on`))
+	assert.Equal(t, "RPRT -4", client.request(t, `U VOX 1`))
+}
@@ -0,0 +1,460 @@
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+)
+
+// kenwoodID is the answer to the ID command, 019 identifies the TS-2000.
+const kenwoodID = "019"
+
+// kenwoodCWBufferLength is the length of the text parameter of the KY command.
+const kenwoodCWBufferLength = 24
+
+// kenwoodErrorAnswer is sent when a command cannot be executed.
+const kenwoodErrorAnswer = "?;"
+
+var errUnknownKenwoodCommand = errors.New("unknown command")
+
+// kenwoodCommandKeys maps the Kenwood commands that change the state of the TRX onto the keys of the corresponding
+// Hamlib requests. The role of a client is checked with these keys.
+var kenwoodCommandKeys = map[string]string{
+	"AI": "set_trn",
+	"FA": "set_freq",
+	"FB": "set_freq",
+	"FR": "set_vfo",
+	"FT": "set_split_vfo",
+	"KS": "set_level_keyspd",
+	"KY": "send_morse",
+	"MD": "set_mode",
+	"PC": "set_level_rfpower",
+	"RX": "set_ptt",
+	"TX": "set_ptt",
+}
+
+// kenwoodToHamlibMode maps the modes of the MD command onto the Hamlib modes, the mode rules apply to them like to
+// the Hamlib requests.
+var kenwoodToHamlibMode = map[byte]hamlib.Mode{
+	'1': hamlib.ModeLSB,
+	'2': hamlib.ModeUSB,
+	'3': hamlib.ModeCW,
+	'4': hamlib.ModeFM,
+	'5': hamlib.ModeAM,
+	'6': hamlib.ModePKTLSB,
+	'7': hamlib.ModeCWR,
+	'9': hamlib.ModePKTUSB,
+}
+
+// kenwoodMode returns the mode of the MD command for the given TRX state. CW with a mirrored RX filter is CW-R, like
+// the Hamlib mode CWR.
+func kenwoodMode(state TRXState) byte {
+	if state.Mode == tci.ModeCW && reversedFilter(state.Mode, state.RXFilterMin, state.RXFilterMax) {
+		return '7'
+	}
+	mode, ok := tciToKenwoodMode[state.Mode]
+	if !ok {
+		return '0'
+	}
+	return mode
+}
+
+var tciToKenwoodMode = map[tci.Mode]byte{
+	tci.ModeAM:   '5',
+	tci.ModeSAM:  '5',
+	tci.ModeDSB:  '5',
+	tci.ModeLSB:  '1',
+	tci.ModeUSB:  '2',
+	tci.ModeCW:   '3',
+	tci.ModeNFM:  '4',
+	tci.ModeWFM:  '4',
+	tci.ModeDIGL: '6',
+	tci.ModeDIGU: '9',
+	tci.ModeSPEC: '2',
+	tci.ModeDRM:  '5',
+}
+
+// ListenKenwood opens the given local address to accept incoming connections that use the Kenwood TS-2000
+// CAT protocol to control the given TRX.
+func (a *Adapter) ListenKenwood(trx int, localAddress string) error {
+	trxData, err := a.trxData(trx)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return fmt.Errorf("cannot open local port %s for Kenwood connections: %w", localAddress, err)
+	}
+	log.Printf("listening for Kenwood connections to TRX %d on %s", trx, listener.Addr())
+
+	go func() {
+		<-a.closed
+		listener.Close()
+	}()
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				select {
+				case <-a.closed:
+				default:
+					log.Printf("Kenwood listener: %v", err)
+				}
+				return
+			}
+			if !a.acceptConnection("Kenwood", c.RemoteAddr()) {
+				c.Close()
+				continue
+			}
+			a.serveKenwood(c, trxData)
+		}
+	}()
+	return nil
+}
+
+func (a *Adapter) serveKenwood(conn io.ReadWriteCloser, trxData *TRXData) {
+	c := &kenwoodConnection{
+		conn:      conn,
+		tciClient: a.tciClient,
+		tciDevice: a.tciDevice,
+		watchdog:  a.watchdog,
+		txOwner:   fmt.Sprintf("Kenwood connection %d", a.lastConnID.Add(1)),
+		trxData:   trxData,
+		closed:    make(chan struct{}),
+		settings:  a.settingsSource(trxData.trx, remoteAddress(conn)),
+	}
+	go c.run()
+	go func() {
+		select {
+		case <-a.closed:
+			conn.Close()
+		case <-c.closed:
+		}
+	}()
+}
+
+func (c *kenwoodConnection) setTX(enabled bool) error {
+	if enabled {
+		err := c.checkTX()
+		if err != nil {
+			return err
+		}
+	}
+	err := c.tciClient.SetTX(c.trxData.trx, enabled, tci.SignalSourceDefault)
+	c.watchdog.txSent(c.trxData.trx, c.txOwner, enabled, err)
+	return err
+}
+
+// checkTX returns an error if the TX policy does not allow this connection to key the TRX in its current state.
+func (c *kenwoodConnection) checkTX() error {
+	return c.settings.current().txPolicy.check(c.trxData.Snapshot(), c.txOwner)
+}
+
+// checkTXChange returns an error if the TX policy does not allow this connection to apply the given change while
+// the TRX transmits.
+func (c *kenwoodConnection) checkTXChange(change func(*TRXState)) error {
+	return c.settings.current().txPolicy.checkChange(c.trxData.Snapshot(), c.txOwner, change)
+}
+
+// remoteAddress returns the remote address of the given connection, or nil if it is not a network connection.
+func remoteAddress(conn io.ReadWriteCloser) net.Addr {
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		return nil
+	}
+	return netConn.RemoteAddr()
+}
+
+func (a *Adapter) trxData(trx int) (*TRXData, error) {
+	for _, trxListener := range a.trxListeners {
+		if trxListener.trxData.trx == trx {
+			return trxListener.trxData, nil
+		}
+	}
+	return nil, fmt.Errorf("TRX %d is not used by the adapter", trx)
+}
+
+// kenwoodConnection handles the commands of the Kenwood TS-2000 CAT protocol. Each command is terminated by ';'.
+// Set commands are not answered, read commands are answered with the corresponding set command.
+type kenwoodConnection struct {
+	conn      io.ReadWriteCloser
+	tciClient *tci.Client
+	tciDevice *announcedDevice
+	watchdog  *txWatchdog
+	txOwner   string
+	trxData   *TRXData
+	closed    chan struct{}
+	settings  settingsSource
+}
+
+func (c *kenwoodConnection) run() {
+	defer c.Close()
+	defer c.conn.Close()
+	defer c.watchdog.release(c.txOwner)
+	r := bufio.NewReader(c.conn)
+	for {
+		command, err := r.ReadString(';')
+		if err == io.EOF {
+			log.Print("Kenwood connection EOF")
+			return
+		}
+		if err != nil {
+			log.Printf("Kenwood connection: %v", err)
+			return
+		}
+		command = strings.TrimLeft(strings.TrimSuffix(command, ";"), " \r\n\t")
+		if command == "" {
+			continue
+		}
+
+		answer, err := c.handleCommand(command)
+		if err != nil {
+			log.Printf("Kenwood command %s failed: %v", command, err)
+			answer = kenwoodErrorAnswer
+		}
+		if answer == "" {
+			continue
+		}
+		if c.settings.current().trace {
+			log.Printf("> %s", answer)
+		}
+		_, err = io.WriteString(c.conn, answer)
+		if err != nil {
+			log.Printf("Kenwood connection: %v", err)
+			return
+		}
+	}
+}
+
+func (c *kenwoodConnection) Close() {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+}
+
+func (c *kenwoodConnection) handleCommand(command string) (string, error) {
+	if len(command) < 2 {
+		return "", fmt.Errorf("invalid command")
+	}
+	name := strings.ToUpper(command[:2])
+	args := command[2:]
+	settings := c.settings.current()
+	if settings.trace && args != "" {
+		log.Printf("< %s;", command)
+	}
+	if key, ok := kenwoodCommandKey(name, args); ok && !settings.role.permits(key) {
+		return "", notPermitted(settings.role)
+	}
+
+	switch name {
+	case "ID":
+		return "ID" + kenwoodID + ";", nil
+	case "PS":
+		return "PS1;", nil
+	case "AI":
+		if args == "" {
+			return "AI0;", nil
+		}
+		return "", nil
+	case "FA":
+		return c.vfoFrequency(name, tci.VFOA, args)
+	case "FB":
+		return c.vfoFrequency(name, tci.VFOB, args)
+	case "MD":
+		if args == "" {
+			return "MD" + string(kenwoodMode(c.trxData.Snapshot())) + ";", nil
+		}
+		hamlibMode, ok := kenwoodToHamlibMode[args[0]]
+		if !ok {
+			return "", fmt.Errorf("invalid mode %s", args)
+		}
+		// TCI always receives on VFO A
+		mode, reverse, err := c.settings.current().tciMode(hamlibMode, c.trxData.VFOFrequency(tci.VFOA), c.tciDevice.modes())
+		if err != nil {
+			return "", err
+		}
+		err = c.checkTXChange(func(s *TRXState) { s.Mode = mode })
+		if err != nil {
+			return "", err
+		}
+		return kenwoodSetResult(setModeKeepingRXFilter(c.tciClient, c.trxData, mode, reverse))
+	case "IF":
+		return c.informationAnswer(), nil
+	case "TX":
+		return kenwoodSetResult(c.setTX(true))
+	case "RX":
+		return kenwoodSetResult(c.setTX(false))
+	case "FR":
+		// TCI always receives on VFO A
+		if args == "" {
+			return "FR0;", nil
+		}
+		vfo, err := parseKenwoodVFO(args)
+		if err != nil {
+			return "", err
+		}
+		if vfo != tci.VFOA {
+			return "", fmt.Errorf("the TRX can only receive on VFO A")
+		}
+		return "", nil
+	case "FT":
+		// in split mode, TCI transmits on VFO B, otherwise on VFO A
+		if args == "" {
+			return "FT" + kenwoodBool(c.trxData.SplitEnable()) + ";", nil
+		}
+		vfo, err := parseKenwoodVFO(args)
+		if err != nil {
+			return "", err
+		}
+		split := vfo == tci.VFOB
+		err = c.checkTXChange(func(s *TRXState) { s.SplitEnabled = split })
+		if err != nil {
+			return "", err
+		}
+		return kenwoodSetResult(c.tciClient.SetSplitEnable(c.trxData.trx, split))
+	case "PC":
+		if args == "" {
+			return fmt.Sprintf("PC%03d;", c.trxData.Drive()), nil
+		}
+		percent, err := strconv.Atoi(args)
+		if err != nil {
+			return "", fmt.Errorf("invalid power: %w", err)
+		}
+		percent = min(max(percent, 0), 100)
+		err = c.checkTXChange(func(s *TRXState) { s.Drive = percent })
+		if err != nil {
+			return "", err
+		}
+		return kenwoodSetResult(setDrive(c.tciClient, c.trxData.trx, percent))
+	case "KS":
+		if args == "" {
+			wpm, err := c.tciClient.CWMacrosSpeed()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("KS%03d;", wpm), nil
+		}
+		wpm, err := strconv.Atoi(args)
+		if err != nil {
+			return "", fmt.Errorf("invalid keyer speed: %w", err)
+		}
+		return kenwoodSetResult(c.tciClient.SetCWMacrosSpeed(wpm))
+	case "KY":
+		if args == "" {
+			// KY1 indicates that the CW buffer is full
+			return "KY" + kenwoodBool(c.trxData.SendingCW()) + ";", nil
+		}
+		text := strings.TrimSpace(args)
+		if text == "" {
+			return "", nil
+		}
+		if len(text) > kenwoodCWBufferLength {
+			text = text[:kenwoodCWBufferLength]
+		}
+		err := c.checkTX()
+		if err != nil {
+			return "", err
+		}
+		err = c.tciClient.SendCWMacro(c.trxData.trx, text)
+		c.watchdog.cwSent(c.trxData.trx, c.txOwner, err)
+		return kenwoodSetResult(err)
+	default:
+		return "", errUnknownKenwoodCommand
+	}
+}
+
+func (c *kenwoodConnection) vfoFrequency(name string, vfo tci.VFO, args string) (string, error) {
+	if args == "" {
+		return fmt.Sprintf("%s%011d;", name, c.trxData.VFOFrequency(vfo)), nil
+	}
+	if c.trxData.Lock() {
+		return "", fmt.Errorf("VFO is locked")
+	}
+	frequency, err := strconv.Atoi(args)
+	if err != nil {
+		return "", fmt.Errorf("invalid frequency: %w", err)
+	}
+	err = c.checkTXChange(func(s *TRXState) { s.VFOs[vfo].Frequency = frequency })
+	if err != nil {
+		return "", err
+	}
+	return kenwoodSetResult(c.tciClient.SetVFOFrequency(c.trxData.trx, vfo, frequency))
+}
+
+// informationAnswer returns the answer to the IF command, which contains the essential state of the TRX in a
+// fixed format of 38 characters.
+func (c *kenwoodConnection) informationAnswer() string {
+	state := c.trxData.Snapshot()
+
+	offset := state.RITOffset
+	if state.XITEnabled && !state.RITEnabled {
+		offset = state.XITOffset
+	}
+	mode := kenwoodMode(state)
+
+	var answer strings.Builder
+	answer.WriteString("IF")
+	fmt.Fprintf(&answer, "%011d", state.VFOFrequency(tci.VFOA))
+	answer.WriteString("     ")                         // frequency step
+	fmt.Fprintf(&answer, "%+05d", offset)               // RIT/XIT offset
+	answer.WriteString(kenwoodBool(state.RITEnabled))   // RIT
+	answer.WriteString(kenwoodBool(state.XITEnabled))   // XIT
+	answer.WriteString("000")                           // memory channel
+	answer.WriteString(kenwoodBool(state.Transmitting)) // RX/TX
+	answer.WriteByte(mode)                              // mode
+	answer.WriteString("0")                             // VFO
+	answer.WriteString("0")                             // scan
+	answer.WriteString(kenwoodBool(state.SplitEnabled)) // split
+	answer.WriteString("000")                           // tone, tone number
+	answer.WriteString("0")                             // shift
+	answer.WriteString(";")
+	return answer.String()
+}
+
+// kenwoodCommandKey returns the key of the Hamlib request that corresponds to the given Kenwood command. Commands
+// with arguments set the state of the TRX, only TX and RX have no arguments. Commands that only read the state of the
+// TRX have no key.
+func kenwoodCommandKey(name string, args string) (string, bool) {
+	if args == "" && name != "TX" && name != "RX" {
+		return "", false
+	}
+	key, ok := kenwoodCommandKeys[name]
+	return key, ok
+}
+
+// kenwoodSetResult returns the result of a set command. Set commands are not answered, and like with the Hamlib
+// set commands, a TCI timeout is not treated as error.
+func kenwoodSetResult(err error) (string, error) {
+	if err == nil || errors.Is(err, tci.ErrTimeout) {
+		return "", nil
+	}
+	return "", fmt.Errorf("cannot send TCI command: %w", err)
+}
+
+func parseKenwoodVFO(arg string) (tci.VFO, error) {
+	switch arg {
+	case "0":
+		return tci.VFOA, nil
+	case "1":
+		return tci.VFOB, nil
+	default:
+		return tci.VFOA, fmt.Errorf("invalid VFO %s", arg)
+	}
+}
+
+func kenwoodBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// OpenKenwoodPTY opens a pseudo terminal that uses the Kenwood TS-2000 CAT protocol to control the given TRX.
+// The terminal is linked to the given path, so applications can use this path like a serial port.
+func (a *Adapter) OpenKenwoodPTY(trx int, linkPath string) error {
+	trxData, err := a.trxData(trx)
+	if err != nil {
+		return err
+	}
+	err = removeStaleLink(linkPath)
+	if err != nil {
+		return err
+	}
+
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("cannot open pseudo terminal: %w", err)
+	}
+	err = makeRaw(pts)
+	if err != nil {
+		ptm.Close()
+		pts.Close()
+		return fmt.Errorf("cannot configure pseudo terminal %s: %w", pts.Name(), err)
+	}
+	err = os.Symlink(pts.Name(), linkPath)
+	if err != nil {
+		ptm.Close()
+		pts.Close()
+		return fmt.Errorf("cannot link pseudo terminal %s to %s: %w", pts.Name(), linkPath, err)
+	}
+	log.Printf("Kenwood pseudo terminal for TRX %d: %s -> %s", trx, linkPath, pts.Name())
+
+	// keep the terminal side open, otherwise the adapter reads EOF when the last application closes the terminal
+	go func() {
+		<-a.closed
+		os.Remove(linkPath)
+		pts.Close()
+	}()
+	a.serveKenwood(ptm, trxData)
+	return nil
+}
+
+// removeStaleLink removes a symlink that was left over by a previous run. Any other file at this path is kept.
+func removeStaleLink(linkPath string) error {
+	info, err := os.Lstat(linkPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot check %s: %w", linkPath, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s already exists and is not a symlink", linkPath)
+	}
+	return os.Remove(linkPath)
+}
+
+// makeRaw puts the terminal into raw mode, like cfmakeraw(3).
+func makeRaw(terminal *os.File) error {
+	fd := int(terminal.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+}
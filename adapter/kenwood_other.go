@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package adapter
+
+import "fmt"
+
+// OpenKenwoodPTY is only supported on Linux.
+func (a *Adapter) OpenKenwoodPTY(trx int, linkPath string) error {
+	return fmt.Errorf("pseudo terminals are not supported on this platform")
+}
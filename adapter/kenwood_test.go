@@ -0,0 +1,245 @@
+package adapter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKenwoodReadCommands(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+	trxData.SetMode(0, tci.ModeUSB)
+	trxData.SetRITEnable(0, true)
+	trxData.SetRITOffset(0, -120)
+	trxData.SetSplitEnable(0, true)
+	trxData.SetDrive(35)
+	conn := &kenwoodConnection{trxData: trxData}
+
+	tt := []struct {
+		command  string
+		expected string
+	}{
+		{"ID", "ID019;"},
+		{"FA", "FA00007074000;"},
+		{"FB", "FB00014074000;"},
+		{"MD", "MD2;"},
+		{"FR", "FR0;"},
+		{"FT", "FT1;"},
+		{"KY", "KY0;"},
+		{"PC", "PC035;"},
+		{"IF", "IF00007074000     -012010000020010000;"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.command, func(t *testing.T) {
+			answer, err := conn.handleCommand(tc.command)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, answer)
+		})
+	}
+}
+
+func TestKenwoodReceivesOnVFOA(t *testing.T) {
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 14074000)
+	conn := &kenwoodConnection{trxData: trxData}
+
+	_, err := conn.handleCommand("FR1")
+	assert.Error(t, err)
+
+	answer, err := conn.handleCommand("FR0")
+	assert.NoError(t, err)
+	assert.Equal(t, "", answer)
+
+	answer, err = conn.handleCommand("IF")
+	assert.NoError(t, err)
+	assert.Len(t, answer, 38)
+	assert.Equal(t, "IF00007074000", answer[:13])
+	assert.Equal(t, byte('0'), answer[30])
+}
+
+func TestKenwoodCWBuffer(t *testing.T) {
+	trxData := newTRXData(0)
+	conn := &kenwoodConnection{trxData: trxData}
+	message := func(name string, args ...any) func() {
+		return func() { trxData.Message(tci.NewCommandMessage(name, args...)) }
+	}
+
+	// the steps are applied in this order to the same TRX
+	tt := []struct {
+		name     string
+		apply    func()
+		expected string
+	}{
+		{"idle", func() {}, "KY0;"},
+		{"transmitting without CW macro", func() { trxData.SetTX(0, true) }, "KY0;"},
+		{"CW macro of another TRX", message("cw_macros", 1, "CQ"), "KY0;"},
+		{"CW macro", message("cw_macros", 0, "CQ"), "KY1;"},
+		{"CW macro sent", trxData.CWMacrosEmpty, "KY0;"},
+		{"CW macro again", message("cw_macros", 0, "TEST"), "KY1;"},
+		{"CW macro stopped", message("cw_macros_stop"), "KY0;"},
+		{"CW macro until the transmission ends", message("cw_macros", 0, "DE"), "KY1;"},
+		{"transmission ended", func() { trxData.SetTX(0, false) }, "KY0;"},
+	}
+	for _, tc := range tt {
+		tc.apply()
+
+		answer, err := conn.handleCommand("KY")
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, answer, tc.name)
+	}
+}
+
+func TestKenwoodTXPolicyWhileTransmitting(t *testing.T) {
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{TXPolicy: testTXPolicy()})
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7030000)
+	trxData.SetVFOFrequency(0, tci.VFOB, 10110000)
+	trxData.SetMode(0, tci.ModeCW)
+	trxData.SetDrive(50)
+	trxData.SetTX(0, true)
+	conn := &kenwoodConnection{trxData: trxData, settings: adapter.settingsSource(0, nil)}
+
+	for _, command := range []string{"PC060", "FA00007100000", "FT1", "MD2"} {
+		t.Run(command, func(t *testing.T) {
+			_, err := conn.handleCommand(command)
+			assert.ErrorContains(t, err, "TX policy")
+		})
+	}
+}
+
+func TestKenwoodRoles(t *testing.T) {
+	adapter := &Adapter{}
+	trxData := newTRXData(0)
+	trxData.SetVFOFrequency(0, tci.VFOA, 7074000)
+	conn := &kenwoodConnection{trxData: trxData, settings: adapter.settingsSource(0, nil)}
+
+	tt := []struct {
+		role     Role
+		command  string
+		expected string
+		refused  bool
+	}{
+		{RoleReadOnly, "FA", "FA00007074000;", false},
+		{RoleReadOnly, "AI0", "", false},
+		{RoleReadOnly, "FA00014074000", "", true},
+		{RoleReadOnly, "MD2", "", true},
+		{RoleReadOnly, "FT1", "", true},
+		{RoleTune, "TX", "", true},
+		{RoleTune, "RX", "", true},
+		{RoleTune, "KY CQ", "", true},
+		{RoleTune, "PC050", "", true},
+		{RoleTune, "PC", "PC000;", false},
+	}
+	for _, tc := range tt {
+		t.Run(string(tc.role)+" "+tc.command, func(t *testing.T) {
+			adapter.Reconfigure(Settings{Role: tc.role})
+
+			answer, err := conn.handleCommand(tc.command)
+
+			if tc.refused {
+				assert.ErrorContains(t, err, "does not permit")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, answer)
+		})
+	}
+}
+
+func TestKenwoodUnknownCommand(t *testing.T) {
+	conn := &kenwoodConnection{trxData: newTRXData(0)}
+
+	_, err := conn.handleCommand("XX")
+
+	assert.ErrorIs(t, err, errUnknownKenwoodCommand)
+}
+
+// openKenwood opens a Kenwood connection to the given TRX. The returned functions send a command, command also
+// waits for the answer.
+func (s *e2eSetup) openKenwood(t *testing.T, trx int) (send func(string), command func(string) string) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	t.Cleanup(func() { clientSide.Close() })
+	trxData, err := s.adapter.trxData(trx)
+	require.NoError(t, err)
+	s.adapter.serveKenwood(serverSide, trxData)
+	reader := bufio.NewReader(clientSide)
+	send = func(command string) {
+		_, err := io.WriteString(clientSide, command)
+		require.NoError(t, err)
+	}
+	command = func(command string) string {
+		send(command)
+		clientSide.SetReadDeadline(time.Now().Add(e2eTimeout))
+		answer, err := reader.ReadString(';')
+		require.NoError(t, err)
+		return answer
+	}
+	return send, command
+}
+
+func TestE2E_KenwoodSplit(t *testing.T) {
+	setup := startE2E(t, 0)
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	send, command := setup.openKenwood(t, 0)
+
+	// the commands are sent in this order on the same connection
+	tt := []struct {
+		rxVFO    string
+		txVFO    string
+		expected bool
+	}{
+		{"", "FT1;", true},
+		{"", "FT0;", false},
+		{"FR1;", "FT1;", true},
+		{"FR1;", "FT0;", false},
+	}
+	for _, tc := range tt {
+		if tc.rxVFO != "" {
+			assert.Equal(t, kenwoodErrorAnswer, command(tc.rxVFO), "TCI receives on VFO A")
+		}
+		send(tc.txVFO)
+		assert.Eventually(t, func() bool { return setup.sdr.SplitEnable(0) == tc.expected }, e2eTimeout, e2eTick, tc.txVFO)
+		assert.Eventually(t, func() bool { return trxData.SplitEnable() == tc.expected }, e2eTimeout, e2eTick, tc.txVFO)
+		assert.Equal(t, "FT"+kenwoodBool(tc.expected)+";", command("FT;"), tc.txVFO)
+	}
+}
+
+func TestE2E_KenwoodModeRules(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Modes: testModeRules()})
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	send, command := setup.openKenwood(t, 0)
+
+	send("MD3;")
+	require.Eventually(t, func() bool { return trxData.Mode() == tci.ModeCW }, e2eTimeout, e2eTick)
+	send("MD9;")
+	assert.Eventually(t, func() bool { return setup.sdr.Mode(0) == "usb" }, e2eTimeout, e2eTick, "PKTUSB is USB on 40m")
+	assert.Equal(t, kenwoodErrorAnswer, command("MD6;"), "the TCI host does not support SPEC")
+
+	send("MD3;")
+	require.Eventually(t, func() bool { return trxData.Mode() == tci.ModeCW }, e2eTimeout, e2eTick)
+	min, max := setup.sdr.RXFilterBand(0)
+	send("MD7;")
+	assert.Eventually(t, func() bool {
+		mirroredMin, mirroredMax := setup.sdr.RXFilterBand(0)
+		return mirroredMin == -max && mirroredMax == -min
+	}, e2eTimeout, e2eTick, "CWR mirrors the RX filter")
+	assert.Eventually(t, func() bool { return command("MD;") == "MD7;" }, e2eTimeout, e2eTick, "CW with mirrored RX filter is CW-R")
+	assert.Equal(t, byte('7'), command("IF;")[29], "mode of the IF answer")
+
+	send("MD3;")
+	assert.Eventually(t, func() bool { return command("MD;") == "MD3;" }, e2eTimeout, e2eTick)
+}
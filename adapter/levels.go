@@ -0,0 +1,157 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+	tci "github.com/ftl/tci/client"
+)
+
+const (
+	// s9Level is the signal strength of S9 in dBm, Hamlib's STRENGTH level is relative to S9.
+	s9Level = -73
+	// s0Level is the signal strength of S0 in dBm, it is the lowest value of the RAWSTR level.
+	s0Level = -127
+	// maxRawStrength is the highest value of the RAWSTR level, one step is 1 dB above S0.
+	maxRawStrength = 255
+	// minVolume is the lowest volume in dB that TCI supports.
+	minVolume = -60
+	// minSquelchLevel is the lowest squelch threshold in dB that TCI supports.
+	minSquelchLevel = -140
+	// nominalTXPower is the output power in W that is used to normalize the RFPOWER_METER level.
+	nominalTXPower = 100.0
+)
+
+type levelCapability struct {
+	name string
+	get  bool
+	set  bool
+	min  float64
+	max  float64
+	step float64
+}
+
+// levelCapabilities lists all Hamlib levels that are supported by getLevel and setLevel.
+var levelCapabilities = []levelCapability{
+	{name: "AF", get: true, set: true, min: 0, max: 1, step: 1.0 / -minVolume},
+	{name: "SQL", get: true, set: true, min: 0, max: 1, step: 1.0 / -minSquelchLevel},
+	{name: "CWPITCH", get: true, min: 300, max: 1000, step: 10},
+	{name: "RFPOWER", get: true, set: true, min: 0, max: 1, step: 0.01},
+	{name: "KEYSPD", get: true, set: true, min: 1, max: 60, step: 1},
+	{name: "RAWSTR", get: true, min: 0, max: maxRawStrength, step: 1},
+	{name: "SWR", get: true},
+	{name: "STRENGTH", get: true},
+	{name: "RFPOWER_METER", get: true, min: 0, max: 1, step: 0.01},
+	{name: "RFPOWER_METER_WATTS", get: true, min: 0, max: nominalTXPower, step: 0.1},
+}
+
+func (c *inboundConnection) getLevel(req request, vfo tci.VFO) (protocol.Response, error) {
+	if len(req.Args) < 1 {
+		return protocol.NoResponse, fmt.Errorf("get_level: %w", errNoArguments)
+	}
+	level := strings.ToUpper(req.Args[0])
+	switch level {
+	case "KEYSPD":
+		wpm, err := c.tciClient.CWMacrosSpeed()
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("get_level: %w", tciCommandFailed(err))
+		}
+		return protocol.GetLevelKeyspdResponse(wpm), nil
+	case "STRENGTH":
+		return getLevelResponse(level, strconv.Itoa(c.trxData.SMeter(vfo)-s9Level)), nil
+	case "RAWSTR":
+		return getLevelResponse(level, strconv.Itoa(rawStrength(c.trxData.SMeter(vfo)))), nil
+	case "RFPOWER":
+		return getLevelResponse(level, formatLevel(float64(c.trxData.Drive())/100)), nil
+	case "AF":
+		return getLevelResponse(level, formatLevel(normalize(c.trxData.Volume(vfo), minVolume, 0))), nil
+	case "SQL":
+		return getLevelResponse(level, formatLevel(normalize(c.trxData.SquelchLevel(), minSquelchLevel, 0))), nil
+	case "CWPITCH":
+		return getLevelResponse(level, strconv.Itoa(c.trxData.CWPitch())), nil
+	case "SWR":
+		return getLevelResponse(level, formatLevel(c.trxData.TXSWR())), nil
+	case "RFPOWER_METER":
+		return getLevelResponse(level, formatLevel(math.Min(c.trxData.TXPower()/nominalTXPower, 1))), nil
+	case "RFPOWER_METER_WATTS":
+		return getLevelResponse(level, formatLevel(c.trxData.TXPower())), nil
+	default:
+		log.Printf("unsupported level: %v", req.LongFormat())
+		return notImplementedResponse(req.Key()), nil
+	}
+}
+
+func (c *inboundConnection) setLevel(req request, vfo tci.VFO) (protocol.Response, error) {
+	if len(req.Args) < 2 {
+		return protocol.NoResponse, fmt.Errorf("set_level: %w", errNoArguments)
+	}
+	level := strings.ToUpper(req.Args[0])
+	value, err := strconv.ParseFloat(req.Args[1], 64)
+	if err != nil {
+		return protocol.NoResponse, fmt.Errorf("set_level: %w", invalidArgument("invalid value for %s: %w", level, err))
+	}
+	switch level {
+	case "KEYSPD":
+		err = c.tciClient.SetCWMacrosSpeed(int(value))
+	case "RFPOWER":
+		percent := int(math.Round(clamp(value, 0, 1) * 100))
+		err = c.checkTXChange(func(s *TRXState) { s.Drive = percent })
+		if err != nil {
+			return protocol.NoResponse, fmt.Errorf("set_level: %w", err)
+		}
+		err = setDrive(c.tciClient, c.trxData.trx, percent)
+	case "AF":
+		dB := denormalize(value, minVolume, 0)
+		if c.trxData.HasRXVolume(vfo) {
+			err = c.tciClient.SetRXVolume(c.trxData.trx, vfo, dB)
+		} else {
+			err = c.tciClient.SetVolume(dB)
+		}
+	case "SQL":
+		err = c.tciClient.SetSquelchLevel(denormalize(value, minSquelchLevel, 0))
+	case "CWPITCH":
+		return protocol.NoResponse, fmt.Errorf("set_level: %w", notAvailable("the CW pitch can only be changed on the TCI server"))
+	default:
+		log.Printf("unsupported level: %v", req.LongFormat())
+		return notImplementedResponse(req.Key()), nil
+	}
+	if err != nil {
+		return protocol.NoResponse, fmt.Errorf("set_level: %w", tciCommandFailed(err))
+	}
+	return protocol.OKResponse(req.Key()), nil
+}
+
+// rawStrength maps the given signal strength in dBm onto the non-negative RAWSTR level: 0 is S0, each step is 1 dB.
+func rawStrength(dBm int) int {
+	return min(max(dBm-s0Level, 0), maxRawStrength)
+}
+
+// setDrive sets the drive of the given TRX. The drive of TRX 0 is the global drive of the TCI server.
+func setDrive(client *tci.Client, trx int, percent int) error {
+	if trx == 0 {
+		return client.SetDrive(percent)
+	}
+	return client.SetTRXDrive(trx, percent)
+}
+
+// normalize maps the given value from the range [min, max] to Hamlib's normalized range [0, 1].
+func normalize(value, min, max int) float64 {
+	return clamp(float64(value-min)/float64(max-min), 0, 1)
+}
+
+// denormalize maps the given value from Hamlib's normalized range [0, 1] to the range [min, max].
+func denormalize(value float64, min, max int) int {
+	return min + int(math.Round(clamp(value, 0, 1)*float64(max-min)))
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+func formatLevel(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
@@ -0,0 +1,91 @@
+package adapter
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLevel(t *testing.T) {
+	tt := []struct {
+		name     string
+		prepare  func(*TRXData)
+		request  string
+		expected string
+	}{
+		{"STRENGTH at S9", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -73) }, `l STRENGTH`, "0"},
+		{"STRENGTH above S9", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -53) }, `l STRENGTH`, "20"},
+		{"STRENGTH below S9", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -121) }, `l STRENGTH`, "-48"},
+		{"STRENGTH from the RX sensor", func(d *TRXData) { d.SetRXSensors(0, -62.6) }, `l STRENGTH`, "10"},
+		{"RAWSTR", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -90) }, `l RAWSTR`, "37"},
+		{"RAWSTR below S0", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -140) }, `l RAWSTR`, "0"},
+		{"RAWSTR at the maximum", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, 140) }, `l RAWSTR`, "255"},
+		{"AF from the main volume", func(d *TRXData) { d.SetVolume(-30) }, `l AF`, "0.500000"},
+		{"AF from the RX volume", func(d *TRXData) {
+			d.SetVolume(-60)
+			d.SetRXVolume(0, tci.VFOA, -15)
+		}, `l AF`, "0.750000"},
+		{"AF at 0 dB", func(d *TRXData) { d.SetVolume(0) }, `l AF`, "1.000000"},
+		{"AF below the minimum", func(d *TRXData) { d.SetVolume(-80) }, `l AF`, "0.000000"},
+		{"SQL", func(d *TRXData) { d.SetSquelchLevel(-70) }, `l SQL`, "0.500000"},
+		{"SQL at the minimum", func(d *TRXData) { d.SetSquelchLevel(-140) }, `l SQL`, "0.000000"},
+		{"RFPOWER", func(d *TRXData) { d.SetDrive(35) }, `l RFPOWER`, "0.350000"},
+		{"CWPITCH", func(d *TRXData) {}, `l CWPITCH`, "600"},
+		{"CWPITCH of the TRX", func(d *TRXData) { d.Message(tci.NewCommandMessage("cw_pitch", 0, 700)) }, `l CWPITCH`, "700"},
+		{"CWPITCH of all TRX", func(d *TRXData) { d.Message(tci.NewCommandMessage("cw_pitch", 450)) }, `l CWPITCH`, "450"},
+		{"CWPITCH of another TRX", func(d *TRXData) { d.Message(tci.NewCommandMessage("cw_pitch", 1, 700)) }, `l CWPITCH`, "600"},
+		{"SWR", func(d *TRXData) { d.SetTXSWR(1.5) }, `l SWR`, "1.500000"},
+		{"RFPOWER_METER", func(d *TRXData) { d.SetTXPower(50) }, `l RFPOWER_METER`, "0.500000"},
+		{"RFPOWER_METER above the nominal power", func(d *TRXData) { d.SetTXPower(150) }, `l RFPOWER_METER`, "1.000000"},
+		{"RFPOWER_METER_WATTS", func(d *TRXData) { d.SetTXPower(50) }, `l RFPOWER_METER_WATTS`, "50.000000"},
+		{"lower case level", func(d *TRXData) { d.SetRXSMeter(0, tci.VFOA, -73) }, `l strength`, "0"},
+		{"unsupported level", func(d *TRXData) {}, `l NOTCHF`, "RPRT -4"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			trxData := newTRXData(0)
+			tc.prepare(trxData)
+			client := openTestConnection(t, trxData, false)
+
+			assert.Equal(t, tc.expected, client.request(t, tc.request))
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	setup := startE2E(t, 0)
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	tt := []struct {
+		request  string
+		command  string
+		keys     []any
+		expected string
+	}{
+		{`L AF 0.5`, "volume", nil, "-30"},
+		{`L AF 1.5`, "volume", nil, "0"},
+		{`L SQL 0.25`, "sql_level", nil, "-105"},
+		{`L SQL 0`, "sql_level", nil, "-140"},
+		{`L RFPOWER 0.4`, "drive", []any{0}, "40"},
+		{`L RFPOWER -1`, "drive", []any{0}, "0"},
+		{`L KEYSPD 30`, "cw_macros_speed", nil, "30"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, "RPRT 0", client.request(t, tc.request), tc.request)
+		assert.Eventually(t, func() bool {
+			value := setup.sdr.Get(tc.command, tc.keys...)
+			return len(value) > 0 && value[len(value)-1] == tc.expected
+		}, e2eTimeout, e2eTick, tc.request)
+	}
+
+	assert.Equal(t, "RPRT -11", client.request(t, `L CWPITCH 700`))
+	assert.Equal(t, "RPRT -1", client.request(t, `L AF loud`))
+	assert.Equal(t, "RPRT -4", client.request(t, `L NOTCHF 1000`))
+}
@@ -0,0 +1,285 @@
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// requestDurationBuckets are the upper bounds of the buckets of the request duration histogram in seconds.
+// The TCI client waits 50ms for a reply, the buckets are chosen around this timeout.
+var requestDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type requestCountKey struct {
+	command string
+	result  string
+}
+
+type txInterventionKey struct {
+	trx    int
+	reason string
+}
+
+type requestDuration struct {
+	buckets []int
+	count   int
+	sum     float64
+}
+
+// adapterMetrics collects the metrics of the adapter. It is notified by the TCI client about the connection state.
+type adapterMetrics struct {
+	mutex             sync.Mutex
+	tciConnected      bool
+	tciConnects       int
+	tciTimeouts       int
+	hamlibConnections map[int]int
+	requestCounts     map[requestCountKey]int
+	requestDurations  map[string]*requestDuration
+	txInterventions   map[txInterventionKey]int
+}
+
+func newAdapterMetrics() *adapterMetrics {
+	return &adapterMetrics{
+		hamlibConnections: make(map[int]int),
+		requestCounts:     make(map[requestCountKey]int),
+		requestDurations:  make(map[string]*requestDuration),
+		txInterventions:   make(map[txInterventionKey]int),
+	}
+}
+
+// Connected implements the tci.ConnectionListener interface.
+func (m *adapterMetrics) Connected(connected bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tciConnected = connected
+	if connected {
+		m.tciConnects++
+	}
+}
+
+func (m *adapterMetrics) connected() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tciConnected
+}
+
+func (m *adapterMetrics) hamlibConnectionOpened(trx int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.hamlibConnections[trx]++
+}
+
+func (m *adapterMetrics) hamlibConnectionClosed(trx int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.hamlibConnections[trx]--
+}
+
+func (m *adapterMetrics) tciTimeout() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tciTimeouts++
+}
+
+func (m *adapterMetrics) requestDone(command string, result string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requestCounts[requestCountKey{command: command, result: result}]++
+
+	stats, ok := m.requestDurations[command]
+	if !ok {
+		stats = &requestDuration{buckets: make([]int, len(requestDurationBuckets))}
+		m.requestDurations[command] = stats
+	}
+	seconds := duration.Seconds()
+	for i, bound := range requestDurationBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+	stats.count++
+	stats.sum += seconds
+}
+
+// metricCommand returns the command label of the given request. The argument of a subcommand is chosen by the
+// client, only the names of the Hamlib levels and functions are used in the label, all other arguments are counted
+// as "other". This keeps the number of label values bounded.
+func metricCommand(req request) string {
+	if !req.HasSubCommand || len(req.Args) == 0 {
+		return req.Long
+	}
+	name := strings.ToUpper(req.Args[0])
+	var known bool
+	switch req.Long {
+	case "get_level", "set_level":
+		_, known = hamlibLevelBits[name]
+	case "get_func", "set_func":
+		_, known = hamlibFunctionBits[name]
+	}
+	if !known {
+		return req.Long + "_other"
+	}
+	return req.Long + "_" + strings.ToLower(name)
+}
+
+func (m *adapterMetrics) txWatchdogIntervention(trx int, reason string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.txInterventions[txInterventionKey{trx: trx, reason: reason}]++
+}
+
+// write writes all metrics and the given TRX states in the Prometheus text exposition format.
+func (m *adapterMetrics) write(w io.Writer, states []TRXState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeMetricHeader(w, "tciadapter_tci_connected", "gauge", "Indicates if the TCI connection is established.")
+	fmt.Fprintf(w, "tciadapter_tci_connected %d\n", boolToInt(m.tciConnected))
+	writeMetricHeader(w, "tciadapter_tci_reconnects_total", "counter", "Number of reconnects to the TCI host.")
+	fmt.Fprintf(w, "tciadapter_tci_reconnects_total %d\n", max(m.tciConnects-1, 0))
+	writeMetricHeader(w, "tciadapter_tci_timeouts_total", "counter", "Number of TCI requests that timed out.")
+	fmt.Fprintf(w, "tciadapter_tci_timeouts_total %d\n", m.tciTimeouts)
+
+	writeMetricHeader(w, "tciadapter_hamlib_connections", "gauge", "Number of active Hamlib connections.")
+	for _, state := range states {
+		fmt.Fprintf(w, "tciadapter_hamlib_connections{trx=\"%d\"} %d\n", state.TRX, m.hamlibConnections[state.TRX])
+	}
+
+	writeMetricHeader(w, "tciadapter_hamlib_requests_total", "counter", "Number of Hamlib requests by command and result.")
+	countKeys := make([]requestCountKey, 0, len(m.requestCounts))
+	for key := range m.requestCounts {
+		countKeys = append(countKeys, key)
+	}
+	sort.Slice(countKeys, func(i, j int) bool {
+		if countKeys[i].command != countKeys[j].command {
+			return countKeys[i].command < countKeys[j].command
+		}
+		return countKeys[i].result < countKeys[j].result
+	})
+	for _, key := range countKeys {
+		fmt.Fprintf(w, "tciadapter_hamlib_requests_total{command=\"%s\",result=\"%s\"} %d\n", escapeLabel(key.command), escapeLabel(key.result), m.requestCounts[key])
+	}
+
+	writeMetricHeader(w, "tciadapter_hamlib_request_duration_seconds", "histogram", "Duration of the Hamlib requests by command.")
+	commands := make([]string, 0, len(m.requestDurations))
+	for command := range m.requestDurations {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		stats := m.requestDurations[command]
+		label := escapeLabel(command)
+		for i, bound := range requestDurationBuckets {
+			fmt.Fprintf(w, "tciadapter_hamlib_request_duration_seconds_bucket{command=\"%s\",le=\"%s\"} %d\n", label, formatFloat(bound), stats.buckets[i])
+		}
+		fmt.Fprintf(w, "tciadapter_hamlib_request_duration_seconds_bucket{command=\"%s\",le=\"+Inf\"} %d\n", label, stats.count)
+		fmt.Fprintf(w, "tciadapter_hamlib_request_duration_seconds_sum{command=\"%s\"} %s\n", label, formatFloat(stats.sum))
+		fmt.Fprintf(w, "tciadapter_hamlib_request_duration_seconds_count{command=\"%s\"} %d\n", label, stats.count)
+	}
+
+	writeMetricHeader(w, "tciadapter_trx_frequency_hertz", "gauge", "Current frequency of each VFO.")
+	for _, state := range states {
+		for vfo, vfoState := range state.VFOs {
+			fmt.Fprintf(w, "tciadapter_trx_frequency_hertz{trx=\"%d\",vfo=\"%s\"} %d\n", state.TRX, tciToHamlibVFO[tci.VFO(vfo)], vfoState.Frequency)
+		}
+	}
+	writeMetricHeader(w, "tciadapter_trx_mode", "gauge", "Current mode of each TRX, the value is always 1.")
+	for _, state := range states {
+		if state.Mode == "" {
+			continue
+		}
+		fmt.Fprintf(w, "tciadapter_trx_mode{trx=\"%d\",mode=\"%s\"} 1\n", state.TRX, escapeLabel(strings.ToUpper(string(state.Mode))))
+	}
+	writeMetricHeader(w, "tciadapter_trx_ptt", "gauge", "Indicates if the TRX transmits.")
+	for _, state := range states {
+		fmt.Fprintf(w, "tciadapter_trx_ptt{trx=\"%d\"} %d\n", state.TRX, boolToInt(state.Transmitting))
+	}
+
+	writeMetricHeader(w, "tciadapter_tx_watchdog_interventions_total", "counter", "Number of times the TX watchdog unkeyed a TRX, by reason.")
+	interventionKeys := make([]txInterventionKey, 0, len(m.txInterventions))
+	for key := range m.txInterventions {
+		interventionKeys = append(interventionKeys, key)
+	}
+	sort.Slice(interventionKeys, func(i, j int) bool {
+		if interventionKeys[i].trx != interventionKeys[j].trx {
+			return interventionKeys[i].trx < interventionKeys[j].trx
+		}
+		return interventionKeys[i].reason < interventionKeys[j].reason
+	})
+	for _, key := range interventionKeys {
+		fmt.Fprintf(w, "tciadapter_tx_watchdog_interventions_total{trx=\"%d\",reason=\"%s\"} %d\n", key.trx, key.reason, m.txInterventions[key])
+	}
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// ListenMetrics opens the given local address to provide the metrics of the adapter in the Prometheus text format
+// on the path /metrics.
+func (a *Adapter) ListenMetrics(localAddress string) error {
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return fmt.Errorf("cannot open local port %s for metrics: %w", localAddress, err)
+	}
+	log.Printf("providing metrics on http://%s/metrics", listener.Addr())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", a.serveMetrics)
+	httpServer := &http.Server{Handler: a.acceptHTTP("Metrics", mux)}
+
+	go func() {
+		<-a.closed
+		httpServer.Close()
+	}()
+	go func() {
+		err := httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (a *Adapter) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	states := make([]TRXState, len(a.trxListeners))
+	for i, trxListener := range a.trxListeners {
+		states[i] = trxListener.trxData.Snapshot()
+	}
+	var buffer bytes.Buffer
+	a.metrics.write(&buffer, states)
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(buffer.Bytes())
+}
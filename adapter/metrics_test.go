@@ -0,0 +1,96 @@
+package adapter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Write(t *testing.T) {
+	metrics := newAdapterMetrics()
+	metrics.Connected(true)
+	metrics.Connected(false)
+	metrics.Connected(true)
+	metrics.hamlibConnectionOpened(0)
+	metrics.hamlibConnectionOpened(0)
+	metrics.hamlibConnectionClosed(0)
+	metrics.tciTimeout()
+	metrics.requestDone("get_freq", "0", 2*time.Millisecond)
+	metrics.requestDone("set_freq", "-1", 60*time.Millisecond)
+	metrics.requestDone("get_freq", "0", 500*time.Microsecond)
+
+	state := TRXState{TRX: 0, Mode: tci.ModeUSB, Transmitting: true}
+	state.VFOs[0].Frequency = 14074000
+	var buffer strings.Builder
+	metrics.write(&buffer, []TRXState{state})
+	text := buffer.String()
+
+	assert.Contains(t, text, "tciadapter_tci_connected 1\n")
+	assert.Contains(t, text, "tciadapter_tci_reconnects_total 1\n")
+	assert.Contains(t, text, "tciadapter_tci_timeouts_total 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_connections{trx=\"0\"} 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_requests_total{command=\"get_freq\",result=\"0\"} 2\n")
+	assert.Contains(t, text, "tciadapter_hamlib_requests_total{command=\"set_freq\",result=\"-1\"} 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_request_duration_seconds_bucket{command=\"get_freq\",le=\"0.001\"} 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_request_duration_seconds_bucket{command=\"get_freq\",le=\"0.005\"} 2\n")
+	assert.Contains(t, text, "tciadapter_hamlib_request_duration_seconds_bucket{command=\"set_freq\",le=\"0.05\"} 0\n")
+	assert.Contains(t, text, "tciadapter_hamlib_request_duration_seconds_count{command=\"set_freq\"} 1\n")
+	assert.Contains(t, text, "tciadapter_trx_frequency_hertz{trx=\"0\",vfo=\"VFOA\"} 14074000\n")
+	assert.Contains(t, text, "tciadapter_trx_mode{trx=\"0\",mode=\"USB\"} 1\n")
+	assert.Contains(t, text, "tciadapter_trx_ptt{trx=\"0\"} 1\n")
+}
+
+func TestMetricCommand(t *testing.T) {
+	tt := []struct {
+		request  string
+		expected string
+	}{
+		{`f`, "get_freq"},
+		{`\set_freq 7074000`, "set_freq"},
+		{`L KEYSPD 20`, "set_level_keyspd"},
+		{`l strength`, "get_level_strength"},
+		{`L x1 1`, "set_level_other"},
+		{`U NB 1`, "set_func_nb"},
+		{`u foo`, "get_func_other"},
+		{`P FOO 1`, "set_parm_other"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.request, func(t *testing.T) {
+			req, err := nextRequest(bytes.NewBufferString(tc.request), false)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, metricCommand(req))
+		})
+	}
+}
+
+func TestE2E_Metrics(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	_, err := rig.Frequency(e2eContext(t))
+	require.NoError(t, err)
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		setup.adapter.serveMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, metricsContentType, recorder.Header().Get("Content-Type"))
+		body, err := io.ReadAll(recorder.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	text := scrape()
+	assert.Contains(t, text, "tciadapter_tci_connected 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_connections{trx=\"0\"} 1\n")
+	assert.Contains(t, text, "tciadapter_hamlib_requests_total{command=\"get_freq\",result=\"0\"} 1\n")
+	assert.Contains(t, text, "tciadapter_trx_frequency_hertz{trx=\"0\",vfo=\"VFOA\"} 7074000\n")
+}
@@ -0,0 +1,317 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+)
+
+// ModeDirection selects in which direction a mode rule is used.
+type ModeDirection int
+
+// All directions of a mode rule.
+const (
+	BothDirections ModeDirection = iota
+	HamlibToTCI
+	TCIToHamlib
+)
+
+// ModeRule maps a Hamlib mode onto a TCI mode and back. The rules are checked before the default mapping of the
+// adapter, the first matching rule is used.
+type ModeRule struct {
+	Hamlib    hamlib.Mode   `json:"hamlib"`
+	TCI       tci.Mode      `json:"tci"`
+	Direction ModeDirection `json:"direction,omitempty"`
+	// Reverse mirrors the RX filter of the TCI mode to the other side of the carrier, e.g. to map CWR onto CW.
+	// From TCI to Hamlib, the rule only matches if the RX filter is mirrored.
+	Reverse bool `json:"reverse,omitempty"`
+	// Bands limits the rule to these frequency ranges. Without bands, the rule applies to all frequencies.
+	Bands []Band `json:"bands,omitempty"`
+}
+
+func (r ModeRule) toTCI() bool {
+	return r.Direction != TCIToHamlib
+}
+
+func (r ModeRule) toHamlib() bool {
+	return r.Direction != HamlibToTCI
+}
+
+func (r ModeRule) appliesTo(frequency int) bool {
+	if len(r.Bands) == 0 {
+		return true
+	}
+	for _, band := range r.Bands {
+		if band.Contains(frequency) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks if the rule maps a known Hamlib mode onto a TCI mode.
+func (r ModeRule) Validate() error {
+	if _, ok := hamlibModeBits[r.Hamlib]; !ok {
+		return fmt.Errorf("unknown Hamlib mode %s", r.Hamlib)
+	}
+	if r.TCI == tci.ModeNone {
+		return fmt.Errorf("no TCI mode for %s", r.Hamlib)
+	}
+	return nil
+}
+
+func (r ModeRule) String() string {
+	result := fmt.Sprintf("%s=%s", r.Hamlib, strings.ToUpper(string(r.TCI)))
+	if r.Reverse {
+		result += " (reverse)"
+	}
+	return result
+}
+
+// Band is a frequency range in Hz.
+type Band struct {
+	Name string `json:"name,omitempty"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+func (b Band) Contains(frequency int) bool {
+	return b.From <= frequency && frequency <= b.To
+}
+
+// amateurBands contains the amateur radio bands, with the widest limits of the three IARU regions.
+var amateurBands = []Band{
+	{"160m", 1800000, 2000000},
+	{"80m", 3500000, 4000000},
+	{"60m", 5250000, 5450000},
+	{"40m", 7000000, 7300000},
+	{"30m", 10100000, 10150000},
+	{"20m", 14000000, 14350000},
+	{"17m", 18068000, 18168000},
+	{"15m", 21000000, 21450000},
+	{"12m", 24890000, 24990000},
+	{"10m", 28000000, 29700000},
+	{"6m", 50000000, 54000000},
+	{"4m", 70000000, 70500000},
+	{"2m", 144000000, 148000000},
+	{"70cm", 420000000, 450000000},
+}
+
+// FindBand returns the amateur radio band with the given name, e.g. "40m".
+func FindBand(name string) (Band, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, band := range amateurBands {
+		if band.Name == name {
+			return band, true
+		}
+	}
+	return Band{}, false
+}
+
+// modeRules are the mode rules of one connection, the rules of the client come before the global rules.
+type modeRules []ModeRule
+
+// tciMode returns the TCI mode for the given Hamlib mode on the given frequency. If the rule that was used
+// reverses the RX filter, reverse is true.
+func (r modeRules) tciMode(mode hamlib.Mode, frequency int) (result tci.Mode, reverse bool, ok bool) {
+	for _, rule := range r {
+		if rule.toTCI() && rule.Hamlib == mode && rule.appliesTo(frequency) {
+			return rule.TCI, rule.Reverse, true
+		}
+	}
+	result, ok = hamlibToTCIMode[mode]
+	return result, false, ok
+}
+
+// hamlibMode returns the Hamlib mode for the given TCI mode on the given frequency.
+func (r modeRules) hamlibMode(mode tci.Mode, frequency int, reversed bool) (hamlib.Mode, bool) {
+	for _, rule := range r {
+		if rule.toHamlib() && rule.TCI == mode && (!rule.Reverse || reversed) && rule.appliesTo(frequency) {
+			return rule.Hamlib, true
+		}
+	}
+	result, ok := tciToHamlibMode[mode]
+	return result, ok
+}
+
+// stateMode returns the Hamlib mode of the given TRX state, as seen from the given VFO.
+func (r modeRules) stateMode(state TRXState, vfo tci.VFO) (hamlib.Mode, bool) {
+	return r.hamlibMode(state.Mode, state.VFOFrequency(vfo), reversedFilter(state.Mode, state.RXFilterMin, state.RXFilterMax))
+}
+
+// hamlibModes returns the Hamlib modes that can be mapped onto the given TCI modes on any frequency, ordered like in
+// rig.h.
+func (r modeRules) hamlibModes(tciModes []tci.Mode, noDigimodes bool) []hamlib.Mode {
+	available := make(map[tci.Mode]bool)
+	if len(tciModes) == 0 {
+		for mode := range tciToHamlibMode {
+			available[mode] = true
+		}
+	}
+	for _, mode := range tciModes {
+		available[tci.Mode(strings.ToLower(string(mode)))] = true
+	}
+	isAvailable := func(mode tci.Mode) bool {
+		if noDigimodes {
+			mode = overrideDigimode(mode)
+		}
+		return available[mode]
+	}
+
+	result := make([]hamlib.Mode, 0, len(hamlibModeBits))
+	for hamlibMode := range hamlibModeBits {
+		found := false
+		for _, rule := range r {
+			if rule.toTCI() && rule.Hamlib == hamlibMode && isAvailable(rule.TCI) {
+				found = true
+				break
+			}
+		}
+		if tciMode, ok := hamlibToTCIMode[hamlibMode]; !found && ok {
+			found = isAvailable(tciMode)
+		}
+		if found {
+			result = append(result, hamlibMode)
+		}
+	}
+	sortHamlibModes(result)
+	return result
+}
+
+// overrideDigimode replaces the digital modes DIGL/DIGU with LSB/USB.
+func overrideDigimode(mode tci.Mode) tci.Mode {
+	switch mode {
+	case tci.ModeDIGL:
+		return tci.ModeLSB
+	case tci.ModeDIGU:
+		return tci.ModeUSB
+	default:
+		return mode
+	}
+}
+
+// reversedFilter indicates if the RX filter of the given mode is mirrored to the other side of the carrier.
+func reversedFilter(mode tci.Mode, min, max int) bool {
+	switch mode {
+	case tci.ModeLSB, tci.ModeDIGL:
+		return min >= 0 && max > 0
+	case tci.ModeUSB, tci.ModeDIGU, tci.ModeCW:
+		return max <= 0 && min < 0
+	default:
+		return false
+	}
+}
+
+// supportsMode indicates if the TCI server announced the given mode in its modulations list, regardless of the case.
+// Without modulations list, all modes are supported.
+func supportsMode(tciModes []tci.Mode, mode tci.Mode) bool {
+	if len(tciModes) == 0 {
+		return true
+	}
+	for _, tciMode := range tciModes {
+		if strings.EqualFold(string(tciMode), string(mode)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedModeRules returns the rules in the given settings that use a TCI mode which is not in the modulations
+// list of the TCI server.
+func unsupportedModeRules(settings *Settings, tciModes []tci.Mode) []ModeRule {
+	var result []ModeRule
+	check := func(rules []ModeRule) {
+		for _, rule := range rules {
+			if !supportsMode(tciModes, rule.TCI) {
+				result = append(result, rule)
+			}
+		}
+	}
+	check(settings.Modes)
+	for _, client := range settings.Clients {
+		check(client.Modes)
+	}
+	return result
+}
+
+func (a *Adapter) validateModes(settings *Settings, tciModes []tci.Mode) {
+	for _, rule := range unsupportedModeRules(settings, tciModes) {
+		log.Printf("mode rule %s: the TCI host does not support %s", rule, strings.ToUpper(string(rule.TCI)))
+	}
+}
+
+// hamlibMode returns the Hamlib mode of the given TRX state for this connection.
+func (c *inboundConnection) hamlibMode(state TRXState) (hamlib.Mode, error) {
+	result, ok := c.settings.current().modes.stateMode(state, c.getCurrentVFO())
+	if !ok {
+		return "", notAvailable("unknown mode %s", strings.ToUpper(string(state.Mode)))
+	}
+	return result, nil
+}
+
+// tciMode returns the TCI mode for the given Hamlib mode for this connection, on the current frequency.
+func (c *inboundConnection) tciMode(mode hamlib.Mode) (tci.Mode, bool, error) {
+	return c.settings.current().tciMode(mode, c.trxData.VFOFrequency(c.getCurrentVFO()), c.tciDevice.modes())
+}
+
+// tciMode returns the TCI mode for the given Hamlib mode on the given frequency, using the mode rules of these
+// settings. The TCI host must support the resulting mode.
+func (s connectionSettings) tciMode(mode hamlib.Mode, frequency int, tciModes []tci.Mode) (tci.Mode, bool, error) {
+	result, reverse, ok := s.modes.tciMode(mode, frequency)
+	if !ok {
+		return tci.ModeNone, false, invalidArgument("unknown mode %s", mode)
+	}
+	result, err := s.availableTCIMode(result, tciModes)
+	if err != nil {
+		return tci.ModeNone, false, err
+	}
+	return result, reverse, nil
+}
+
+// availableTCIMode applies the digimode override of these settings to the given TCI mode. The TCI host must support
+// the resulting mode.
+func (s connectionSettings) availableTCIMode(mode tci.Mode, tciModes []tci.Mode) (tci.Mode, error) {
+	if s.noDigimodes {
+		mode = overrideDigimode(mode)
+	}
+	if !supportsMode(tciModes, mode) {
+		return tci.ModeNone, notAvailable("the TCI host does not support mode %s", strings.ToUpper(string(mode)))
+	}
+	return mode, nil
+}
+
+var hamlibToTCIMode = map[hamlib.Mode]tci.Mode{
+	hamlib.ModeUSB:     tci.ModeUSB,
+	hamlib.ModeLSB:     tci.ModeLSB,
+	hamlib.ModeCW:      tci.ModeCW,
+	hamlib.ModeCWR:     tci.ModeCW,
+	hamlib.ModeRTTY:    tci.ModeDIGU,
+	hamlib.ModeRTTYR:   tci.ModeDIGL,
+	hamlib.ModeAM:      tci.ModeAM,
+	hamlib.ModeFM:      tci.ModeNFM,
+	hamlib.ModeWFM:     tci.ModeWFM,
+	hamlib.ModePKTLSB:  tci.ModeDIGL,
+	hamlib.ModePKTUSB:  tci.ModeDIGU,
+	hamlib.ModePKTFM:   tci.ModeNFM,
+	hamlib.ModeECSSLSB: tci.ModeDIGL,
+	hamlib.ModeECSSUSB: tci.ModeDIGU,
+	hamlib.ModeFAX:     tci.ModeDIGU,
+	hamlib.ModeSAM:     tci.ModeSAM,
+	hamlib.ModeDSB:     tci.ModeDSB,
+}
+
+var tciToHamlibMode = map[tci.Mode]hamlib.Mode{
+	tci.ModeAM:   hamlib.ModeAM,
+	tci.ModeSAM:  hamlib.ModeSAM,
+	tci.ModeDSB:  hamlib.ModeDSB,
+	tci.ModeLSB:  hamlib.ModeLSB,
+	tci.ModeUSB:  hamlib.ModeUSB,
+	tci.ModeCW:   hamlib.ModeCW,
+	tci.ModeNFM:  hamlib.ModeFM,
+	tci.ModeWFM:  hamlib.ModeWFM,
+	tci.ModeDIGL: hamlib.ModePKTLSB,
+	tci.ModeDIGU: hamlib.ModePKTUSB,
+}
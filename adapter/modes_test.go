@@ -0,0 +1,133 @@
+package adapter
+
+import (
+	"testing"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeRules_TCIMode(t *testing.T) {
+	band40m, _ := FindBand("40m")
+	rules := modeRules{
+		{Hamlib: hamlib.ModeRTTY, TCI: tci.ModeDIGL, Bands: []Band{band40m}},
+		{Hamlib: hamlib.ModeCWR, TCI: tci.ModeCW, Reverse: true},
+		{Hamlib: hamlib.ModePKTFM, TCI: tci.ModeWFM},
+		{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB, Direction: HamlibToTCI},
+		{Hamlib: hamlib.ModePKTLSB, TCI: tci.ModeLSB, Direction: TCIToHamlib},
+	}
+
+	tt := []struct {
+		name            string
+		mode            hamlib.Mode
+		frequency       int
+		expected        tci.Mode
+		expectedReverse bool
+		expectedOK      bool
+	}{
+		{"default", hamlib.ModeUSB, 14074000, tci.ModeUSB, false, true},
+		{"band rule in band", hamlib.ModeRTTY, 7040000, tci.ModeDIGL, false, true},
+		{"band rule outside of band", hamlib.ModeRTTY, 14080000, tci.ModeDIGU, false, true},
+		{"reverse", hamlib.ModeCWR, 14020000, tci.ModeCW, true, true},
+		{"other FM variant", hamlib.ModePKTFM, 145000000, tci.ModeWFM, false, true},
+		{"to TCI only", hamlib.ModeFAX, 14230000, tci.ModeUSB, false, true},
+		{"to Hamlib only", hamlib.ModePKTLSB, 7074000, tci.ModeDIGL, false, true},
+		{"unknown", hamlib.Mode("XYZ"), 14074000, tci.ModeNone, false, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, reverse, ok := rules.tciMode(tc.mode, tc.frequency)
+			assert.Equal(t, tc.expected, mode)
+			assert.Equal(t, tc.expectedReverse, reverse)
+			assert.Equal(t, tc.expectedOK, ok)
+		})
+	}
+}
+
+func TestModeRules_HamlibMode(t *testing.T) {
+	band40m, _ := FindBand("40m")
+	rules := modeRules{
+		{Hamlib: hamlib.ModeRTTY, TCI: tci.ModeDIGL, Bands: []Band{band40m}},
+		{Hamlib: hamlib.ModeCWR, TCI: tci.ModeCW, Reverse: true},
+		{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB, Direction: HamlibToTCI},
+		{Hamlib: hamlib.ModeLSB, TCI: tci.ModeDIGL, Direction: TCIToHamlib},
+	}
+
+	tt := []struct {
+		name       string
+		mode       tci.Mode
+		frequency  int
+		reversed   bool
+		expected   hamlib.Mode
+		expectedOK bool
+	}{
+		{"default", tci.ModeDIGU, 14074000, false, hamlib.ModePKTUSB, true},
+		{"band rule in band", tci.ModeDIGL, 7040000, false, hamlib.ModeRTTY, true},
+		{"band rule outside of band", tci.ModeDIGL, 3580000, false, hamlib.ModeLSB, true},
+		{"reversed", tci.ModeCW, 14020000, true, hamlib.ModeCWR, true},
+		{"not reversed", tci.ModeCW, 14020000, false, hamlib.ModeCW, true},
+		{"to TCI only", tci.ModeUSB, 14230000, false, hamlib.ModeUSB, true},
+		{"unknown", tci.ModeDRM, 6000000, false, "", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, ok := rules.hamlibMode(tc.mode, tc.frequency, tc.reversed)
+			assert.Equal(t, tc.expected, mode)
+			assert.Equal(t, tc.expectedOK, ok)
+		})
+	}
+}
+
+func TestModeRules_HamlibModes(t *testing.T) {
+	rules := modeRules{
+		{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB},
+	}
+
+	assert.Equal(t, []hamlib.Mode{hamlib.ModeUSB, hamlib.ModeLSB, hamlib.ModeFAX}, rules.hamlibModes([]tci.Mode{"LSB", "USB"}, false))
+	assert.Equal(t, []hamlib.Mode{hamlib.ModeUSB, hamlib.ModeLSB, hamlib.ModeRTTY, hamlib.ModeRTTYR, hamlib.ModePKTLSB, hamlib.ModePKTUSB, hamlib.ModeECSSUSB, hamlib.ModeECSSLSB, hamlib.ModeFAX}, rules.hamlibModes([]tci.Mode{"lsb", "usb"}, true))
+}
+
+func TestReversedFilter(t *testing.T) {
+	tt := []struct {
+		mode     tci.Mode
+		min, max int
+		expected bool
+	}{
+		{tci.ModeCW, 450, 950, false},
+		{tci.ModeCW, -950, -450, true},
+		{tci.ModeUSB, 300, 2700, false},
+		{tci.ModeLSB, -2700, -300, false},
+		{tci.ModeLSB, 300, 2700, true},
+		{tci.ModeAM, -3000, 3000, false},
+	}
+	for _, tc := range tt {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			assert.Equal(t, tc.expected, reversedFilter(tc.mode, tc.min, tc.max))
+		})
+	}
+}
+
+func TestUnsupportedModeRules(t *testing.T) {
+	settings := &Settings{
+		Modes: []ModeRule{{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB}},
+		Clients: []ClientSettings{
+			{Modes: []ModeRule{{Hamlib: hamlib.ModePKTFM, TCI: tci.ModeWFM}}},
+		},
+	}
+
+	assert.Empty(t, unsupportedModeRules(settings, nil))
+	assert.Empty(t, unsupportedModeRules(settings, []tci.Mode{"USB", "WFM"}))
+	assert.Equal(t, []ModeRule{{Hamlib: hamlib.ModePKTFM, TCI: tci.ModeWFM}}, unsupportedModeRules(settings, []tci.Mode{"USB", "LSB"}))
+}
+
+// testModeRules maps PKTUSB onto USB on 40m, PKTLSB onto the unsupported SPEC mode, and CWR onto CW with a mirrored
+// RX filter.
+func testModeRules() []ModeRule {
+	band40m, _ := FindBand("40m")
+	return []ModeRule{
+		{Hamlib: hamlib.ModePKTUSB, TCI: tci.ModeUSB, Bands: []Band{band40m}},
+		{Hamlib: hamlib.ModePKTLSB, TCI: tci.ModeSPEC},
+		{Hamlib: hamlib.ModeCWR, TCI: tci.ModeCW, Reverse: true},
+	}
+}
@@ -0,0 +1,180 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"net"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// multicastInterval is the maximum time between two state packets, even if the state does not change.
+const multicastInterval = 5 * time.Second
+
+// multicastPacket is the JSON state packet that is published through UDP, it follows the format of the multicast
+// data publisher of rigctld. Like rigctld, the CRC is the CRC-32 of the packet with the CRC set to 0.
+type multicastPacket struct {
+	App     string         `json:"app"`
+	Version string         `json:"version"`
+	Seq     uint64         `json:"seq"`
+	Time    string         `json:"time"`
+	CRC     uint32         `json:"crc"`
+	Rig     multicastRig   `json:"rig"`
+	VFOs    []multicastVFO `json:"vfos"`
+}
+
+type multicastRig struct {
+	ID       multicastRigID `json:"id"`
+	Status   string         `json:"status"`
+	ErrorMsg string         `json:"errorMsg"`
+	Name     string         `json:"name"`
+	Split    bool           `json:"split"`
+	SplitVFO string         `json:"splitVfo"`
+	SatMode  bool           `json:"satMode"`
+	Modes    []string       `json:"modes"`
+}
+
+type multicastRigID struct {
+	Model    string `json:"model"`
+	Endpoint string `json:"endpoint"`
+	Process  string `json:"process"`
+}
+
+type multicastVFO struct {
+	Name  string `json:"name"`
+	Freq  int    `json:"freq"`
+	Mode  string `json:"mode"`
+	Width int    `json:"width"`
+	PTT   bool   `json:"ptt"`
+	RX    bool   `json:"rx"`
+	TX    bool   `json:"tx"`
+}
+
+// PublishMulticast publishes the state of each TRX to the given UDP address (usually a multicast group like
+// 224.0.0.1:4532) whenever the frequency, mode, PTT or split state changes.
+func (a *Adapter) PublishMulticast(address string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return fmt.Errorf("invalid multicast address %s: %w", address, err)
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return fmt.Errorf("cannot open multicast connection to %s: %w", address, err)
+	}
+	log.Printf("publishing the TRX state to %s", udpAddr)
+
+	go func() {
+		<-a.closed
+		conn.Close()
+	}()
+	for _, trxListener := range a.trxListeners {
+		go a.publishState(conn, trxListener)
+	}
+	return nil
+}
+
+func (a *Adapter) publishState(conn net.Conn, trxListener *trxListener) {
+	changes, unsubscribe := trxListener.trxData.Subscribe()
+	defer unsubscribe()
+	ticker := time.NewTicker(multicastInterval)
+	defer ticker.Stop()
+
+	var seq uint64
+	var last asyncState
+	publish := func(state TRXState) {
+		seq++
+		packet := a.multicastPacket(state, trxListener.Addr().String(), seq)
+		bytes, err := packet.marshal()
+		if err != nil {
+			log.Printf("cannot marshal multicast packet: %v", err)
+			return
+		}
+		_, err = conn.Write(bytes)
+		if err != nil {
+			log.Printf("cannot publish multicast packet: %v", err)
+		}
+		last = newAsyncState(state)
+	}
+
+	publish(trxListener.trxData.Snapshot())
+	for {
+		select {
+		case <-a.closed:
+			return
+		case <-ticker.C:
+			publish(trxListener.trxData.Snapshot())
+		case <-changes:
+			state := trxListener.trxData.Snapshot()
+			if newAsyncState(state) != last {
+				publish(state)
+			}
+		}
+	}
+}
+
+// marshal returns the JSON encoding of the packet with its CRC.
+func (p multicastPacket) marshal() ([]byte, error) {
+	p.CRC = 0
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	p.CRC = crc32.ChecksumIEEE(bytes)
+	return json.Marshal(p)
+}
+
+func (a *Adapter) multicastPacket(state TRXState, endpoint string, seq uint64) multicastPacket {
+	device := a.tciDevice.get()
+	name := "tciadapter"
+	if device.DeviceName != "" {
+		name = device.DeviceName
+	}
+	settings := a.connectionSettings(state.TRX, nil)
+	modes := settings.modes.hamlibModes(device.Modes, settings.noDigimodes)
+	modeNames := make([]string, len(modes))
+	for i, mode := range modes {
+		modeNames[i] = string(mode)
+	}
+
+	txVFO := tci.VFOA
+	if state.SplitEnabled {
+		txVFO = tci.VFOB
+	}
+	vfos := make([]multicastVFO, len(state.VFOs))
+	for i, data := range state.VFOs {
+		vfo := tci.VFO(i)
+		mode, _ := settings.modes.stateMode(state, vfo)
+		vfos[i] = multicastVFO{
+			Name:  string(tciToHamlibVFO[vfo]),
+			Freq:  data.Frequency,
+			Mode:  string(mode),
+			Width: state.Passband(),
+			PTT:   state.Transmitting && vfo == txVFO,
+			RX:    vfo == tci.VFOA,
+			TX:    vfo == txVFO,
+		}
+	}
+
+	return multicastPacket{
+		App:     "tciadapter",
+		Version: a.version,
+		Seq:     seq,
+		Time:    time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		Rig: multicastRig{
+			ID: multicastRigID{
+				Model:    "tciadapter",
+				Endpoint: endpoint,
+				Process:  fmt.Sprintf("TRX %d", state.TRX),
+			},
+			Status:   "OK",
+			Name:     name,
+			Split:    state.SplitEnabled,
+			SplitVFO: string(tciToHamlibVFO[txVFO]),
+			Modes:    modeNames,
+		},
+		VFOs: vfos,
+	}
+}
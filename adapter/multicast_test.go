@@ -0,0 +1,43 @@
+package adapter
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestE2E_PublishMulticast(t *testing.T) {
+	setup := startE2E(t, 0)
+	receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { receiver.Close() })
+
+	require.NoError(t, setup.adapter.PublishMulticast(receiver.LocalAddr().String()))
+	receiver.SetReadDeadline(time.Now().Add(e2eTimeout))
+	buffer := make([]byte, 65536)
+	n, err := receiver.Read(buffer)
+	require.NoError(t, err)
+	data := buffer[:n]
+
+	var packet multicastPacket
+	require.NoError(t, json.Unmarshal(data, &packet))
+	assert.Equal(t, "tciadapter", packet.App)
+	assert.Equal(t, uint64(1), packet.Seq)
+	assert.Equal(t, "TRX 0", packet.Rig.ID.Process)
+	assert.Equal(t, setup.adapter.Addr(0).String(), packet.Rig.ID.Endpoint)
+	require.Len(t, packet.VFOs, 2)
+	assert.Equal(t, "VFOA", packet.VFOs[0].Name)
+	assert.Equal(t, 7074000, packet.VFOs[0].Freq)
+	assert.Equal(t, "USB", packet.VFOs[0].Mode)
+	assert.True(t, packet.VFOs[0].RX)
+
+	withoutCRC := regexp.MustCompile(`"crc":\d+`).ReplaceAll(data, []byte(`"crc":0`))
+	assert.Equal(t, crc32.ChecksumIEEE(withoutCRC), packet.CRC)
+	assert.NotZero(t, packet.CRC)
+}
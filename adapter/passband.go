@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"strconv"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+)
+
+// Special passband values of Hamlib's set_mode command.
+const (
+	passbandNormal   = 0
+	passbandNoChange = -1
+)
+
+// sidebandCenter is the audio frequency in Hz on which the passband of the sideband modes is centered.
+const sidebandCenter = 1500
+
+// defaultPassbands contains the normal passband in Hz for each TCI mode. A passband of 0 means
+// that the filter is not changed for this mode.
+var defaultPassbands = map[tci.Mode]int{
+	tci.ModeAM:   6000,
+	tci.ModeSAM:  6000,
+	tci.ModeDSB:  6000,
+	tci.ModeLSB:  2400,
+	tci.ModeUSB:  2400,
+	tci.ModeCW:   500,
+	tci.ModeNFM:  12000,
+	tci.ModeWFM:  0,
+	tci.ModeDIGL: 3000,
+	tci.ModeDIGU: 3000,
+}
+
+// filterBand returns the RX filter band for the given mode and passband. The band is centered on the
+// sideband center for LSB/USB, on the CW pitch for CW, and on the carrier for all other modes.
+func filterBand(mode tci.Mode, passband int, cwPitch int) (int, int) {
+	switch mode {
+	case tci.ModeUSB, tci.ModeDIGU:
+		low := max(sidebandCenter-passband/2, 0)
+		return low, low + passband
+	case tci.ModeLSB, tci.ModeDIGL:
+		low := max(sidebandCenter-passband/2, 0)
+		return -(low + passband), -low
+	case tci.ModeCW:
+		low := max(cwPitch-passband/2, 0)
+		return low, low + passband
+	default:
+		return -passband / 2, passband / 2
+	}
+}
+
+// parsePassband parses the passband argument of a Hamlib request.
+func parsePassband(hamlibPassband string) (int, error) {
+	passband, err := strconv.Atoi(hamlibPassband)
+	if err != nil {
+		return 0, invalidArgument("invalid passband: %w", err)
+	}
+	return passband, nil
+}
+
+// setMode sets the mode and the passband of the TRX according to the given Hamlib arguments. If the mode rule
+// reverses the sideband, the RX filter is mirrored to the other side of the carrier. Without passband change, the
+// width of the RX filter is kept.
+func (c *inboundConnection) setMode(hamlibMode string, passband int) error {
+	mode, reverse, err := c.tciMode(hamlib.Mode(hamlibMode))
+	if err != nil {
+		return err
+	}
+	err = c.checkTXChange(func(s *TRXState) { s.Mode = mode })
+	if err != nil {
+		return err
+	}
+
+	if passband < passbandNormal {
+		return setModeKeepingRXFilter(c.tciClient, c.trxData, mode, reverse)
+	}
+	err = c.tciClient.SetMode(c.trxData.trx, mode)
+	if err != nil {
+		return tciCommandFailed(err)
+	}
+
+	if passband == passbandNormal {
+		passband = defaultPassbands[mode]
+		if passband == 0 {
+			return nil
+		}
+	}
+	min, max := filterBand(mode, passband, c.trxData.CWPitch())
+	if reverse {
+		min, max = -max, -min
+	}
+	err = c.tciClient.SetRXFilterBand(c.trxData.trx, min, max)
+	if err != nil {
+		return tciCommandFailed(err)
+	}
+	return nil
+}
+
+// setModeKeepingRXFilter sets the mode of the TRX and keeps the width of the RX filter, but places the filter on the
+// side of the carrier that belongs to the new mode, e.g. when switching between CW and CWR or between USB and LSB. A
+// filter that spans both sides of the carrier is kept as it is.
+func setModeKeepingRXFilter(tciClient *tci.Client, trxData *TRXData, mode tci.Mode, reverse bool) error {
+	// the filter is taken before the TCI host reports the new mode and maybe a new filter
+	state := trxData.Snapshot()
+	err := tciClient.SetMode(trxData.trx, mode)
+	if err != nil {
+		return tciCommandFailed(err)
+	}
+
+	var lowerSide bool
+	switch mode {
+	case tci.ModeLSB, tci.ModeDIGL:
+		lowerSide = !reverse
+	case tci.ModeUSB, tci.ModeDIGU, tci.ModeCW:
+		lowerSide = reverse
+	default:
+		return nil
+	}
+	onLowerSide := state.RXFilterMax <= 0 && state.RXFilterMin < 0
+	onUpperSide := state.RXFilterMin >= 0 && state.RXFilterMax > 0
+	if lowerSide && !onUpperSide || !lowerSide && !onLowerSide {
+		return nil
+	}
+	err = tciClient.SetRXFilterBand(trxData.trx, -state.RXFilterMax, -state.RXFilterMin)
+	if err != nil {
+		return tciCommandFailed(err)
+	}
+	return nil
+}
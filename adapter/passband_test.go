@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterBand(t *testing.T) {
+	tt := []struct {
+		mode        tci.Mode
+		passband    int
+		expectedMin int
+		expectedMax int
+	}{
+		{tci.ModeUSB, 2400, 300, 2700},
+		{tci.ModeUSB, 3000, 0, 3000},
+		{tci.ModeUSB, 4000, 0, 4000},
+		{tci.ModeDIGU, 500, 1250, 1750},
+		{tci.ModeLSB, 2400, -2700, -300},
+		{tci.ModeDIGL, 3000, -3000, 0},
+		{tci.ModeCW, 500, 350, 850},
+		{tci.ModeCW, 1500, 0, 1500},
+		{tci.ModeAM, 6000, -3000, 3000},
+		{tci.ModeNFM, 12000, -6000, 6000},
+	}
+	for _, tc := range tt {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			min, max := filterBand(tc.mode, tc.passband, 600)
+
+			assert.Equal(t, tc.expectedMin, min, "min")
+			assert.Equal(t, tc.expectedMax, max, "max")
+		})
+	}
+}
+
+func TestSetModeWithPassband(t *testing.T) {
+	setup := startE2E(t, 0)
+	setup.adapter.Reconfigure(Settings{Modes: []ModeRule{{Hamlib: "CWR", TCI: tci.ModeCW, Reverse: true}}})
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// the requests are sent in this order on the same connection
+	tt := []struct {
+		request      string
+		expectedMode string
+		expectedMin  int
+		expectedMax  int
+	}{
+		{`M USB 0`, "usb", 300, 2700},
+		{`M USB 3000`, "usb", 0, 3000},
+		{`M LSB 1800`, "lsb", -2400, -600},
+		{`M CW 0`, "cw", 350, 850},
+		{`M CWR -1`, "cw", -850, -350},
+		{`M CW -1`, "cw", 350, 850},
+		{`M CWR 200`, "cw", -700, -500},
+		{`M AM 0`, "am", -3000, 3000},
+		{`M WFM 0`, "wfm", -3000, 3000},
+		{`M USB 2400`, "usb", 300, 2700},
+		{`X PKTUSB 0`, "digu", 300, 2700},
+		{`X PKTLSB 2000`, "digl", -2700, -300},
+	}
+	trxData := setup.adapter.trxListener(0).trxData
+	for _, tc := range tt {
+		assert.Equal(t, "RPRT 0", client.request(t, tc.request), tc.request)
+		assert.Eventually(t, func() bool {
+			min, max := setup.sdr.RXFilterBand(0)
+			return setup.sdr.Mode(0) == tc.expectedMode && min == tc.expectedMin && max == tc.expectedMax
+		}, e2eTimeout, e2eTick, tc.request)
+		// the next request starts from the state that the adapter knows
+		assert.Eventually(t, func() bool {
+			state := trxData.Snapshot()
+			return string(state.Mode) == tc.expectedMode && state.RXFilterMin == tc.expectedMin && state.RXFilterMax == tc.expectedMax
+		}, e2eTimeout, e2eTick, tc.request)
+	}
+}
@@ -0,0 +1,140 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// The streams of a recording.
+const (
+	streamSession = "session"
+	streamHamlib  = "hamlib"
+	streamTCI     = "tci"
+)
+
+// The directions of the recorded messages, as seen from the adapter.
+const (
+	directionRX = "rx" // received by the adapter
+	directionTX = "tx" // sent by the adapter
+)
+
+// RecordedEvent is one entry of a recording. A recording is stored as JSON lines, one event per line.
+type RecordedEvent struct {
+	Time      time.Time        `json:"time"`
+	Stream    string           `json:"stream"`
+	Direction string           `json:"dir,omitempty"`
+	Conn      int64            `json:"conn,omitempty"`
+	TRX       int              `json:"trx"`
+	Data      string           `json:"data,omitempty"`
+	Session   *RecordedSession `json:"session,omitempty"`
+}
+
+// RecordedSession describes the adapter and its effective settings. A recording starts with a session, each
+// reconfiguration of the adapter records another session. The password is redacted and the TLS configuration is not
+// recorded.
+type RecordedSession struct {
+	Version  string   `json:"version"`
+	TRX      []int    `json:"trx"`
+	Settings Settings `json:"settings"`
+}
+
+// recordedSession returns the session of the adapter with the given settings.
+func (a *Adapter) recordedSession(settings Settings) *RecordedSession {
+	result := &RecordedSession{
+		Version:  a.version,
+		Settings: settings,
+	}
+	for _, trxListener := range a.trxListeners {
+		result.TRX = append(result.TRX, trxListener.trxData.trx)
+	}
+	if result.Settings.Password != "" {
+		result.Settings.Password = redactedPassword
+	}
+	result.Settings.TLS = nil
+	return result
+}
+
+// Recorder writes a timestamped capture of the Hamlib and the TCI communication of the adapter.
+type Recorder struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+	closed  bool
+}
+
+// NewRecorder returns a recorder that writes the recording to the given writer.
+func NewRecorder(w io.Writer) *Recorder {
+	result := &Recorder{
+		encoder: json.NewEncoder(w),
+	}
+	if closer, ok := w.(io.Closer); ok {
+		result.closer = closer
+	}
+	return result
+}
+
+// CreateRecording returns a recorder that writes the recording to the given file. An existing file is overwritten.
+func CreateRecording(filename string) (*Recorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the recording: %w", err)
+	}
+	return NewRecorder(f), nil
+}
+
+// Record writes the given event to the recording. If the time of the event is not set, the current time is used.
+func (r *Recorder) Record(event RecordedEvent) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	err := r.encoder.Encode(event)
+	if err != nil {
+		log.Printf("cannot record event: %v", err)
+	}
+}
+
+// Close stops the recording. Any further events are ignored.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.closer != nil {
+		r.closer.Close()
+	}
+}
+
+// ReadRecording reads all events of the recording from the given reader.
+func ReadRecording(r io.Reader) ([]RecordedEvent, error) {
+	var result []RecordedEvent
+	decoder := json.NewDecoder(r)
+	for {
+		var event RecordedEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid recording: %w", err)
+		}
+		result = append(result, event)
+	}
+}
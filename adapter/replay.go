@@ -0,0 +1,333 @@
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/gorilla/websocket"
+)
+
+// replaySyncMessage is sent by the replay after the recorded TCI messages, before it sends the next Hamlib request.
+// The adapter processes the TCI messages asynchronously, but in order: when the adapter receives the sync message,
+// it has processed all TCI messages before.
+const replaySyncMessage = "replay_sync"
+
+// ReplayFile replays the recording in the given file, see Replay.
+func ReplayFile(filename string, timeout time.Duration) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("cannot open the recording: %w", err)
+	}
+	defer f.Close()
+	events, err := ReadRecording(f)
+	if err != nil {
+		return err
+	}
+	return Replay(events, timeout)
+}
+
+// Replay feeds the given recording into a new adapter: it plays the part of the TCI host and of the Hamlib clients.
+// All Hamlib responses and TCI commands that the adapter sends must match the recording, otherwise Replay returns
+// an error that describes the differences. The timeout defines how long Replay waits for each expected message.
+func Replay(events []RecordedEvent, timeout time.Duration) error {
+	if len(events) == 0 || events[0].Stream != streamSession || events[0].Session == nil {
+		return fmt.Errorf("the recording does not start with a session")
+	}
+	session := events[0].Session
+	if len(session.TRX) == 0 {
+		return fmt.Errorf("the recorded session has no TRX")
+	}
+
+	tciHost, err := startReplayHost()
+	if err != nil {
+		return err
+	}
+	defer tciHost.Close()
+
+	trxAddresses := make([]TRXAddress, len(session.TRX))
+	for i, trx := range session.TRX {
+		trxAddresses[i] = TRXAddress{TRX: trx, LocalAddress: "127.0.0.1:0", Settings: session.Settings.TRX[trx]}
+	}
+	done := make(chan struct{})
+	adapter, err := Listen(trxAddresses, TCIHostAt(tciHost.Addr()), done, replaySettings(session.Settings), session.Version, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		close(done)
+		tciHost.Close()
+		adapter.tciClient.Disconnect()
+	}()
+	syncListener := &replaySync{synced: make(chan struct{}, 1)}
+	adapter.tciClient.Notify(syncListener)
+
+	err = tciHost.waitForConnection(timeout)
+	if err != nil {
+		return err
+	}
+
+	clients := make(map[int64]*replayClient)
+	defer func() {
+		for _, client := range clients {
+			client.conn.Close()
+		}
+	}()
+
+	var mismatches []error
+	unsynced := false
+	for i := 1; i < len(events); i++ {
+		event := events[i]
+		eventNumber := i + 1
+		switch {
+		case event.Stream == streamSession && event.Session != nil:
+			adapter.Reconfigure(replaySettings(event.Session.Settings))
+		case event.Stream == streamTCI && event.Direction == directionRX:
+			err := tciHost.send(event.Data)
+			if err != nil {
+				return errors.Join(append(mismatches, fmt.Errorf("event %d: %w", eventNumber, err))...)
+			}
+			unsynced = true
+		case event.Stream == streamTCI && event.Direction == directionTX:
+			command, err := tciHost.receive(timeout)
+			if err != nil {
+				return errors.Join(append(mismatches, fmt.Errorf("event %d: expected TCI command %q: %w", eventNumber, event.Data, err))...)
+			}
+			if command != event.Data {
+				mismatches = append(mismatches, fmt.Errorf("event %d: expected TCI command %q, got %q", eventNumber, event.Data, command))
+			}
+		case event.Stream == streamHamlib && event.Direction == directionRX:
+			if unsynced {
+				err := tciHost.send(replaySyncMessage + ";")
+				if err == nil {
+					err = syncListener.wait(timeout)
+				}
+				if err != nil {
+					return errors.Join(append(mismatches, fmt.Errorf("event %d: %w", eventNumber, err))...)
+				}
+				unsynced = false
+			}
+			client, ok := clients[event.Conn]
+			if !ok {
+				client, err = openReplayClient(adapter, event.TRX)
+				if err != nil {
+					return errors.Join(append(mismatches, fmt.Errorf("event %d: %w", eventNumber, err))...)
+				}
+				clients[event.Conn] = client
+			}
+			_, err := fmt.Fprintln(client.conn, event.Data)
+			if err != nil {
+				return errors.Join(append(mismatches, fmt.Errorf("event %d: cannot send Hamlib request: %w", eventNumber, err))...)
+			}
+		case event.Stream == streamHamlib && event.Direction == directionTX:
+			client, ok := clients[event.Conn]
+			if !ok {
+				return errors.Join(append(mismatches, fmt.Errorf("event %d: response on connection %d without request", eventNumber, event.Conn))...)
+			}
+			group := hamlibResponseGroup(events, i)
+			lineCount := 0
+			for _, expected := range group {
+				lineCount += strings.Count(expected.Data, "\n") + 1
+			}
+			response, err := client.readResponse(lineCount, timeout)
+			if err != nil {
+				return errors.Join(append(mismatches, fmt.Errorf("event %d: expected Hamlib response %q on connection %d: %w", eventNumber, event.Data, event.Conn, err))...)
+			}
+			mismatches = append(mismatches, compareHamlibResponses(group, strings.Split(response, "\n"), eventNumber)...)
+			i += len(group) - 1
+		}
+	}
+
+	return errors.Join(mismatches...)
+}
+
+// replaySettings returns the recorded settings for the replay. The replayed Hamlib clients connect from the loopback
+// address, therefore the access lists of the recording are not used, and client settings only apply if they match
+// the loopback address.
+func replaySettings(settings Settings) Settings {
+	settings.Allow = nil
+	settings.Deny = nil
+	return settings
+}
+
+// replaySync is notified by the TCI client of the adapter about the sync messages of the replay. The TCI client
+// notifies its listeners about one message after the other.
+type replaySync struct {
+	synced chan struct{}
+}
+
+func (s *replaySync) Message(msg tci.Message) {
+	if msg.Name() == replaySyncMessage {
+		s.synced <- struct{}{}
+	}
+}
+
+func (s *replaySync) wait(timeout time.Duration) error {
+	select {
+	case <-s.synced:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("the adapter did not process the TCI messages")
+	}
+}
+
+// hamlibResponseGroup returns the responses on the same connection that directly follow each other in the
+// recording, starting at the given index.
+func hamlibResponseGroup(events []RecordedEvent, start int) []RecordedEvent {
+	end := start + 1
+	for end < len(events) {
+		event := events[end]
+		if event.Stream != streamHamlib || event.Direction != directionTX || event.Conn != events[start].Conn {
+			break
+		}
+		end++
+	}
+	return events[start:end]
+}
+
+// compareHamlibResponses compares the received lines with the expected responses.
+func compareHamlibResponses(expected []RecordedEvent, lines []string, firstEventNumber int) []error {
+	var result []error
+	for i, event := range expected {
+		lineCount := strings.Count(event.Data, "\n") + 1
+		response := strings.Join(lines[:lineCount], "\n")
+		lines = lines[lineCount:]
+		if response != event.Data {
+			result = append(result, fmt.Errorf("event %d: expected Hamlib response %q on connection %d, got %q", firstEventNumber+i, event.Data, event.Conn, response))
+		}
+	}
+	return result
+}
+
+// replayHost plays the part of the TCI host in a replay.
+type replayHost struct {
+	listener   net.Listener
+	httpServer *http.Server
+	upgrader   websocket.Upgrader
+	connected  chan *websocket.Conn
+	conn       *websocket.Conn
+	commands   chan string
+}
+
+func startReplayHost() (*replayHost, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the local port for the replay: %w", err)
+	}
+	result := &replayHost{
+		listener: listener,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		connected: make(chan *websocket.Conn, 1),
+		commands:  make(chan string, 100),
+	}
+	result.httpServer = &http.Server{Handler: result}
+	go func() {
+		err := result.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("replay: %v", err)
+		}
+	}()
+	return result, nil
+}
+
+func (h *replayHost) Addr() *net.TCPAddr {
+	return h.listener.Addr().(*net.TCPAddr)
+}
+
+func (h *replayHost) Close() {
+	h.httpServer.Close()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+}
+
+func (h *replayHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("replay: %v", err)
+		return
+	}
+	select {
+	case h.connected <- conn:
+	default:
+		// the replay supports only one TCI connection
+		conn.Close()
+	}
+}
+
+func (h *replayHost) waitForConnection(timeout time.Duration) error {
+	select {
+	case h.conn = <-h.connected:
+	case <-time.After(timeout):
+		return fmt.Errorf("the adapter did not connect to the replayed TCI host")
+	}
+	go func() {
+		defer close(h.commands)
+		for {
+			msgType, msg, err := h.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType == websocket.TextMessage {
+				h.commands <- string(msg)
+			}
+		}
+	}()
+	return nil
+}
+
+func (h *replayHost) send(message string) error {
+	return h.conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+func (h *replayHost) receive(timeout time.Duration) (string, error) {
+	select {
+	case command, ok := <-h.commands:
+		if !ok {
+			return "", fmt.Errorf("the TCI connection is closed")
+		}
+		return command, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timeout")
+	}
+}
+
+// replayClient plays the part of one Hamlib client in a replay.
+type replayClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func openReplayClient(adapter *Adapter, trx int) (*replayClient, error) {
+	addr := adapter.Addr(trx)
+	if addr == nil {
+		return nil, fmt.Errorf("TRX %d is not provided", trx)
+	}
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("cannot open Hamlib connection: %w", err)
+	}
+	return &replayClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *replayClient) readResponse(lines int, timeout time.Duration) (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	result := make([]string, 0, lines)
+	for i := 0; i < lines; i++ {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		result = append(result, strings.TrimSuffix(line, "\n"))
+	}
+	return strings.Join(result, "\n"), nil
+}
@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplay_Recordings replays all recordings in testdata/recordings. To turn a field issue into a regression test,
+// record the session with --record and add the recording to this directory.
+func TestReplay_Recordings(t *testing.T) {
+	recordings, err := filepath.Glob(filepath.Join("testdata", "recordings", "*.jsonl"))
+	require.NoError(t, err)
+	require.NotEmpty(t, recordings)
+
+	for _, recording := range recordings {
+		t.Run(filepath.Base(recording), func(t *testing.T) {
+			assert.NoError(t, ReplayFile(recording, time.Second))
+		})
+	}
+}
+
+func TestReplay_DetectsMismatches(t *testing.T) {
+	session := &RecordedSession{TRX: []int{0}}
+	events := []RecordedEvent{
+		{Stream: streamSession, Session: session},
+		{Stream: streamTCI, Direction: directionRX, Data: "protocol:ExpertSDR3,1.8;"},
+		{Stream: streamTCI, Direction: directionRX, Data: "trx_count:1;"},
+		{Stream: streamTCI, Direction: directionRX, Data: "vfo:0,0,7074000;"},
+		{Stream: streamTCI, Direction: directionRX, Data: "ready;"},
+		{Stream: streamHamlib, Direction: directionRX, Conn: 1, Data: "f"},
+		{Stream: streamHamlib, Direction: directionTX, Conn: 1, Data: "14074000"},
+	}
+
+	err := Replay(events, time.Second)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `expected Hamlib response "14074000" on connection 1, got "7074000"`)
+}
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	recorder := NewRecorder(&buffer)
+	recorder.Record(RecordedEvent{Stream: streamHamlib, Direction: directionRX, Conn: 1, Data: "f"})
+	recorder.Record(RecordedEvent{Stream: streamHamlib, Direction: directionTX, Conn: 1, Data: "USB\n2700"})
+	recorder.Close()
+	recorder.Record(RecordedEvent{Stream: streamHamlib, Direction: directionRX, Conn: 1, Data: "m"})
+
+	events, err := ReadRecording(&buffer)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "f", events[0].Data)
+	assert.Equal(t, "USB\n2700", events[1].Data)
+	assert.False(t, events[0].Time.IsZero())
+}
+
+func TestRecorder_RecordsEffectiveSettings(t *testing.T) {
+	_, network, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	settings := Settings{
+		Modes:    []ModeRule{{Hamlib: hamlib.ModePKTUSB, TCI: tci.ModeDIGU, Bands: []Band{{Name: "40m", From: 7000000, To: 7200000}}}},
+		TXPolicy: testTXPolicy(),
+		Allow:    []*net.IPNet{network},
+		Role:     RoleTune,
+		Password: "secret",
+		Clients:  []ClientSettings{{Networks: []*net.IPNet{network}, LicenseClass: "novice", Role: RoleFull}},
+	}
+	var buffer bytes.Buffer
+	done := make(chan struct{})
+	adapter, err := Listen([]TRXAddress{{TRX: 0, LocalAddress: "127.0.0.1:0"}}, TCIHostAt(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}), done, settings, "test", NewRecorder(&buffer))
+	require.NoError(t, err)
+	reconfigured := settings
+	reconfigured.Role = RoleReadOnly
+	adapter.Reconfigure(reconfigured)
+	close(done)
+	adapter.Wait()
+
+	events, err := ReadRecording(&buffer)
+	require.NoError(t, err)
+	var sessions []*RecordedSession
+	for _, event := range events {
+		if event.Stream == streamSession {
+			sessions = append(sessions, event.Session)
+		}
+	}
+	require.Len(t, sessions, 2)
+
+	recorded := sessions[0].Settings
+	require.Len(t, recorded.Allow, 1)
+	require.Len(t, recorded.Clients, 1)
+	require.Len(t, recorded.Clients[0].Networks, 1)
+	assert.Equal(t, "192.168.1.0/24", recorded.Allow[0].String())
+	assert.Equal(t, "192.168.1.0/24", recorded.Clients[0].Networks[0].String())
+	recorded.Allow, recorded.Clients[0].Networks = settings.Allow, settings.Clients[0].Networks
+
+	expected := settings
+	expected.Password = redactedPassword
+	expected.TRX = map[int]TRXSettings{0: {}}
+	assert.Equal(t, "test", sessions[0].Version)
+	assert.Equal(t, []int{0}, sessions[0].TRX)
+	assert.Equal(t, expected, recorded)
+	assert.Equal(t, RoleReadOnly, sessions[1].Settings.Role)
+	assert.Equal(t, redactedPassword, sessions[1].Settings.Password)
+}
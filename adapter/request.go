@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+)
+
+// vfoCommands contains all commands that take the target VFO as first argument when the connection is in VFO mode
+// (see the commands without ARG_NOVFO in Hamlib's rigctl_parse.c).
+var vfoCommands = map[string]bool{
+	"set_freq":            true,
+	"get_freq":            true,
+	"set_mode":            true,
+	"get_mode":            true,
+	"set_rit":             true,
+	"get_rit":             true,
+	"set_xit":             true,
+	"get_xit":             true,
+	"set_ptt":             true,
+	"get_ptt":             true,
+	"get_dcd":             true,
+	"set_rptr_shift":      true,
+	"get_rptr_shift":      true,
+	"set_rptr_offs":       true,
+	"get_rptr_offs":       true,
+	"set_ctcss_tone":      true,
+	"get_ctcss_tone":      true,
+	"set_dcs_code":        true,
+	"get_dcs_code":        true,
+	"set_ctcss_sql":       true,
+	"get_ctcss_sql":       true,
+	"set_dcs_sql":         true,
+	"get_dcs_sql":         true,
+	"set_split_freq":      true,
+	"get_split_freq":      true,
+	"set_split_mode":      true,
+	"get_split_mode":      true,
+	"set_split_freq_mode": true,
+	"get_split_freq_mode": true,
+	"set_split_vfo":       true,
+	"get_split_vfo":       true,
+	"set_ts":              true,
+	"get_ts":              true,
+	"set_func":            true,
+	"get_func":            true,
+	"set_level":           true,
+	"get_level":           true,
+	"set_bank":            true,
+	"set_mem":             true,
+	"get_mem":             true,
+	"vfo_op":              true,
+	"scan":                true,
+	"set_ant":             true,
+	"get_ant":             true,
+	"send_dtmf":           true,
+	"recv_dtmf":           true,
+	"send_morse":          true,
+	"stop_morse":          true,
+	"wait_morse":          true,
+	"send_voice_mem":      true,
+}
+
+// request is a Hamlib request that optionally contains the target VFO.
+type request struct {
+	protocol.Request
+	vfo string
+}
+
+// requestReader reads Hamlib requests like protocol.RequestReader, but it also supports the VFO mode.
+// In VFO mode, all commands in vfoCommands take the target VFO as additional first argument.
+type requestReader struct {
+	scanner     *bufio.Scanner
+	currentLine *bytes.Buffer
+	lineRead    func(line string)
+}
+
+func newRequestReader(r io.Reader) *requestReader {
+	return &requestReader{
+		scanner: bufio.NewScanner(r),
+	}
+}
+
+func (r *requestReader) ReadRequest(vfoMode bool) (request, error) {
+	for {
+		if r.currentLine == nil || r.currentLine.Len() == 0 {
+			if !r.scanner.Scan() {
+				err := r.scanner.Err()
+				if err == nil {
+					return request{}, io.EOF
+				}
+				return request{}, err
+			}
+			r.currentLine = bytes.NewBufferString(r.scanner.Text())
+			if r.lineRead != nil {
+				r.lineRead(r.scanner.Text())
+			}
+		}
+
+		req, err := nextRequest(r.currentLine, vfoMode)
+		if err == io.EOF {
+			continue
+		}
+		return req, err
+	}
+}
+
+func nextRequest(r *bytes.Buffer, vfoMode bool) (request, error) {
+	var cmd protocol.Command
+loop:
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return request{}, io.EOF
+		}
+
+		switch c {
+		case '#':
+			r.Reset()
+			return request{}, io.EOF
+		case '+':
+			req, err := nextRequest(r, vfoMode)
+			if err == nil && req.SupportsExtendedMode {
+				req.ExtendedSeparator = "\n"
+			}
+			return req, err
+		case ';', ',', '|':
+			req, err := nextRequest(r, vfoMode)
+			if err == nil && req.SupportsExtendedMode {
+				req.ExtendedSeparator = string(c)
+			}
+			return req, err
+		case '\\':
+			name := readWord(r)
+			var ok bool
+			cmd, ok = longCommand(name)
+			if !ok {
+				return request{}, fmt.Errorf("unknown long command %s", name)
+			}
+			break loop
+		default:
+			if unicode.IsSpace(rune(c)) {
+				continue
+			}
+			var ok bool
+			cmd, ok = shortCommand(c)
+			if !ok {
+				return request{}, fmt.Errorf("unknown short command %s (0x%x)", string(c), c)
+			}
+			break loop
+		}
+	}
+
+	result := request{Request: protocol.Request{Command: cmd}}
+	if vfoMode && vfoCommands[cmd.Long] {
+		result.vfo = readWord(r)
+	}
+
+	if cmd.ArgsInLine {
+		line := strings.TrimSpace(r.String())
+		r.Reset()
+		if line != "" {
+			result.Args = []string{line}
+		}
+		return result, nil
+	}
+
+	for i := 0; i < cmd.Args; i++ {
+		arg := readWord(r)
+		if arg == "" {
+			break
+		}
+		result.Args = append(result.Args, arg)
+	}
+	return result, nil
+}
+
+// longCommand returns the command with the given long name, including the commands that the protocol package does not
+// know.
+func longCommand(name string) (protocol.Command, bool) {
+	if name == passwordCommand.Long {
+		return passwordCommand, true
+	}
+	cmd, ok := protocol.LongCommands[name]
+	return cmd, ok
+}
+
+// shortCommand returns the command with the given short name, including the commands that the protocol package does
+// not know.
+func shortCommand(name byte) (protocol.Command, bool) {
+	if name == passwordCommand.Short {
+		return passwordCommand, true
+	}
+	cmd, ok := protocol.ShortCommands[name]
+	return cmd, ok
+}
+
+func readWord(r *bytes.Buffer) string {
+	var word strings.Builder
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		if unicode.IsSpace(rune(c)) {
+			if word.Len() > 0 {
+				break
+			}
+			continue
+		}
+		word.WriteByte(c)
+	}
+	return word.String()
+}
+
+// parseHamlibBool parses a boolean argument of a Hamlib request, which must be 0 or 1.
+func parseHamlibBool(arg string) (bool, error) {
+	switch arg {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	default:
+		return false, invalidArgument("invalid value %s, use 0 or 1", arg)
+	}
+}
@@ -0,0 +1,83 @@
+package adapter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ftl/rigproxy/pkg/protocol"
+)
+
+func getRITResponse(offset int) protocol.Response {
+	return protocol.Response{
+		Command: "get_rit",
+		Data:    []string{strconv.Itoa(offset)},
+		Keys:    []string{"RIT"},
+		Result:  "0",
+	}
+}
+
+func getXITResponse(offset int) protocol.Response {
+	return protocol.Response{
+		Command: "get_xit",
+		Data:    []string{strconv.Itoa(offset)},
+		Keys:    []string{"XIT"},
+		Result:  "0",
+	}
+}
+
+func getLevelResponse(level string, value string) protocol.Response {
+	return protocol.Response{
+		Command: protocol.CommandKey("get_level_" + strings.ToLower(level)),
+		Data:    []string{value},
+		Keys:    []string{level},
+		Result:  "0",
+	}
+}
+
+func getFuncResponse(function string, enabled bool) protocol.Response {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+	return protocol.Response{
+		Command: protocol.CommandKey("get_func_" + strings.ToLower(function)),
+		Data:    []string{value},
+		Keys:    []string{function},
+		Result:  "0",
+	}
+}
+
+func chkVFOResponse(vfoMode bool) protocol.Response {
+	value := "0"
+	if vfoMode {
+		value = "1"
+	}
+	return protocol.Response{
+		Command: "chk_vfo",
+		Data:    []string{value},
+		Keys:    []string{""},
+		Result:  "0",
+	}
+}
+
+func getLockModeResponse(locked bool) protocol.Response {
+	value := "0"
+	if locked {
+		value = "1"
+	}
+	return protocol.Response{
+		Command: "get_lock_mode",
+		Data:    []string{value},
+		Keys:    []string{"Locked"},
+		Result:  "0",
+	}
+}
+
+func getTRNResponse(transceive string) protocol.Response {
+	return protocol.Response{
+		Command: "get_trn",
+		Data:    []string{transceive},
+		Keys:    []string{"Transceive"},
+		Result:  "0",
+	}
+}
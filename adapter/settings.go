@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// Settings are the settings of the adapter that can be changed while the adapter is running, see Reconfigure. Allow
+// and Deny are the access lists for the Hamlib, Kenwood and FLRig clients. Role is the role of all clients without a
+// role of their own, without role all requests are permitted. The role applies to the Hamlib requests and to the
+// corresponding Kenwood commands and FLRig methods. With TLS, the Hamlib connections use TLS. With Password, the
+// Hamlib clients must send the password (\password) before any other request.
+type Settings struct {
+	TraceHamlib bool                `json:"trace_hamlib"`
+	TraceTCI    bool                `json:"trace_tci"`
+	NoDigimodes bool                `json:"no_digimodes"`
+	VFOMode     bool                `json:"vfo_mode"`
+	MaxTXTime   time.Duration       `json:"max_tx_time,omitempty"`
+	Modes       []ModeRule          `json:"modes,omitempty"`
+	TXPolicy    TXPolicy            `json:"tx_policy"`
+	Allow       []*net.IPNet        `json:"allow,omitempty"`
+	Deny        []*net.IPNet        `json:"deny,omitempty"`
+	Role        Role                `json:"role,omitempty"`
+	TLS         *tls.Config         `json:"-"`
+	Password    string              `json:"password,omitempty"`
+	TRX         map[int]TRXSettings `json:"trx,omitempty"`
+	Clients     []ClientSettings    `json:"clients,omitempty"`
+}
+
+// TRXSettings override the global settings of the adapter for one TRX. A nil value keeps the global setting.
+type TRXSettings struct {
+	NoDigimodes *bool `json:"no_digimodes,omitempty"`
+	VFOMode     *bool `json:"vfo_mode,omitempty"`
+}
+
+// ClientSettings override the global and the TRX settings for clients that connect from one of the given networks. A
+// nil value keeps the global or the TRX setting. The mode rules of the client are checked before the global mode
+// rules. An empty license class or role keeps the license class of the TX policy or the global role.
+type ClientSettings struct {
+	Networks     []*net.IPNet `json:"networks"`
+	NoDigimodes  *bool        `json:"no_digimodes,omitempty"`
+	VFOMode      *bool        `json:"vfo_mode,omitempty"`
+	TraceHamlib  *bool        `json:"trace_hamlib,omitempty"`
+	Modes        []ModeRule   `json:"modes,omitempty"`
+	LicenseClass string       `json:"license_class,omitempty"`
+	Role         Role         `json:"role,omitempty"`
+}
+
+func (s ClientSettings) matches(ip net.IP) bool {
+	return containsIP(s.Networks, ip)
+}
+
+// findClientSettings returns the first client entry that matches the given remote address.
+func findClientSettings(clients []ClientSettings, remoteAddress net.Addr) (ClientSettings, bool) {
+	ip := remoteIP(remoteAddress)
+	if ip == nil {
+		return ClientSettings{}, false
+	}
+	for _, settings := range clients {
+		if settings.matches(ip) {
+			return settings, true
+		}
+	}
+	return ClientSettings{}, false
+}
+
+func remoteIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// connectionSettings are the effective settings of one connection.
+type connectionSettings struct {
+	trace       bool
+	noDigimodes bool
+	vfoMode     bool
+	modes       modeRules
+	txPolicy    txPolicy
+	role        Role
+	password    string
+}
+
+// settingsSource provides the current settings of a connection, they may change while the connection is open.
+type settingsSource func() connectionSettings
+
+func (s settingsSource) current() connectionSettings {
+	if s == nil {
+		return connectionSettings{}
+	}
+	return s()
+}
+
+// Reconfigure replaces the settings of the adapter. The trace flags, the digimode override, the mode rules, the TX
+// policy, the roles, the password and the client settings apply immediately to all open connections, the VFO mode,
+// the access lists and the TLS configuration apply to new connections, and the maximum TX time applies to the next
+// transmission.
+func (a *Adapter) Reconfigure(settings Settings) {
+	a.settingsLock.Lock()
+	defer a.settingsLock.Unlock()
+	a.settings.Store(&settings)
+	a.recorder.Record(RecordedEvent{Stream: streamSession, Session: a.recordedSession(settings)})
+	if tciModes := a.tciDevice.modes(); tciModes != nil {
+		a.validateModes(&settings, tciModes)
+	}
+	if a.tciDialer != nil {
+		a.tciDialer.setTrace(settings.TraceTCI)
+	}
+}
+
+// Settings returns the current settings of the adapter.
+func (a *Adapter) Settings() Settings {
+	return *a.settings.Load()
+}
+
+// connectionSettings resolves the current settings for a connection to the given TRX: the client settings override
+// the TRX settings, the TRX settings override the global settings. Without remote address, the client settings are
+// not used.
+func (a *Adapter) connectionSettings(trx int, remoteAddress net.Addr) connectionSettings {
+	settings := a.settings.Load()
+	result := connectionSettings{
+		trace:       settings.TraceHamlib,
+		noDigimodes: settings.NoDigimodes,
+		vfoMode:     settings.VFOMode,
+		modes:       settings.Modes,
+		txPolicy:    newTXPolicy(settings.TXPolicy, settings.TXPolicy.LicenseClass),
+		role:        settings.Role,
+		password:    settings.Password,
+	}
+	trxSettings := settings.TRX[trx]
+	overrideBool(&result.noDigimodes, trxSettings.NoDigimodes)
+	overrideBool(&result.vfoMode, trxSettings.VFOMode)
+
+	client, ok := findClientSettings(settings.Clients, remoteAddress)
+	if !ok {
+		return result
+	}
+	overrideBool(&result.trace, client.TraceHamlib)
+	overrideBool(&result.noDigimodes, client.NoDigimodes)
+	overrideBool(&result.vfoMode, client.VFOMode)
+	if len(client.Modes) > 0 {
+		result.modes = append(append(modeRules{}, client.Modes...), settings.Modes...)
+	}
+	if client.LicenseClass != "" {
+		result.txPolicy = newTXPolicy(settings.TXPolicy, client.LicenseClass)
+	}
+	if client.Role != "" {
+		result.role = client.Role
+	}
+	return result
+}
+
+// settingsSource returns the source of the current settings for a connection to the given TRX.
+func (a *Adapter) settingsSource(trx int, remoteAddress net.Addr) settingsSource {
+	return func() connectionSettings {
+		return a.connectionSettings(trx, remoteAddress)
+	}
+}
+
+func overrideBool(value *bool, override *bool) {
+	if override != nil {
+		*value = *override
+	}
+}
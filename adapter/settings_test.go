@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"net"
+	"testing"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionSettings(t *testing.T) {
+	yes, no := true, false
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	_, host, _ := net.ParseCIDR("192.168.1.10/32")
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{
+		NoDigimodes: true,
+		TRX: map[int]TRXSettings{
+			1: {VFOMode: &yes, NoDigimodes: &yes},
+		},
+		Clients: []ClientSettings{
+			{Networks: []*net.IPNet{host}, TraceHamlib: &yes},
+			{Networks: []*net.IPNet{lan}, NoDigimodes: &no},
+		},
+	})
+
+	tt := []struct {
+		name     string
+		trx      int
+		remote   string
+		expected connectionSettings
+	}{
+		{"global", 0, "10.0.0.1:1234", connectionSettings{noDigimodes: true}},
+		{"trx", 1, "10.0.0.1:1234", connectionSettings{noDigimodes: true, vfoMode: true}},
+		{"first matching client", 1, "192.168.1.10:1234", connectionSettings{trace: true, noDigimodes: true, vfoMode: true}},
+		{"client overrides trx", 1, "192.168.1.11:1234", connectionSettings{noDigimodes: false, vfoMode: true}},
+		{"no remote address", 1, "", connectionSettings{noDigimodes: true, vfoMode: true}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var remote net.Addr
+			if tc.remote != "" {
+				var err error
+				remote, err = net.ResolveTCPAddr("tcp", tc.remote)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, adapter.connectionSettings(tc.trx, remote))
+		})
+	}
+}
+
+func TestConnectionSettings_ModeRules(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	globalRule := ModeRule{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB}
+	clientRule := ModeRule{Hamlib: hamlib.ModeFAX, TCI: tci.ModeLSB}
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{
+		Modes: []ModeRule{globalRule},
+		Clients: []ClientSettings{
+			{Networks: []*net.IPNet{lan}, Modes: []ModeRule{clientRule}},
+		},
+	})
+
+	assert.Equal(t, modeRules{globalRule}, adapter.connectionSettings(0, nil).modes)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 1234}
+	assert.Equal(t, modeRules{clientRule, globalRule}, adapter.connectionSettings(0, remote).modes)
+}
+
+func TestReconfigure_AppliesToOpenConnections(t *testing.T) {
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{})
+	settings := adapter.settingsSource(0, nil)
+	assert.False(t, settings.current().noDigimodes)
+
+	adapter.Reconfigure(Settings{NoDigimodes: true, TraceHamlib: true})
+
+	assert.Equal(t, connectionSettings{trace: true, noDigimodes: true}, settings.current())
+}
@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	tci "github.com/ftl/tci/client"
+	"github.com/gorilla/websocket"
+)
+
+// tciDialer connects the TCI client of the adapter to the current TCI host. It records and traces the text messages
+// of the connection, and it switches an open connection to another TCI host without disconnecting the TCI client.
+type tciDialer struct {
+	recorder *Recorder
+	trace    atomic.Bool
+
+	mutex   sync.Mutex
+	tciHost *TCIHost
+	conn    *tciConn
+	closed  bool
+}
+
+func newTCIDialer(tciHost *TCIHost, trace bool, recorder *Recorder) *tciDialer {
+	result := &tciDialer{
+		tciHost:  tciHost,
+		recorder: recorder,
+	}
+	result.trace.Store(trace)
+	return result
+}
+
+// Dial opens a new connection to the current TCI host. It implements the tci.Dialer interface.
+func (d *tciDialer) Dial() (tci.Conn, error) {
+	d.mutex.Lock()
+	tciHost := d.tciHost
+	closed := d.closed
+	d.mutex.Unlock()
+	if closed {
+		return nil, net.ErrClosed
+	}
+
+	upstream, err := tciHost.dial()
+	if err != nil {
+		return nil, err
+	}
+	conn := &tciConn{dialer: d, upstream: upstream}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		upstream.Close()
+		return nil, net.ErrClosed
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// String returns the current TCI host without the password.
+func (d *tciDialer) String() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.tciHost.String()
+}
+
+// Close closes the open connection, the TCI client sees this as connection loss. The dialer does not open any new
+// connection.
+func (d *tciDialer) Close() {
+	d.mutex.Lock()
+	d.closed = true
+	conn := d.conn
+	d.mutex.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func (d *tciDialer) setTrace(trace bool) {
+	d.trace.Store(trace)
+}
+
+// setTCIHost switches to the given TCI host. The open connection is switched to the new TCI host, the new host sends
+// its initial state to the TCI client like on a new connection. If the new TCI host is not available, the connection
+// is closed and the TCI client reconnects to the new TCI host.
+func (d *tciDialer) setTCIHost(tciHost *TCIHost) {
+	d.mutex.Lock()
+	d.tciHost = tciHost
+	conn := d.conn
+	d.mutex.Unlock()
+	if conn == nil {
+		return
+	}
+
+	upstream, err := tciHost.dial()
+	if err != nil {
+		log.Printf("cannot connect to the TCI host %s: %v", tciHost, err)
+		conn.Close()
+		return
+	}
+	conn.switchUpstream(upstream)
+}
+
+func (d *tciDialer) transferred(msgType int, msg []byte, direction string) {
+	if msgType != websocket.TextMessage {
+		return
+	}
+	if d.trace.Load() {
+		if direction == directionRX {
+			log.Printf("< %s", msg)
+		} else {
+			log.Printf("> %s", msg)
+		}
+	}
+	d.recorder.Record(RecordedEvent{Stream: streamTCI, Direction: direction, Data: string(msg)})
+}
+
+// tciConn is the connection of the TCI client to the TCI host. The TCI client reads and writes in different
+// goroutines, while the dialer may switch the connection to another TCI host.
+type tciConn struct {
+	dialer *tciDialer
+
+	mutex    sync.Mutex
+	upstream *websocket.Conn
+	closed   bool
+}
+
+func (c *tciConn) currentUpstream() *websocket.Conn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.upstream
+}
+
+// switchUpstream replaces the connection to the TCI host and closes the previous one.
+func (c *tciConn) switchUpstream(upstream *websocket.Conn) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		upstream.Close()
+		return
+	}
+	previous := c.upstream
+	c.upstream = upstream
+	c.mutex.Unlock()
+	previous.Close()
+}
+
+// ReadMessage reads the next message from the TCI host. When the connection switches to another TCI host, the
+// reading continues with the new TCI host.
+func (c *tciConn) ReadMessage() (int, []byte, error) {
+	for {
+		upstream := c.currentUpstream()
+		msgType, msg, err := upstream.ReadMessage()
+		if err != nil {
+			if c.currentUpstream() != upstream {
+				continue
+			}
+			return msgType, msg, err
+		}
+		c.dialer.transferred(msgType, msg, directionRX)
+		return msgType, msg, nil
+	}
+}
+
+// WriteMessage writes the given message to the current TCI host. A message that is written while the connection
+// switches to another TCI host may be lost, the TCI client handles this like a timeout.
+func (c *tciConn) WriteMessage(msgType int, data []byte) error {
+	c.dialer.transferred(msgType, data, directionTX)
+	return c.currentUpstream().WriteMessage(msgType, data)
+}
+
+func (c *tciConn) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	upstream := c.upstream
+	c.mutex.Unlock()
+	return upstream.Close()
+}
+
+func (c *tciConn) RemoteAddr() net.Addr {
+	return c.currentUpstream().RemoteAddr()
+}
@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/tciadapter/simulator"
+)
+
+func TestTCIDialer_RecordsAndSwitchesTCIHost(t *testing.T) {
+	sdr, err := simulator.Start("127.0.0.1:0", simulator.DefaultConfig)
+	require.NoError(t, err)
+	t.Cleanup(sdr.Close)
+	otherSDR, err := simulator.Start("127.0.0.1:0", simulator.DefaultConfig)
+	require.NoError(t, err)
+	t.Cleanup(otherSDR.Close)
+	buffer := new(bytes.Buffer)
+	recorder := NewRecorder(buffer)
+	dialer := newTCIDialer(TCIHostAt(sdr.Addr()), false, recorder)
+
+	conn, err := dialer.Dial()
+	require.NoError(t, err)
+	assert.Equal(t, sdr.Addr().String(), conn.RemoteAddr().String(), "the dialer connects directly to the TCI host")
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.NotEmpty(t, msg, "the TCI host sends its initial state")
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("vfo:0,0;")))
+
+	dialer.setTCIHost(TCIHostAt(otherSDR.Addr()))
+	assert.Equal(t, otherSDR.Addr().String(), conn.RemoteAddr().String(), "the open connection switches to the new TCI host")
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err, "the TCI client keeps reading from the new TCI host")
+	assert.NotEmpty(t, msg)
+
+	assert.Nil(t, websocket.DefaultDialer.NetDialContext, "the default dialer is not changed")
+
+	dialer.Close()
+	err = conn.WriteMessage(websocket.TextMessage, []byte("vfo:0,0;"))
+	assert.Error(t, err, "closing the dialer closes the open connection")
+	_, err = dialer.Dial()
+	assert.ErrorIs(t, err, net.ErrClosed)
+
+	recorder.Close()
+	assert.Contains(t, buffer.String(), `"dir":"rx"`)
+	assert.Contains(t, buffer.String(), `"data":"vfo:0,0;"`)
+}
@@ -0,0 +1,52 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+)
+
+// TCIHost describes how the adapter connects to the TCI host.
+type TCIHost struct {
+	// URL is the WebSocket URL of the TCI host with the scheme ws or wss and an optional path. The user and the
+	// password of the URL are sent as HTTP basic authentication, e.g. to a reverse proxy in front of the TCI host.
+	URL *url.URL
+	// TLS configures the connection to a wss URL, e.g. with a custom CA. Without configuration, the system defaults
+	// are used.
+	TLS *tls.Config
+}
+
+// TCIHostAt returns the TCI host at the given address, connected through plain WebSocket without path.
+func TCIHostAt(addr *net.TCPAddr) *TCIHost {
+	return &TCIHost{URL: &url.URL{Scheme: "ws", Host: addr.String()}}
+}
+
+// String returns the URL of the TCI host without the password.
+func (h *TCIHost) String() string {
+	return h.URL.Redacted()
+}
+
+// dial opens a new WebSocket connection to the TCI host.
+func (h *TCIHost) dial() (*websocket.Conn, error) {
+	u := *h.URL
+	header := make(http.Header)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		header.Set("Authorization", "Basic "+credentials)
+		u.User = nil
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = h.TLS
+	conn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil && resp != nil {
+		return nil, fmt.Errorf("%w: %s", err, resp.Status)
+	}
+	return conn, err
+}
@@ -0,0 +1,144 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// TXPolicy limits the transmissions that are started through the adapter to the segments of a band plan. Each
+// license class has its own segments. Without license class, the policy is not enforced.
+type TXPolicy struct {
+	// LicenseClass is the license class of all connections whose client settings have no license class of their own.
+	LicenseClass string `json:"license_class,omitempty"`
+	// BandPlan contains the segments in which each license class is allowed to transmit.
+	BandPlan map[string][]TXSegment `json:"band_plan,omitempty"`
+	// DryRun only logs the transmissions that violate the policy, without refusing them.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Validate checks if the band plan contains the given license classes.
+func (p TXPolicy) Validate(licenseClasses ...string) error {
+	for _, licenseClass := range licenseClasses {
+		if licenseClass == "" {
+			continue
+		}
+		if _, ok := p.BandPlan[licenseClass]; !ok {
+			return fmt.Errorf("unknown license class %s", licenseClass)
+		}
+	}
+	return nil
+}
+
+// TXSegment is a frequency range in which a license class is allowed to transmit.
+type TXSegment struct {
+	Band
+	// Modes are the allowed TCI modes in this segment. Without modes, all modes are allowed.
+	Modes []tci.Mode `json:"modes,omitempty"`
+	// MaxDrive is the maximum drive in percent. 0 means no limit.
+	MaxDrive int `json:"max_drive,omitempty"`
+}
+
+func (s TXSegment) allowsMode(mode tci.Mode) bool {
+	if len(s.Modes) == 0 {
+		return true
+	}
+	for _, allowed := range s.Modes {
+		if strings.EqualFold(string(allowed), string(mode)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s TXSegment) allowsDrive(drive int) bool {
+	return s.MaxDrive <= 0 || drive <= s.MaxDrive
+}
+
+// txPolicy is the TX policy of one connection.
+type txPolicy struct {
+	licenseClass string
+	segments     []TXSegment
+	dryRun       bool
+}
+
+func newTXPolicy(policy TXPolicy, licenseClass string) txPolicy {
+	return txPolicy{
+		licenseClass: licenseClass,
+		segments:     policy.BandPlan[licenseClass],
+		dryRun:       policy.DryRun,
+	}
+}
+
+// check returns an error if the given owner is not allowed to transmit in the given TRX state. In dry-run mode,
+// the violation is only logged.
+func (p txPolicy) check(state TRXState, owner string) error {
+	if p.licenseClass == "" {
+		return nil
+	}
+	err := p.violation(state)
+	if err == nil {
+		return nil
+	}
+	if p.dryRun {
+		log.Printf("TX policy (dry run): %s: %v", owner, err)
+		return nil
+	}
+	return err
+}
+
+// violation returns an error that describes why the license class of the policy is not allowed to transmit in the
+// given TRX state, or nil if one of the segments allows the transmission.
+func (p txPolicy) violation(state TRXState) error {
+	frequency := state.TXFrequency()
+	mode := strings.ToUpper(string(state.Mode))
+	inBand := false
+	modeAllowed := false
+	maxDrive := 0
+	for _, segment := range p.segments {
+		if !segment.Contains(frequency) {
+			continue
+		}
+		inBand = true
+		if !segment.allowsMode(state.Mode) {
+			continue
+		}
+		modeAllowed = true
+		if segment.allowsDrive(state.Drive) {
+			return nil
+		}
+		maxDrive = max(maxDrive, segment.MaxDrive)
+	}
+
+	switch {
+	case !inBand:
+		return notAvailable("TX policy: license class %s must not transmit on %d Hz", p.licenseClass, frequency)
+	case !modeAllowed:
+		return notAvailable("TX policy: license class %s must not transmit %s on %d Hz", p.licenseClass, mode, frequency)
+	default:
+		return notAvailable("TX policy: license class %s must not transmit with more than %d%% drive on %d Hz (drive is %d%%)", p.licenseClass, maxDrive, frequency, state.Drive)
+	}
+}
+
+// checkTX returns an error if the TX policy does not allow this connection to key the TRX in its current state.
+func (c *inboundConnection) checkTX() error {
+	return c.settings.current().txPolicy.check(c.trxData.Snapshot(), c.txOwner())
+}
+
+// checkChange returns an error if the given owner is not allowed to transmit in the given TRX state after the given
+// change. The change is only checked while the TRX transmits, the next key-down is checked anyway.
+func (p txPolicy) checkChange(state TRXState, owner string, change func(*TRXState)) error {
+	if !state.Transmitting && !state.Tuning {
+		return nil
+	}
+	change(&state)
+	return p.check(state, owner)
+}
+
+// checkTXChange returns an error if the TX policy does not allow this connection to apply the given change while
+// the TRX transmits.
+func (c *inboundConnection) checkTXChange(change func(*TRXState)) error {
+	return c.settings.current().txPolicy.checkChange(c.trxData.Snapshot(), c.txOwner(), change)
+}
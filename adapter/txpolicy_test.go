@@ -0,0 +1,210 @@
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTXPolicy() TXPolicy {
+	band40m, _ := FindBand("40m")
+	band20m, _ := FindBand("20m")
+	return TXPolicy{
+		LicenseClass: "novice",
+		BandPlan: map[string][]TXSegment{
+			"novice": {
+				{Band: Band{From: 7000000, To: 7040000}, Modes: []tci.Mode{tci.ModeCW}, MaxDrive: 50},
+				{Band: band40m, Modes: []tci.Mode{tci.ModeDIGU}, MaxDrive: 20},
+			},
+			"full":     {{Band: band40m}, {Band: band20m}},
+			"listener": {},
+		},
+	}
+}
+
+func TestTXPolicy_Check(t *testing.T) {
+	state := func(frequency int, mode tci.Mode, drive int) TRXState {
+		result := TRXState{Mode: mode, Drive: drive}
+		result.VFOs[tci.VFOA].Frequency = frequency
+		return result
+	}
+	split := state(7030000, tci.ModeCW, 50)
+	split.SplitEnabled = true
+	split.VFOs[tci.VFOB].Frequency = 10110000
+	xit := state(7039900, tci.ModeCW, 50)
+	xit.XITEnabled = true
+	xit.XITOffset = 200
+
+	tt := []struct {
+		name         string
+		licenseClass string
+		state        TRXState
+		allowed      bool
+	}{
+		{"no license class", "", state(27000000, tci.ModeAM, 100), true},
+		{"allowed", "novice", state(7030000, tci.ModeCW, 50), true},
+		{"mode allowed in other segment", "novice", state(7074000, tci.ModeDIGU, 20), true},
+		{"mode case", "novice", state(7030000, tci.Mode("CW"), 50), true},
+		{"outside of the segments", "novice", state(14030000, tci.ModeCW, 50), false},
+		{"mode not allowed", "novice", state(7030000, tci.ModeUSB, 50), false},
+		{"drive too high", "novice", state(7074000, tci.ModeDIGU, 30), false},
+		{"split uses VFO B", "novice", split, false},
+		{"XIT moves out of the segment", "novice", xit, false},
+		{"no limits", "full", state(14200000, tci.ModeUSB, 100), true},
+		{"no segments", "listener", state(7030000, tci.ModeCW, 10), false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newTXPolicy(testTXPolicy(), tc.licenseClass).check(tc.state, "test")
+			if tc.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var requestErr *requestError
+			require.True(t, errors.As(err, &requestErr), "%v", err)
+			assert.Equal(t, resultNotAvailable, requestErr.code)
+		})
+	}
+}
+
+func TestTXPolicy_CheckChange(t *testing.T) {
+	keyed := TRXState{Mode: tci.ModeCW, Drive: 50, Transmitting: true}
+	keyed.VFOs[tci.VFOA].Frequency = 7030000
+	keyed.VFOs[tci.VFOB].Frequency = 10110000
+	receiving := keyed
+	receiving.Transmitting = false
+	tuning := receiving
+	tuning.Tuning = true
+
+	tt := []struct {
+		name    string
+		state   TRXState
+		change  func(*TRXState)
+		allowed bool
+	}{
+		{"frequency in the segment", keyed, func(s *TRXState) { s.VFOs[tci.VFOA].Frequency = 7020000 }, true},
+		{"frequency out of the segment", keyed, func(s *TRXState) { s.VFOs[tci.VFOA].Frequency = 14030000 }, false},
+		{"RX frequency in split mode", keyed, func(s *TRXState) { s.VFOs[tci.VFOB].Frequency = 14030000 }, true},
+		{"split", keyed, func(s *TRXState) { s.SplitEnabled = true }, false},
+		{"XIT", keyed, func(s *TRXState) { s.XITEnabled, s.XITOffset = true, 20000 }, false},
+		{"mode", keyed, func(s *TRXState) { s.Mode = tci.ModeUSB }, false},
+		{"lower drive", keyed, func(s *TRXState) { s.Drive = 30 }, true},
+		{"higher drive", keyed, func(s *TRXState) { s.Drive = 60 }, false},
+		{"higher drive while tuning", tuning, func(s *TRXState) { s.Drive = 60 }, false},
+		{"higher drive while receiving", receiving, func(s *TRXState) { s.Drive = 60 }, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newTXPolicy(testTXPolicy(), "novice").checkChange(tc.state, "test", tc.change)
+			if tc.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var requestErr *requestError
+			require.True(t, errors.As(err, &requestErr), "%v", err)
+			assert.Equal(t, resultNotAvailable, requestErr.code)
+		})
+	}
+}
+
+func TestTXPolicy_DryRun(t *testing.T) {
+	policy := testTXPolicy()
+	policy.DryRun = true
+	state := TRXState{Mode: tci.ModeUSB}
+	state.VFOs[tci.VFOA].Frequency = 14200000
+
+	assert.NoError(t, newTXPolicy(policy, "novice").check(state, "test"))
+	assert.Error(t, newTXPolicy(policy, "novice").violation(state))
+}
+
+func TestTXPolicy_Validate(t *testing.T) {
+	policy := testTXPolicy()
+
+	assert.NoError(t, policy.Validate("", "novice", "listener"))
+	assert.Error(t, policy.Validate("extra"))
+}
+
+func TestConnectionSettings_LicenseClass(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{
+		TXPolicy: testTXPolicy(),
+		Clients:  []ClientSettings{{Networks: []*net.IPNet{network}, LicenseClass: "full"}},
+	})
+
+	assert.Equal(t, "novice", adapter.connectionSettings(0, nil).txPolicy.licenseClass)
+	assert.Equal(t, "full", adapter.connectionSettings(0, &net.TCPAddr{IP: net.ParseIP("192.168.1.10")}).txPolicy.licenseClass)
+}
+
+func TestE2E_TXPolicy(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	setup.adapter.Reconfigure(Settings{TXPolicy: testTXPolicy()})
+
+	// the simulator starts on 7074000 Hz in USB
+	err := rig.SetPTT(e2eContext(t), hamlib.PTTTx)
+	assert.ErrorContains(t, err, "-11")
+	err = rig.SendMorse(e2eContext(t), "CQ")
+	assert.ErrorContains(t, err, "-11")
+
+	require.NoError(t, rig.SetFrequency(e2eContext(t), 7030000))
+	require.NoError(t, rig.SetModeAndPassband(e2eContext(t), hamlib.ModeCW, 0))
+	require.Eventually(t, func() bool {
+		state := setup.adapter.trxListeners[0].trxData.Snapshot()
+		return state.Mode == tci.ModeCW && state.VFOFrequency(tci.VFOA) == 7030000
+	}, e2eTimeout, e2eTick)
+
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTTx))
+	assert.Eventually(t, func() bool { return setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTRx))
+	assert.Eventually(t, func() bool { return !setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+}
+
+func TestE2E_TXPolicyWhileTransmitting(t *testing.T) {
+	setup := startE2E(t, 0)
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+	trxData, err := setup.adapter.trxData(0)
+	require.NoError(t, err)
+	setup.adapter.Reconfigure(Settings{TXPolicy: testTXPolicy()})
+
+	// the simulator starts on 7074000 Hz in USB
+	assert.Equal(t, "RPRT -11", client.request(t, `U TUNER 1`))
+
+	// the requests are sent in this order on the same connection, applied waits until the TRX data is updated
+	tt := []struct {
+		request  string
+		expected string
+		applied  func(TRXState) bool
+	}{
+		{`F 7030000`, "RPRT 0", func(s TRXState) bool { return s.VFOFrequency(tci.VFOA) == 7030000 }},
+		{`M CW 0`, "RPRT 0", func(s TRXState) bool { return s.Mode == tci.ModeCW }},
+		{`L RFPOWER 0.5`, "RPRT 0", func(s TRXState) bool { return s.Drive == 50 }},
+		{`U TUNER 1`, "RPRT 0", func(s TRXState) bool { return s.Tuning }},
+		{`L RFPOWER 0.6`, "RPRT -11", nil},
+		{`F 7100000`, "RPRT -11", nil},
+		{`I 10110000`, "RPRT 0", func(s TRXState) bool { return s.VFOFrequency(tci.VFOB) == 10110000 }},
+		{`S 1 VFOB`, "RPRT -11", nil},
+		{`Z 20000`, "RPRT -11", nil},
+		{`M USB 0`, "RPRT -11", nil},
+		{`X USB 0`, "RPRT -11", nil},
+		{`L RFPOWER 0.3`, "RPRT 0", func(s TRXState) bool { return s.Drive == 30 }},
+		{`F 7020000`, "RPRT 0", func(s TRXState) bool { return s.VFOFrequency(tci.VFOA) == 7020000 }},
+		{`U TUNER 0`, "RPRT 0", func(s TRXState) bool { return !s.Tuning }},
+		{`L RFPOWER 0.6`, "RPRT 0", func(s TRXState) bool { return s.Drive == 60 }},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, client.request(t, tc.request), tc.request)
+		if tc.applied != nil {
+			require.Eventually(t, func() bool { return tc.applied(trxData.Snapshot()) }, e2eTimeout, e2eTick, tc.request)
+		}
+	}
+}
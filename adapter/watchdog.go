@@ -0,0 +1,220 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	tci "github.com/ftl/tci/client"
+)
+
+// The reasons why the TX watchdog unkeys a TRX, they are used as label of the intervention metric.
+const (
+	txTimeExceeded    = "max_tx_time"
+	txOwnerClosed     = "disconnect"
+	txAdapterShutdown = "shutdown"
+)
+
+// flrigTXOwner is the owner of the transmissions that are started through the FLRig frontend, which has no
+// connections.
+const flrigTXOwner = "FLRig"
+
+// txWatchdog unkeys a TRX that was keyed through the adapter if it transmits longer than the maximum TX time, if the
+// client that keyed the TRX closes its connection, or if the adapter shuts down. A TRX is keyed through the PTT,
+// through the tune carrier, or by a CW macro. The watchdog is notified by the TCI client, when the TRX stops
+// transmitting on its own, the watchdog has nothing to do.
+type txWatchdog struct {
+	adapter  *Adapter
+	mutex    sync.Mutex
+	keyDowns map[txKey]*keyDown
+}
+
+// keySource is the way a TRX was keyed through the adapter.
+type keySource int
+
+const (
+	keyedByPTT keySource = iota
+	keyedByTune
+	keyedByCW
+)
+
+func (s keySource) String() string {
+	switch s {
+	case keyedByTune:
+		return "tune"
+	case keyedByCW:
+		return "CW"
+	default:
+		return "PTT"
+	}
+}
+
+// txKey identifies a transmission of a TRX.
+type txKey struct {
+	trx    int
+	source keySource
+}
+
+// keyDown is a transmission that was started through the adapter.
+type keyDown struct {
+	owner string
+	start time.Time
+	timer *time.Timer
+}
+
+func newTXWatchdog(adapter *Adapter) *txWatchdog {
+	return &txWatchdog{
+		adapter:  adapter,
+		keyDowns: make(map[txKey]*keyDown),
+	}
+}
+
+// txSent updates the watchdog after a TX command of the given owner was sent to the TCI server. Like in the
+// Hamlib responses, a timeout is taken as success.
+func (w *txWatchdog) txSent(trx int, owner string, enabled bool, err error) {
+	w.sent(txKey{trx: trx, source: keyedByPTT}, owner, enabled, err)
+}
+
+// tuneSent updates the watchdog after a tune command of the given owner was sent to the TCI server.
+func (w *txWatchdog) tuneSent(trx int, owner string, enabled bool, err error) {
+	w.sent(txKey{trx: trx, source: keyedByTune}, owner, enabled, err)
+}
+
+// cwSent updates the watchdog after a CW macro of the given owner was sent to the TCI server.
+func (w *txWatchdog) cwSent(trx int, owner string, err error) {
+	w.sent(txKey{trx: trx, source: keyedByCW}, owner, true, err)
+}
+
+func (w *txWatchdog) sent(key txKey, owner string, enabled bool, err error) {
+	if w == nil || (err != nil && !errors.Is(err, tci.ErrTimeout)) {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.remove(key)
+	if !enabled {
+		return
+	}
+
+	k := &keyDown{owner: owner, start: time.Now()}
+	maxTXTime := w.adapter.settings.Load().MaxTXTime
+	if maxTXTime > 0 {
+		k.timer = time.AfterFunc(maxTXTime, func() {
+			w.intervene(key, k, txTimeExceeded, fmt.Sprintf("the maximum TX time of %v is exceeded", maxTXTime))
+		})
+	}
+	w.keyDowns[key] = k
+}
+
+// SetTX implements the tci.TXListener interface. When the TRX stops transmitting, the PTT is released. The TRX may
+// stop transmitting between the characters of a CW macro, so the CW macros are only taken as sent if the TCI server
+// does not announce the end of the CW macros, see CWMacrosEmpty.
+func (w *txWatchdog) SetTX(trx int, enabled bool) {
+	if enabled {
+		return
+	}
+	cwMacrosEmptyAnnounced := w.adapter.tciDevice.announcesCWMacrosEmpty()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.remove(txKey{trx: trx, source: keyedByPTT})
+	if !cwMacrosEmptyAnnounced {
+		w.remove(txKey{trx: trx, source: keyedByCW})
+	}
+}
+
+// SetTune implements the tci.TuneListener interface.
+func (w *txWatchdog) SetTune(trx int, enabled bool) {
+	if enabled {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.remove(txKey{trx: trx, source: keyedByTune})
+}
+
+// CWMacrosEmpty implements the tci.CWMacrosEmptyListener interface.
+func (w *txWatchdog) CWMacrosEmpty() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for key := range w.keyDowns {
+		if key.source == keyedByCW {
+			w.remove(key)
+		}
+	}
+}
+
+// remove removes the given transmission, the caller must hold the mutex.
+func (w *txWatchdog) remove(key txKey) {
+	k, ok := w.keyDowns[key]
+	if !ok {
+		return
+	}
+	if k.timer != nil {
+		k.timer.Stop()
+	}
+	delete(w.keyDowns, key)
+}
+
+// release unkeys all TRX that were keyed by the given owner, because the owner closed its connection.
+func (w *txWatchdog) release(owner string) {
+	if w == nil {
+		return
+	}
+	for key, k := range w.ownedKeyDowns(owner) {
+		w.intervene(key, k, txOwnerClosed, fmt.Sprintf("%s was closed", owner))
+	}
+}
+
+// releaseAll unkeys all TRX that were keyed through the adapter, because the adapter shuts down.
+func (w *txWatchdog) releaseAll() {
+	for key, k := range w.ownedKeyDowns("") {
+		w.intervene(key, k, txAdapterShutdown, "the adapter shuts down")
+	}
+}
+
+// ownedKeyDowns returns the transmissions of the given owner, or all transmissions if the owner is empty.
+func (w *txWatchdog) ownedKeyDowns(owner string) map[txKey]*keyDown {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	result := make(map[txKey]*keyDown)
+	for key, k := range w.keyDowns {
+		if owner == "" || k.owner == owner {
+			result[key] = k
+		}
+	}
+	return result
+}
+
+// intervene unkeys the given TRX, unless the given transmission already ended. A tune carrier is switched off, a CW
+// macro is stopped before the TRX is unkeyed.
+func (w *txWatchdog) intervene(key txKey, k *keyDown, reason string, description string) {
+	w.mutex.Lock()
+	if w.keyDowns[key] != k {
+		w.mutex.Unlock()
+		return
+	}
+	w.remove(key)
+	w.mutex.Unlock()
+
+	err := fmt.Errorf("TX watchdog: unkeying TRX %d, keyed through %s by %s %v ago: %s", key.trx, key.source, k.owner, time.Since(k.start).Round(time.Millisecond), description)
+	log.Print(err)
+	w.adapter.errors.add(err)
+	w.adapter.metrics.txWatchdogIntervention(key.trx, reason)
+
+	switch key.source {
+	case keyedByTune:
+		err = w.adapter.tciClient.SetTune(key.trx, false)
+	case keyedByCW:
+		err = w.adapter.tciClient.StopCW()
+		if err == nil || errors.Is(err, tci.ErrTimeout) {
+			err = w.adapter.tciClient.SetTX(key.trx, false, tci.SignalSourceDefault)
+		}
+	default:
+		err = w.adapter.tciClient.SetTX(key.trx, false, tci.SignalSourceDefault)
+	}
+	if err != nil && !errors.Is(err, tci.ErrTimeout) {
+		log.Printf("TX watchdog: cannot unkey TRX %d: %v", key.trx, err)
+	}
+}
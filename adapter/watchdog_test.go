@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTXWatchdog_KeyDowns(t *testing.T) {
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{})
+	watchdog := newTXWatchdog(adapter)
+
+	watchdog.txSent(0, "Hamlib connection 1", true, errors.New("not connected"))
+	assert.Empty(t, watchdog.ownedKeyDowns(""), "failed command")
+
+	watchdog.txSent(0, "Hamlib connection 1", true, tci.ErrTimeout)
+	watchdog.txSent(1, "Hamlib connection 2", true, nil)
+	assert.Len(t, watchdog.ownedKeyDowns(""), 2)
+	assert.Len(t, watchdog.ownedKeyDowns("Hamlib connection 1"), 1)
+
+	watchdog.SetTX(0, false)
+	assert.Empty(t, watchdog.ownedKeyDowns("Hamlib connection 1"), "unkeyed on the TRX")
+
+	watchdog.txSent(1, "Hamlib connection 1", false, nil)
+	assert.Empty(t, watchdog.ownedKeyDowns(""), "unkeyed by another client")
+}
+
+func TestTXWatchdog_KeySources(t *testing.T) {
+	adapter := &Adapter{}
+	adapter.Reconfigure(Settings{})
+
+	tt := []struct {
+		name      string
+		unkey     func(*txWatchdog)
+		remaining []keySource
+	}{
+		{"TX ends", func(w *txWatchdog) { w.SetTX(0, false) }, []keySource{keyedByTune}},
+		{"TX of another TRX ends", func(w *txWatchdog) { w.SetTX(1, false) }, []keySource{keyedByPTT, keyedByTune, keyedByCW}},
+		{"tune ends", func(w *txWatchdog) { w.SetTune(0, false) }, []keySource{keyedByPTT, keyedByCW}},
+		{"tune starts", func(w *txWatchdog) { w.SetTune(0, true) }, []keySource{keyedByPTT, keyedByTune, keyedByCW}},
+		{"CW macros sent", (*txWatchdog).CWMacrosEmpty, []keySource{keyedByPTT, keyedByTune}},
+		{"tune switched off by a client", func(w *txWatchdog) { w.tuneSent(0, "Hamlib connection 2", false, nil) }, []keySource{keyedByPTT, keyedByCW}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			watchdog := newTXWatchdog(adapter)
+			watchdog.txSent(0, "Hamlib connection 1", true, nil)
+			watchdog.tuneSent(0, "Hamlib connection 1", true, nil)
+			watchdog.cwSent(0, "Hamlib connection 1", nil)
+
+			tc.unkey(watchdog)
+
+			remaining := make([]keySource, 0, len(tc.remaining))
+			for key := range watchdog.ownedKeyDowns("") {
+				remaining = append(remaining, key.source)
+			}
+			assert.ElementsMatch(t, tc.remaining, remaining)
+		})
+	}
+}
+
+func TestTXWatchdog_CWUntilMacrosEmpty(t *testing.T) {
+	tt := []struct {
+		version  string
+		expected int
+	}{
+		{"1.4", 0},
+		{"1.5", 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.version, func(t *testing.T) {
+			adapter := &Adapter{}
+			adapter.tciDevice = &announcedDevice{adapter: adapter}
+			adapter.tciDevice.SetProtocol("ExpertSDR3", tc.version)
+			adapter.Reconfigure(Settings{})
+			watchdog := newTXWatchdog(adapter)
+			watchdog.cwSent(0, "Hamlib connection 1", nil)
+
+			watchdog.SetTX(0, false)
+
+			assert.Len(t, watchdog.ownedKeyDowns(""), tc.expected, "the TX ends between the characters")
+			watchdog.CWMacrosEmpty()
+			assert.Empty(t, watchdog.ownedKeyDowns(""))
+		})
+	}
+}
+
+func TestE2E_TXWatchdog_Tune(t *testing.T) {
+	setup := startE2E(t, 0)
+	settings := setup.adapter.Settings()
+	settings.MaxTXTime = 200 * time.Millisecond
+	setup.adapter.Reconfigure(settings)
+	conn, err := net.Dial("tcp", setup.adapter.Addr(0).String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	assert.Equal(t, "RPRT 0", client.request(t, `U TUNER 1`))
+	assert.Eventually(t, func() bool { return setup.sdr.Get("tune", 0)[0] == "true" }, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool { return setup.sdr.Get("tune", 0)[0] == "false" }, e2eTimeout, e2eTick)
+
+	var buffer bytes.Buffer
+	setup.adapter.metrics.write(&buffer, nil)
+	assert.Contains(t, buffer.String(), "tciadapter_tx_watchdog_interventions_total{trx=\"0\",reason=\"max_tx_time\"} 1\n")
+
+	settings.MaxTXTime = 0
+	setup.adapter.Reconfigure(settings)
+	assert.Equal(t, "RPRT 0", client.request(t, `U TUNER 1`))
+	assert.Eventually(t, func() bool { return setup.sdr.Get("tune", 0)[0] == "true" }, e2eTimeout, e2eTick)
+	conn.Close()
+	assert.Eventually(t, func() bool { return setup.sdr.Get("tune", 0)[0] == "false" }, e2eTimeout, e2eTick, "unkeyed on disconnect")
+}
+
+func TestE2E_TXWatchdog_MaxTXTime(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	settings := setup.adapter.Settings()
+	settings.MaxTXTime = 200 * time.Millisecond
+	setup.adapter.Reconfigure(settings)
+
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTTx))
+	assert.Eventually(t, func() bool { return setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+	assert.Eventually(t, func() bool { return !setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+
+	var buffer bytes.Buffer
+	setup.adapter.metrics.write(&buffer, nil)
+	assert.Contains(t, buffer.String(), "tciadapter_tx_watchdog_interventions_total{trx=\"0\",reason=\"max_tx_time\"} 1\n")
+}
+
+func TestE2E_TXWatchdog_ConnectionClosed(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTTx))
+	assert.Eventually(t, func() bool { return setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+	rig.Close()
+	assert.Eventually(t, func() bool { return !setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+}
+
+func TestE2E_TXWatchdog_Shutdown(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTTx))
+	assert.Eventually(t, func() bool { return setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+	setup.adapter.Close()
+	setup.adapter.Wait()
+	assert.False(t, setup.sdr.TX(0))
+}
+
+func TestE2E_TXWatchdog_UnkeyedByClient(t *testing.T) {
+	setup := startE2E(t, 0)
+	rig := setup.openHamlib(t, 0)
+	settings := setup.adapter.Settings()
+	settings.MaxTXTime = 200 * time.Millisecond
+	setup.adapter.Reconfigure(settings)
+
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTTx))
+	require.NoError(t, rig.SetPTT(e2eContext(t), hamlib.PTTRx))
+	assert.Eventually(t, func() bool { return !setup.sdr.TX(0) }, e2eTimeout, e2eTick)
+	assert.Empty(t, setup.adapter.watchdog.ownedKeyDowns(""), "the timer of the transmission is stopped")
+
+	var buffer bytes.Buffer
+	setup.adapter.metrics.write(&buffer, nil)
+	assert.NotContains(t, buffer.String(), "tciadapter_tx_watchdog_interventions_total{")
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlrpcMethodCall is the subset of an XML-RPC request that is used by FLRig clients: a method with scalar parameters.
+type xmlrpcMethodCall struct {
+	XMLName    xml.Name      `xml:"methodCall"`
+	MethodName string        `xml:"methodName"`
+	Params     []xmlrpcValue `xml:"params>param>value"`
+}
+
+type xmlrpcValue struct {
+	String  *string `xml:"string"`
+	Int     *string `xml:"int"`
+	I4      *string `xml:"i4"`
+	Double  *string `xml:"double"`
+	Boolean *string `xml:"boolean"`
+	Text    string  `xml:",chardata"`
+}
+
+// value returns the Go value of the XML-RPC value: string, int, float64 or bool. Values without type are strings.
+func (v xmlrpcValue) value() (any, error) {
+	switch {
+	case v.String != nil:
+		return *v.String, nil
+	case v.Int != nil:
+		return strconv.Atoi(strings.TrimSpace(*v.Int))
+	case v.I4 != nil:
+		return strconv.Atoi(strings.TrimSpace(*v.I4))
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1", nil
+	default:
+		return v.Text, nil
+	}
+}
+
+func readXMLRPCMethodCall(r io.Reader) (string, []any, error) {
+	var call xmlrpcMethodCall
+	err := xml.NewDecoder(r).Decode(&call)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid XML-RPC request: %w", err)
+	}
+	params := make([]any, len(call.Params))
+	for i, param := range call.Params {
+		params[i], err = param.value()
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid parameter %d of %s: %w", i, call.MethodName, err)
+		}
+	}
+	return strings.TrimSpace(call.MethodName), params, nil
+}
+
+func formatXMLRPCResponse(result any) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(`<?xml version="1.0"?>`)
+	buffer.WriteString("<methodResponse><params><param>")
+	writeXMLRPCValue(&buffer, result)
+	buffer.WriteString("</param></params></methodResponse>")
+	return buffer.Bytes()
+}
+
+func formatXMLRPCFault(code int, message string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(`<?xml version="1.0"?>`)
+	buffer.WriteString("<methodResponse><fault><value><struct>")
+	buffer.WriteString("<member><name>faultCode</name>")
+	writeXMLRPCValue(&buffer, code)
+	buffer.WriteString("</member><member><name>faultString</name>")
+	writeXMLRPCValue(&buffer, message)
+	buffer.WriteString("</member></struct></value></fault></methodResponse>")
+	return buffer.Bytes()
+}
+
+func writeXMLRPCValue(buffer *bytes.Buffer, value any) {
+	buffer.WriteString("<value>")
+	switch value := value.(type) {
+	case nil:
+	case string:
+		buffer.WriteString("<string>")
+		xml.EscapeText(buffer, []byte(value))
+		buffer.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buffer, "<i4>%d</i4>", value)
+	case float64:
+		fmt.Fprintf(buffer, "<double>%s</double>", strconv.FormatFloat(value, 'f', -1, 64))
+	case bool:
+		if value {
+			buffer.WriteString("<boolean>1</boolean>")
+		} else {
+			buffer.WriteString("<boolean>0</boolean>")
+		}
+	case []string:
+		buffer.WriteString("<array><data>")
+		for _, element := range value {
+			writeXMLRPCValue(buffer, element)
+		}
+		buffer.WriteString("</data></array>")
+	case []any:
+		buffer.WriteString("<array><data>")
+		for _, element := range value {
+			writeXMLRPCValue(buffer, element)
+		}
+		buffer.WriteString("</data></array>")
+	default:
+		buffer.WriteString("<string>")
+		xml.EscapeText(buffer, []byte(fmt.Sprint(value)))
+		buffer.WriteString("</string>")
+	}
+	buffer.WriteString("</value>")
+}
@@ -0,0 +1,609 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ftl/tciadapter/adapter"
+)
+
+const (
+	configDirName  = "tciadapter"
+	configFileName = "config.yaml"
+	// profileEnvVariable selects the profile if the profile flag is not used, e.g. in a systemd unit.
+	profileEnvVariable = "TCIADAPTER_PROFILE"
+)
+
+// config is the content of the configuration file. The settings of the file are the defaults for all commands, the
+// settings of the selected profile override them. The flags on the command line override both.
+type config struct {
+	TRX      []trxConfig               `yaml:"trx"`
+	Clients  []clientConfig            `yaml:"clients"`
+	Modes    []modeConfig              `yaml:"modes"`
+	TXPolicy *txPolicyConfig           `yaml:"tx_policy"`
+	Access   *accessConfig             `yaml:"access"`
+	Profiles map[string]configSettings `yaml:"profiles"`
+	Flags    map[string]any            `yaml:",inline"`
+}
+
+// configSettings contain the values of the command line flags, with the flag names as keys, the settings
+// for each TRX and for the Hamlib clients, the mode rules, the TX policy, and the access control.
+type configSettings struct {
+	TRX      []trxConfig     `yaml:"trx"`
+	Clients  []clientConfig  `yaml:"clients"`
+	Modes    []modeConfig    `yaml:"modes"`
+	TXPolicy *txPolicyConfig `yaml:"tx_policy"`
+	Access   *accessConfig   `yaml:"access"`
+	Flags    map[string]any  `yaml:",inline"`
+}
+
+// trxConfig is either a TRX in the format of the trx flag or a mapping with the index, the local address and the
+// settings of the TRX.
+type trxConfig struct {
+	TRX         int    `yaml:"trx"`
+	Address     string `yaml:"address"`
+	NoDigimodes *bool  `yaml:"no_digimodes"`
+	VFOMode     *bool  `yaml:"vfo_mode"`
+}
+
+func (c *trxConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		trxArg, address, _ := strings.Cut(node.Value, "=")
+		trx, err := strconv.Atoi(strings.TrimSpace(trxArg))
+		if err != nil || trx < 0 {
+			return fmt.Errorf("line %d: %s is not a valid TRX index", node.Line, trxArg)
+		}
+		*c = trxConfig{TRX: trx, Address: strings.TrimSpace(address)}
+		return nil
+	}
+	type plain trxConfig
+	return node.Decode((*plain)(c))
+}
+
+// arg returns the TRX in the format of the trx flag.
+func (c trxConfig) arg() string {
+	if c.Address == "" {
+		return strconv.Itoa(c.TRX)
+	}
+	return fmt.Sprintf("%d=%s", c.TRX, c.Address)
+}
+
+func (c trxConfig) settings() adapter.TRXSettings {
+	return adapter.TRXSettings{
+		NoDigimodes: c.NoDigimodes,
+		VFOMode:     c.VFOMode,
+	}
+}
+
+// clientConfig contains the settings for the Hamlib clients from the given networks. A network is either given in
+// CIDR notation or as a single IP address.
+type clientConfig struct {
+	Networks     []string     `yaml:"networks"`
+	NoDigimodes  *bool        `yaml:"no_digimodes"`
+	VFOMode      *bool        `yaml:"vfo_mode"`
+	TraceHamlib  *bool        `yaml:"trace_hamlib"`
+	Modes        []modeConfig `yaml:"modes"`
+	LicenseClass string       `yaml:"license_class"`
+	Role         string       `yaml:"role"`
+}
+
+func (c clientConfig) settings() (adapter.ClientSettings, error) {
+	result := adapter.ClientSettings{
+		NoDigimodes:  c.NoDigimodes,
+		VFOMode:      c.VFOMode,
+		TraceHamlib:  c.TraceHamlib,
+		LicenseClass: strings.TrimSpace(c.LicenseClass),
+	}
+	if len(c.Networks) == 0 {
+		return result, fmt.Errorf("no networks")
+	}
+	role, err := adapter.ParseRole(c.Role)
+	if err != nil {
+		return result, err
+	}
+	result.Role = role
+	result.Networks, err = parseNetworks(c.Networks)
+	if err != nil {
+		return result, err
+	}
+	result.Modes, err = modeRules(c.Modes)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// modeConfig is a rule that maps a Hamlib mode onto a TCI mode and back, optionally only in one direction and only on
+// the given bands. A band is either the name of an amateur radio band (e.g. 40m) or a frequency range in Hz
+// (e.g. 7040000-7050000).
+type modeConfig struct {
+	Hamlib    string   `yaml:"hamlib"`
+	TCI       string   `yaml:"tci"`
+	Direction string   `yaml:"direction"`
+	Reverse   bool     `yaml:"reverse"`
+	Bands     []string `yaml:"bands"`
+}
+
+var modeDirections = map[string]adapter.ModeDirection{
+	"":          adapter.BothDirections,
+	"both":      adapter.BothDirections,
+	"to_tci":    adapter.HamlibToTCI,
+	"to_hamlib": adapter.TCIToHamlib,
+}
+
+func (c modeConfig) rule() (adapter.ModeRule, error) {
+	result := adapter.ModeRule{
+		Hamlib:  hamlib.Mode(strings.ToUpper(strings.TrimSpace(c.Hamlib))),
+		TCI:     tci.Mode(strings.ToLower(strings.TrimSpace(c.TCI))),
+		Reverse: c.Reverse,
+	}
+	direction, ok := modeDirections[strings.ToLower(strings.TrimSpace(c.Direction))]
+	if !ok {
+		return result, fmt.Errorf("invalid direction %s", c.Direction)
+	}
+	result.Direction = direction
+	for _, band := range c.Bands {
+		parsedBand, err := parseBand(band)
+		if err != nil {
+			return result, err
+		}
+		result.Bands = append(result.Bands, parsedBand)
+	}
+	return result, result.Validate()
+}
+
+func parseBand(band string) (adapter.Band, error) {
+	band = strings.TrimSpace(band)
+	if result, ok := adapter.FindBand(band); ok {
+		return result, nil
+	}
+	fromArg, toArg, found := strings.Cut(band, "-")
+	if !found {
+		return adapter.Band{}, fmt.Errorf("unknown band %s", band)
+	}
+	from, err := strconv.Atoi(strings.TrimSpace(fromArg))
+	if err != nil {
+		return adapter.Band{}, fmt.Errorf("invalid band %s: %w", band, err)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(toArg))
+	if err != nil {
+		return adapter.Band{}, fmt.Errorf("invalid band %s: %w", band, err)
+	}
+	if from > to {
+		return adapter.Band{}, fmt.Errorf("invalid band %s", band)
+	}
+	return adapter.Band{Name: band, From: from, To: to}, nil
+}
+
+// modeRules converts the given mode rules, keeping their order.
+func modeRules(modes []modeConfig) ([]adapter.ModeRule, error) {
+	var result []adapter.ModeRule
+	for i, mode := range modes {
+		rule, err := mode.rule()
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode rule %d: %w", i+1, err)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// accessConfig contains the access lists for the Hamlib and Kenwood connections, in the same format as the networks
+// of the clients, and the role of all Hamlib clients without a role of their own.
+type accessConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+	Role  string   `yaml:"role"`
+}
+
+// access contains the parsed access control settings.
+type access struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+	role  adapter.Role
+}
+
+func (c *accessConfig) access() (access, error) {
+	var result access
+	if c == nil {
+		return result, nil
+	}
+	var err error
+	result.allow, err = parseNetworks(c.Allow)
+	if err != nil {
+		return result, fmt.Errorf("invalid allow list: %w", err)
+	}
+	result.deny, err = parseNetworks(c.Deny)
+	if err != nil {
+		return result, fmt.Errorf("invalid deny list: %w", err)
+	}
+	result.role, err = adapter.ParseRole(c.Role)
+	return result, err
+}
+
+// txPolicyConfig contains the band plan with the segments in which each license class is allowed to transmit, and
+// the license class of all clients without their own license class.
+type txPolicyConfig struct {
+	LicenseClass string                       `yaml:"license_class"`
+	DryRun       bool                         `yaml:"dry_run"`
+	BandPlan     map[string][]txSegmentConfig `yaml:"band_plan"`
+}
+
+// txSegmentConfig contains the bands in which a license class is allowed to transmit with the given modes and the
+// given maximum drive in percent. The bands have the same format as the bands of a mode rule. Without modes, all
+// modes are allowed, without maximum drive, the drive is not limited.
+type txSegmentConfig struct {
+	Bands    []string `yaml:"bands"`
+	Modes    []string `yaml:"modes"`
+	MaxDrive int      `yaml:"max_drive"`
+}
+
+func (c *txPolicyConfig) policy() (adapter.TXPolicy, error) {
+	if c == nil {
+		return adapter.TXPolicy{}, nil
+	}
+	result := adapter.TXPolicy{
+		LicenseClass: strings.TrimSpace(c.LicenseClass),
+		BandPlan:     make(map[string][]adapter.TXSegment, len(c.BandPlan)),
+		DryRun:       c.DryRun,
+	}
+	for licenseClass, segments := range c.BandPlan {
+		result.BandPlan[licenseClass] = []adapter.TXSegment{}
+		for i, segment := range segments {
+			txSegments, err := segment.segments()
+			if err != nil {
+				return result, fmt.Errorf("license class %s: invalid segment %d: %w", licenseClass, i+1, err)
+			}
+			result.BandPlan[licenseClass] = append(result.BandPlan[licenseClass], txSegments...)
+		}
+	}
+	return result, result.Validate(result.LicenseClass)
+}
+
+// segments returns one TX segment per band.
+func (c txSegmentConfig) segments() ([]adapter.TXSegment, error) {
+	if len(c.Bands) == 0 {
+		return nil, fmt.Errorf("no bands")
+	}
+	if c.MaxDrive < 0 || c.MaxDrive > 100 {
+		return nil, fmt.Errorf("invalid maximum drive %d", c.MaxDrive)
+	}
+	var modes []tci.Mode
+	for _, mode := range c.Modes {
+		modes = append(modes, tci.Mode(strings.ToLower(strings.TrimSpace(mode))))
+	}
+	result := make([]adapter.TXSegment, 0, len(c.Bands))
+	for _, band := range c.Bands {
+		parsedBand, err := parseBand(band)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, adapter.TXSegment{Band: parsedBand, Modes: modes, MaxDrive: c.MaxDrive})
+	}
+	return result, nil
+}
+
+func parseNetworks(networks []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, network := range networks {
+		ipNet, err := parseNetwork(network)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+func parseNetwork(network string) (*net.IPNet, error) {
+	network = strings.TrimSpace(network)
+	if strings.Contains(network, "/") {
+		_, result, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s: %w", network, err)
+		}
+		return result, nil
+	}
+	ip := net.ParseIP(network)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %s", network)
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// loadedConfig contains the configuration that was loaded for the current command.
+var loadedConfig = struct {
+	filename    string
+	profile     string
+	trxSettings map[int]adapter.TRXSettings
+	clients     []adapter.ClientSettings
+	modes       []adapter.ModeRule
+	txPolicy    adapter.TXPolicy
+	access      access
+}{}
+
+// loadConfig loads the configuration file and applies the settings of the file and of the selected profile to all
+// flags of the given command that are not set on the command line.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	// errors in the configuration are not usage errors
+	cmd.SilenceUsage = true
+
+	filename, err := findConfigFile(*rootFlags.config, configSearchPath())
+	if err != nil {
+		return err
+	}
+	profile := *rootFlags.profile
+	if profile == "" {
+		profile = os.Getenv(profileEnvVariable)
+	}
+	if filename == "" {
+		if profile != "" {
+			return fmt.Errorf("the profile %s is selected, but there is no configuration file", profile)
+		}
+		loadedConfig.filename = ""
+		loadedConfig.profile = ""
+		loadedConfig.trxSettings = nil
+		loadedConfig.clients = nil
+		loadedConfig.modes = nil
+		loadedConfig.txPolicy = adapter.TXPolicy{}
+		loadedConfig.access = access{}
+		return nil
+	}
+
+	c, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	if defaultFlagValues == nil {
+		defaultFlagValues = currentFlagValues(cmd.Flags())
+	}
+	settings, err := c.resolve(profile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	err = settings.apply(cmd.Flags(), knownFlags(cmd.Root()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	clients, err := settings.clientSettings()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	modes, err := modeRules(settings.Modes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	txPolicy, err := settings.txPolicy(clients)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	access, err := settings.Access.access()
+	if err != nil {
+		return fmt.Errorf("%s: invalid access: %w", filename, err)
+	}
+
+	loadedConfig.filename = filename
+	loadedConfig.profile = profile
+	loadedConfig.trxSettings = settings.trxSettings()
+	loadedConfig.clients = clients
+	loadedConfig.modes = modes
+	loadedConfig.txPolicy = txPolicy
+	loadedConfig.access = access
+	return nil
+}
+
+// findConfigFile returns the given configuration file, or the first existing file of the search path. It returns
+// an empty filename if no configuration file exists.
+func findConfigFile(explicitFilename string, searchPath []string) (string, error) {
+	if explicitFilename != "" {
+		_, err := os.Stat(explicitFilename)
+		if err != nil {
+			return "", fmt.Errorf("cannot open the configuration file: %w", err)
+		}
+		return explicitFilename, nil
+	}
+	for _, filename := range searchPath {
+		_, err := os.Stat(filename)
+		if err == nil {
+			return filename, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("cannot open the configuration file: %w", err)
+		}
+	}
+	return "", nil
+}
+
+func readConfigFile(filename string) (*config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the configuration file: %w", err)
+	}
+	defer f.Close()
+
+	result := &config{}
+	err = yaml.NewDecoder(f).Decode(result)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cannot read the configuration file %s: %w", filename, err)
+	}
+	return result, nil
+}
+
+// resolve returns the settings of the given profile, merged with the settings of the file. The flags of the profile
+// override the flags of the file, the TRX, client and mode lists, the TX policy and the access control of the profile
+// replace the ones of the file.
+func (c *config) resolve(profile string) (configSettings, error) {
+	result := configSettings{
+		TRX:      c.TRX,
+		Clients:  c.Clients,
+		Modes:    c.Modes,
+		TXPolicy: c.TXPolicy,
+		Access:   c.Access,
+		Flags:    make(map[string]any, len(c.Flags)),
+	}
+	for name, value := range c.Flags {
+		result.Flags[name] = value
+	}
+	if profile == "" {
+		return result, nil
+	}
+
+	profileSettings, ok := c.Profiles[profile]
+	if !ok {
+		return configSettings{}, fmt.Errorf("unknown profile %s", profile)
+	}
+	if profileSettings.TRX != nil {
+		result.TRX = profileSettings.TRX
+	}
+	if profileSettings.Clients != nil {
+		result.Clients = profileSettings.Clients
+	}
+	if profileSettings.Modes != nil {
+		result.Modes = profileSettings.Modes
+	}
+	if profileSettings.TXPolicy != nil {
+		result.TXPolicy = profileSettings.TXPolicy
+	}
+	if profileSettings.Access != nil {
+		result.Access = profileSettings.Access
+	}
+	for name, value := range profileSettings.Flags {
+		result.Flags[name] = value
+	}
+	return result, nil
+}
+
+// apply sets the flags to the values of the settings, unless they are set on the command line. Settings for flags
+// of other commands are ignored.
+func (s configSettings) apply(flags *pflag.FlagSet, knownFlags map[string]bool) error {
+	names := make([]string, 0, len(s.Flags))
+	for name := range s.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("%s cannot be used in the configuration file", name)
+		}
+		flag := flags.Lookup(name)
+		if flag == nil {
+			if knownFlags[name] {
+				continue
+			}
+			return fmt.Errorf("unknown setting %s", name)
+		}
+		if flag.Changed {
+			continue
+		}
+		err := setFlagValue(flag, s.Flags[name])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+
+	if len(s.TRX) == 0 {
+		return nil
+	}
+	flag := flags.Lookup("trx")
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	args := make([]string, len(s.TRX))
+	for i, trx := range s.TRX {
+		args[i] = trx.arg()
+	}
+	return flag.Value.(pflag.SliceValue).Replace(args)
+}
+
+// setFlagValue sets the value of the given flag without marking it as changed, only the command line changes flags.
+func setFlagValue(flag *pflag.Flag, value any) error {
+	switch value := value.(type) {
+	case nil:
+		return fmt.Errorf("no value")
+	case map[string]any:
+		return fmt.Errorf("a mapping is not allowed")
+	case []any:
+		sliceValue, ok := flag.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("a list is not allowed")
+		}
+		values := make([]string, len(value))
+		for i, v := range value {
+			values[i] = fmt.Sprint(v)
+		}
+		return sliceValue.Replace(values)
+	default:
+		return flag.Value.Set(fmt.Sprint(value))
+	}
+}
+
+func (s configSettings) trxSettings() map[int]adapter.TRXSettings {
+	result := make(map[int]adapter.TRXSettings, len(s.TRX))
+	for _, trx := range s.TRX {
+		result[trx.TRX] = trx.settings()
+	}
+	return result
+}
+
+func (s configSettings) clientSettings() ([]adapter.ClientSettings, error) {
+	result := make([]adapter.ClientSettings, 0, len(s.Clients))
+	for i, client := range s.Clients {
+		settings, err := client.settings()
+		if err != nil {
+			return nil, fmt.Errorf("invalid client %d: %w", i+1, err)
+		}
+		result = append(result, settings)
+	}
+	return result, nil
+}
+
+// txPolicy returns the TX policy of the settings, the given clients may only use license classes of its band plan.
+func (s configSettings) txPolicy(clients []adapter.ClientSettings) (adapter.TXPolicy, error) {
+	result, err := s.TXPolicy.policy()
+	if err != nil {
+		return result, fmt.Errorf("invalid TX policy: %w", err)
+	}
+	for i, client := range clients {
+		err := result.Validate(client.LicenseClass)
+		if err != nil {
+			return result, fmt.Errorf("invalid client %d: %w", i+1, err)
+		}
+	}
+	return result, nil
+}
+
+// knownFlags returns the names of the flags of the given command and of all its sub commands.
+func knownFlags(cmd *cobra.Command) map[string]bool {
+	result := make(map[string]bool)
+	var collect func(*cobra.Command)
+	collect = func(cmd *cobra.Command) {
+		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			result[flag.Name] = true
+		})
+		cmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			result[flag.Name] = true
+		})
+		for _, subCmd := range cmd.Commands() {
+			collect(subCmd)
+		}
+	}
+	collect(cmd)
+	return result
+}
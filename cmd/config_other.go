@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configSearchPath returns the locations of the configuration file in the order of precedence: the user's
+// configuration directory, the XDG configuration directories, and /etc/tciadapter.
+func configSearchPath() []string {
+	var result []string
+	userConfigDir, err := os.UserConfigDir()
+	if err == nil {
+		result = append(result, filepath.Join(userConfigDir, configDirName, configFileName))
+	}
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, configDir := range filepath.SplitList(configDirs) {
+		if configDir == "" {
+			continue
+		}
+		result = append(result, filepath.Join(configDir, configDirName, configFileName))
+	}
+	return append(result, filepath.Join("/etc", configDirName, configFileName))
+}
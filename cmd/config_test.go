@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	hamlib "github.com/ftl/rigproxy/pkg/client"
+	tci "github.com/ftl/tci/client"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/tciadapter/adapter"
+)
+
+const testConfig = `
+tci_host: radio:40001
+trace_tci: true
+device: TestSDR
+trx:
+  - 0
+  - trx: 1
+    address: localhost:4533
+    vfo_mode: true
+clients:
+  - networks: [192.168.1.0/24, 10.0.0.1]
+    no_digimodes: true
+profiles:
+  ft8:
+    no_digimodes: true
+    trace_tci: false
+  contest:
+    local_address: localhost:4600
+    trx: ["1=localhost:4601"]
+    clients: []
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), configFileName)
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	return filename
+}
+
+type testFlags struct {
+	flags        *pflag.FlagSet
+	localAddress *string
+	tciHost      *string
+	trx          *[]string
+	traceTCI     *bool
+	noDigimodes  *bool
+}
+
+func newTestFlags() testFlags {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	return testFlags{
+		flags:        flags,
+		localAddress: flags.String("local_address", ":4532", ""),
+		tciHost:      flags.String("tci_host", "localhost:40001", ""),
+		trx:          flags.StringArrayP("trx", "x", []string{"0"}, ""),
+		traceTCI:     flags.Bool("trace_tci", false, ""),
+		noDigimodes:  flags.Bool("no_digimodes", false, ""),
+	}
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	filename := writeTestConfig(t, testConfig)
+	c, err := readConfigFile(filename)
+	require.NoError(t, err)
+	knownFlags := map[string]bool{"device": true}
+
+	tt := []struct {
+		profile      string
+		args         []string
+		localAddress string
+		tciHost      string
+		trx          []string
+		traceTCI     bool
+		noDigimodes  bool
+		clients      int
+	}{
+		{"", nil, ":4532", "radio:40001", []string{"0", "1=localhost:4533"}, true, false, 1},
+		{"ft8", nil, ":4532", "radio:40001", []string{"0", "1=localhost:4533"}, false, true, 1},
+		{"contest", nil, "localhost:4600", "radio:40001", []string{"1=localhost:4601"}, true, false, 0},
+		{"contest", []string{"-x", "2", "--tci_host", "other:40001", "--trace_tci=false"}, "localhost:4600", "other:40001", []string{"2"}, false, false, 0},
+	}
+	for i, tc := range tt {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			f := newTestFlags()
+			require.NoError(t, f.flags.Parse(tc.args))
+
+			settings, err := c.resolve(tc.profile)
+			require.NoError(t, err)
+			require.NoError(t, settings.apply(f.flags, knownFlags))
+			clients, err := settings.clientSettings()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.localAddress, *f.localAddress)
+			assert.Equal(t, tc.tciHost, *f.tciHost)
+			assert.Equal(t, tc.trx, *f.trx)
+			assert.Equal(t, tc.traceTCI, *f.traceTCI)
+			assert.Equal(t, tc.noDigimodes, *f.noDigimodes)
+			assert.Len(t, clients, tc.clients)
+		})
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	tt := []struct {
+		name    string
+		content string
+		profile string
+	}{
+		{"unknown setting", "bogus: 1", ""},
+		{"unknown profile", "trace_tci: true", "ft8"},
+		{"config in file", "config: other.yaml", ""},
+		{"invalid value", "trace_tci: maybe", ""},
+		{"list for scalar flag", "tci_host: [a, b]", ""},
+		{"unknown setting in profile", "profiles:\n  ft8:\n    bogus: 1", "ft8"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := readConfigFile(writeTestConfig(t, tc.content))
+			require.NoError(t, err)
+			settings, err := c.resolve(tc.profile)
+			if err == nil {
+				err = settings.apply(newTestFlags().flags, map[string]bool{})
+			}
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfig_InvalidClients(t *testing.T) {
+	for _, content := range []string{
+		"clients:\n  - vfo_mode: true",
+		"clients:\n  - networks: [192.168.1.0/33]",
+		"clients:\n  - networks: [localhost]",
+	} {
+		c, err := readConfigFile(writeTestConfig(t, content))
+		require.NoError(t, err)
+		settings, err := c.resolve("")
+		require.NoError(t, err)
+		_, err = settings.clientSettings()
+		assert.Error(t, err, content)
+	}
+}
+
+func TestConfig_TRXSettings(t *testing.T) {
+	c, err := readConfigFile(writeTestConfig(t, testConfig))
+	require.NoError(t, err)
+	settings, err := c.resolve("")
+	require.NoError(t, err)
+
+	vfoMode := true
+	assert.Equal(t, map[int]adapter.TRXSettings{
+		0: {},
+		1: {VFOMode: &vfoMode},
+	}, settings.trxSettings())
+}
+
+func TestConfig_Modes(t *testing.T) {
+	content := `
+modes:
+  - hamlib: rtty
+    tci: DIGL
+    bands: [40m, 3570000-3600000]
+  - hamlib: CWR
+    tci: cw
+    reverse: true
+  - hamlib: FAX
+    tci: usb
+    direction: to_tci
+profiles:
+  fm:
+    modes:
+      - hamlib: PKTFM
+        tci: wfm
+`
+	c, err := readConfigFile(writeTestConfig(t, content))
+	require.NoError(t, err)
+	settings, err := c.resolve("")
+	require.NoError(t, err)
+	rules, err := modeRules(settings.Modes)
+	require.NoError(t, err)
+
+	band40m, _ := adapter.FindBand("40m")
+	assert.Equal(t, []adapter.ModeRule{
+		{Hamlib: hamlib.ModeRTTY, TCI: tci.ModeDIGL, Bands: []adapter.Band{band40m, {Name: "3570000-3600000", From: 3570000, To: 3600000}}},
+		{Hamlib: hamlib.ModeCWR, TCI: tci.ModeCW, Reverse: true},
+		{Hamlib: hamlib.ModeFAX, TCI: tci.ModeUSB, Direction: adapter.HamlibToTCI},
+	}, rules)
+
+	settings, err = c.resolve("fm")
+	require.NoError(t, err)
+	rules, err = modeRules(settings.Modes)
+	require.NoError(t, err)
+	assert.Equal(t, []adapter.ModeRule{{Hamlib: hamlib.ModePKTFM, TCI: tci.ModeWFM}}, rules)
+}
+
+func TestConfig_InvalidModes(t *testing.T) {
+	for _, mode := range []modeConfig{
+		{Hamlib: "XYZ", TCI: "usb"},
+		{Hamlib: "USB"},
+		{Hamlib: "USB", TCI: "usb", Direction: "sideways"},
+		{Hamlib: "USB", TCI: "usb", Bands: []string{"11m"}},
+		{Hamlib: "USB", TCI: "usb", Bands: []string{"7100000-7000000"}},
+	} {
+		_, err := modeRules([]modeConfig{mode})
+		assert.Error(t, err, "%+v", mode)
+	}
+}
+
+func TestConfig_TXPolicy(t *testing.T) {
+	content := `
+tx_policy:
+  license_class: novice
+  dry_run: true
+  band_plan:
+    novice:
+      - bands: [80m, 7000000-7040000]
+        modes: [CW, digu]
+        max_drive: 50
+    full:
+      - bands: [20m]
+    listener: []
+clients:
+  - networks: [192.168.1.10]
+    license_class: full
+profiles:
+  open:
+    tx_policy: {}
+`
+	c, err := readConfigFile(writeTestConfig(t, content))
+	require.NoError(t, err)
+	settings, err := c.resolve("")
+	require.NoError(t, err)
+	clients, err := settings.clientSettings()
+	require.NoError(t, err)
+	policy, err := settings.txPolicy(clients)
+	require.NoError(t, err)
+
+	band80m, _ := adapter.FindBand("80m")
+	band20m, _ := adapter.FindBand("20m")
+	modes := []tci.Mode{tci.ModeCW, tci.ModeDIGU}
+	assert.Equal(t, adapter.TXPolicy{
+		LicenseClass: "novice",
+		DryRun:       true,
+		BandPlan: map[string][]adapter.TXSegment{
+			"novice": {
+				{Band: band80m, Modes: modes, MaxDrive: 50},
+				{Band: adapter.Band{Name: "7000000-7040000", From: 7000000, To: 7040000}, Modes: modes, MaxDrive: 50},
+			},
+			"full":     {{Band: band20m}},
+			"listener": {},
+		},
+	}, policy)
+	assert.Equal(t, "full", clients[0].LicenseClass)
+
+	settings, err = c.resolve("open")
+	require.NoError(t, err)
+	policy, err = settings.txPolicy(nil)
+	require.NoError(t, err)
+	assert.Empty(t, policy.LicenseClass)
+}
+
+func TestConfig_InvalidTXPolicy(t *testing.T) {
+	for _, content := range []string{
+		"tx_policy:\n  license_class: novice",
+		"tx_policy:\n  band_plan:\n    novice:\n      - modes: [cw]",
+		"tx_policy:\n  band_plan:\n    novice:\n      - bands: [11m]",
+		"tx_policy:\n  band_plan:\n    novice:\n      - bands: [40m]\n        max_drive: 101",
+		"tx_policy:\n  band_plan:\n    novice: []\nclients:\n  - networks: [10.0.0.1]\n    license_class: extra",
+	} {
+		c, err := readConfigFile(writeTestConfig(t, content))
+		require.NoError(t, err)
+		settings, err := c.resolve("")
+		require.NoError(t, err)
+		clients, err := settings.clientSettings()
+		require.NoError(t, err)
+		_, err = settings.txPolicy(clients)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestConfig_Access(t *testing.T) {
+	content := `
+access:
+  allow: [192.168.1.0/24]
+  deny: [192.168.1.99]
+  role: read_only
+clients:
+  - networks: [192.168.1.10]
+    role: full
+profiles:
+  open:
+    access: {}
+`
+	c, err := readConfigFile(writeTestConfig(t, content))
+	require.NoError(t, err)
+	settings, err := c.resolve("")
+	require.NoError(t, err)
+	access, err := settings.Access.access()
+	require.NoError(t, err)
+	clients, err := settings.clientSettings()
+	require.NoError(t, err)
+
+	assert.Equal(t, "192.168.1.0/24", access.allow[0].String())
+	assert.Equal(t, "192.168.1.99/32", access.deny[0].String())
+	assert.Equal(t, adapter.RoleReadOnly, access.role)
+	assert.Equal(t, adapter.RoleFull, clients[0].Role)
+
+	settings, err = c.resolve("open")
+	require.NoError(t, err)
+	access, err = settings.Access.access()
+	require.NoError(t, err)
+	assert.Empty(t, access.allow)
+	assert.Empty(t, access.role)
+}
+
+func TestConfig_InvalidAccess(t *testing.T) {
+	for _, content := range []string{
+		"access:\n  allow: [192.168.1.0/33]",
+		"access:\n  deny: [localhost]",
+		"access:\n  role: admin",
+		"clients:\n  - networks: [10.0.0.1]\n    role: admin",
+	} {
+		c, err := readConfigFile(writeTestConfig(t, content))
+		require.NoError(t, err)
+		settings, err := c.resolve("")
+		require.NoError(t, err)
+		_, accessErr := settings.Access.access()
+		_, clientsErr := settings.clientSettings()
+		assert.True(t, accessErr != nil || clientsErr != nil, content)
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.yaml")
+	existing := writeTestConfig(t, "")
+
+	filename, err := findConfigFile("", []string{missing, existing})
+	require.NoError(t, err)
+	assert.Equal(t, existing, filename)
+
+	filename, err = findConfigFile("", []string{missing})
+	require.NoError(t, err)
+	assert.Equal(t, "", filename)
+
+	_, err = findConfigFile(missing, []string{existing})
+	assert.Error(t, err)
+}
+
+func TestFlagValues_Restore(t *testing.T) {
+	f := newTestFlags()
+	require.NoError(t, f.flags.Parse([]string{"--tci_host", "radio:40001"}))
+	defaults := currentFlagValues(f.flags)
+
+	c, err := readConfigFile(writeTestConfig(t, testConfig))
+	require.NoError(t, err)
+	settings, err := c.resolve("contest")
+	require.NoError(t, err)
+	require.NoError(t, settings.apply(f.flags, map[string]bool{"device": true}))
+	require.Equal(t, "localhost:4600", *f.localAddress)
+
+	require.NoError(t, defaults.restore(f.flags))
+
+	assert.Equal(t, ":4532", *f.localAddress)
+	assert.Equal(t, []string{"0"}, *f.trx)
+	assert.False(t, *f.traceTCI)
+	assert.Equal(t, "radio:40001", *f.tciHost, "flags on the command line are kept")
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configSearchPath returns the locations of the configuration file in the order of precedence: the user's
+// configuration directory (%AppData%) and %ProgramData%. The Windows service uses %ProgramData%, unless the
+// configuration file is given explicitly.
+func configSearchPath() []string {
+	var result []string
+	userConfigDir, err := os.UserConfigDir()
+	if err == nil {
+		result = append(result, filepath.Join(userConfigDir, configDirName, configFileName))
+	}
+	programData := os.Getenv("ProgramData")
+	if programData != "" {
+		result = append(result, filepath.Join(programData, configDirName, configFileName))
+	}
+	return result
+}
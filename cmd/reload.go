@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/ftl/tciadapter/adapter"
+)
+
+// restartFlags are the flags that can only be changed with a restart of the adapter.
+var restartFlags = []string{"kenwood_address", "kenwood_pty", "flrig_address", "multicast_address", "metrics_address", "dashboard_address", "record"}
+
+// flagValues contains the values of all flags of a command, to reset the flags before the configuration is reloaded.
+type flagValues map[string][]string
+
+// defaultFlagValues contains the values of the flags before the configuration file was applied for the first time.
+var defaultFlagValues flagValues
+
+func currentFlagValues(flags *pflag.FlagSet) flagValues {
+	result := make(flagValues)
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			result[flag.Name] = append([]string{}, sliceValue.GetSlice()...)
+		} else {
+			result[flag.Name] = []string{flag.Value.String()}
+		}
+	})
+	return result
+}
+
+// restore sets all flags that are not set on the command line back to the stored values.
+func (v flagValues) restore(flags *pflag.FlagSet) error {
+	var result error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		values, ok := v[flag.Name]
+		if flag.Changed || !ok || result != nil {
+			return
+		}
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			result = sliceValue.Replace(values)
+		} else {
+			result = flag.Value.Set(values[0])
+		}
+		if result != nil {
+			result = fmt.Errorf("cannot restore %s: %w", flag.Name, result)
+		}
+	})
+	return result
+}
+
+// notifyReload catches SIGHUP from now on, instead of terminating the process. Call it before the adapter starts,
+// a SIGHUP during the start is handled after the start.
+func notifyReload() <-chan os.Signal {
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	return reloads
+}
+
+// handleReload reloads the configuration whenever the process receives SIGHUP through the given channel. In the
+// simulation, the TCI host is the simulated radio and cannot be changed.
+func handleReload(cmd *cobra.Command, reloads <-chan os.Signal, a *adapter.Adapter, switchTCIHost bool) {
+	go func() {
+		for range reloads {
+			log.Print("reloading the configuration")
+			err := reloadConfig(cmd, a, switchTCIHost)
+			if err != nil {
+				log.Printf("reloading the configuration failed: %v", err)
+				continue
+			}
+			logFlags()
+			log.Print("the configuration was reloaded")
+		}
+	}()
+}
+
+// reloadConfig loads the configuration file again and applies the changes to the running adapter. The trace
+// flags, the digimode override, the VFO mode, the maximum TX time, the mode rules, the TX policy, the access control,
+// the TLS certificates, the password, and the TRX and client settings are applied immediately. The Hamlib listeners
+// are moved to their new local addresses and the adapter switches to the new TCI host, without closing the open
+// Hamlib connections. Other changes need a restart of the adapter. If the configuration cannot be loaded, the adapter
+// keeps the current configuration.
+func reloadConfig(cmd *cobra.Command, a *adapter.Adapter, switchTCIHost bool) error {
+	flags := cmd.Flags()
+	previous := currentFlagValues(flags)
+	err := defaultFlagValues.restore(flags)
+	if err == nil {
+		err = loadConfig(cmd, nil)
+	}
+	if err == nil {
+		err = applyConfig(a, previous, switchTCIHost)
+	}
+	if err != nil {
+		restoreErr := previous.restore(flags)
+		if restoreErr != nil {
+			log.Print(restoreErr)
+		}
+		return err
+	}
+
+	for _, name := range restartFlags {
+		flag := flags.Lookup(name)
+		if flag != nil && flag.Value.String() != previous[name][0] {
+			log.Printf("%s changed, the change is applied after a restart", name)
+		}
+	}
+	return nil
+}
+
+func applyConfig(a *adapter.Adapter, previous flagValues, switchTCIHost bool) error {
+	trxAddresses, err := parseTRXArgs(*rootFlags.trx, *rootFlags.localAddress)
+	if err != nil {
+		return fmt.Errorf("invalid trx: %w", err)
+	}
+	for i, trxAddress := range trxAddresses {
+		trxAddresses[i].Settings = loadedConfig.trxSettings[trxAddress.TRX]
+	}
+	var tciHost *adapter.TCIHost
+	if tciHostChanged(previous) {
+		if !switchTCIHost {
+			log.Print("tci_host changed, the TCI host cannot be changed in the simulation")
+		} else {
+			tciHost, err = tciHostFromFlags()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	previousTRXAddresses, err := parseTRXArgs(previous["trx"], previous["local_address"][0])
+	if err == nil && !sameTRX(previousTRXAddresses, trxAddresses) {
+		log.Print("the TRX selection changed, the change is applied after a restart")
+	}
+	settings, err := adapterSettings(trxAddresses)
+	if err != nil {
+		return err
+	}
+	err = checkUnprotectedFrontends()
+	if err != nil {
+		return err
+	}
+	err = a.Rebind(providedTRX(a, trxAddresses))
+	if err != nil {
+		return err
+	}
+	a.Reconfigure(settings)
+	if tciHost != nil {
+		a.SetTCIHost(tciHost)
+	}
+	return nil
+}
+
+// tciHostChanged indicates if the tci_host flag or the TLS settings for the TCI host changed.
+func tciHostChanged(previous flagValues) bool {
+	return *rootFlags.tciHost != previous["tci_host"][0] ||
+		*rootFlags.tciCA != previous["tci_ca"][0] ||
+		strconv.FormatBool(*rootFlags.tciInsecure) != previous["tci_insecure"][0]
+}
+
+// sameTRX indicates if both lists select the same TRX, regardless of their local addresses.
+func sameTRX(a, b []adapter.TRXAddress) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	selected := make(map[int]bool, len(a))
+	for _, trxAddress := range a {
+		selected[trxAddress.TRX] = true
+	}
+	for _, trxAddress := range b {
+		if !selected[trxAddress.TRX] {
+			return false
+		}
+	}
+	return true
+}
+
+// providedTRX returns the given TRX that are provided by the adapter.
+func providedTRX(a *adapter.Adapter, trxAddresses []adapter.TRXAddress) []adapter.TRXAddress {
+	result := make([]adapter.TRXAddress, 0, len(trxAddresses))
+	for _, trxAddress := range trxAddresses {
+		if a.Addr(trxAddress.TRX) != nil {
+			result = append(result, trxAddress)
+		}
+	}
+	return result
+}
+
+// adapterSettings returns the settings of the adapter that can be changed while the adapter is running.
+func adapterSettings(trxAddresses []adapter.TRXAddress) (adapter.Settings, error) {
+	tlsConfig, err := tlsConfig(*rootFlags.tlsCert, *rootFlags.tlsKey, *rootFlags.tlsClientCA)
+	if err != nil {
+		return adapter.Settings{}, err
+	}
+	result := adapter.Settings{
+		TraceHamlib: *rootFlags.traceHamlib,
+		TraceTCI:    *rootFlags.traceTCI,
+		NoDigimodes: *rootFlags.noDigimodes,
+		VFOMode:     *rootFlags.vfoMode,
+		MaxTXTime:   *rootFlags.maxTXTime,
+		Modes:       loadedConfig.modes,
+		TXPolicy:    loadedConfig.txPolicy,
+		Allow:       loadedConfig.access.allow,
+		Deny:        loadedConfig.access.deny,
+		Role:        loadedConfig.access.role,
+		TLS:         tlsConfig,
+		Password:    *rootFlags.password,
+		TRX:         make(map[int]adapter.TRXSettings, len(trxAddresses)),
+		Clients:     loadedConfig.clients,
+	}
+	for _, trxAddress := range trxAddresses {
+		result.TRX[trxAddress.TRX] = trxAddress.Settings
+	}
+	return result, nil
+}
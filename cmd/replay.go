@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/tciadapter/adapter"
+)
+
+var replayFlags = struct {
+	timeout *time.Duration
+}{}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Replay a recording and check that the adapter still behaves the same",
+	Long: `Replay a recording and check that the adapter still behaves the same.
+
+The replay feeds the recorded TCI messages and Hamlib requests into a new adapter and compares the
+Hamlib responses and TCI commands of the adapter with the recording. Recordings are created with the
+--record parameter.`,
+	Args: cobra.ExactArgs(1),
+	Run:  replay,
+}
+
+func init() {
+	replayFlags.timeout = replayCmd.Flags().DurationP("timeout", "", time.Second, "Wait this long for each expected message")
+
+	rootCmd.AddCommand(replayCmd)
+}
+
+func replay(cmd *cobra.Command, args []string) {
+	log.Printf("TCI-Hamlib Adapter %s", cmd.Version)
+	err := adapter.ReplayFile(args[0], *replayFlags.timeout)
+	if err != nil {
+		log.Fatalf("the replay of %s failed:\n%v", args[0], err)
+	}
+	log.Printf("the replay of %s was successful", args[0])
+}
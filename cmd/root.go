@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ftl/tci/client"
 	"github.com/spf13/cobra"
@@ -18,12 +21,29 @@ import (
 )
 
 var rootFlags = struct {
+	config       *string
+	profile      *string
 	localAddress *string
 	tciHost      *string
-	trx          *int
+	tciCA        *string
+	tciInsecure  *bool
+	trx          *[]string
 	traceHamlib  *bool
 	traceTCI     *bool
 	noDigimodes  *bool
+	vfoMode      *bool
+	maxTXTime    *time.Duration
+	tlsCert      *string
+	tlsKey       *string
+	tlsClientCA  *string
+	password     *string
+	kenwoodAddr  *string
+	kenwoodPTY   *string
+	multicast    *string
+	flrigAddr    *string
+	record       *string
+	metricsAddr  *string
+	dashboard    *string
 }{}
 
 var rootCmd = &cobra.Command{
@@ -40,16 +60,59 @@ func Execute(version string) {
 }
 
 func init() {
-	rootFlags.localAddress = rootCmd.PersistentFlags().StringP("local_address", "l", ":4532", "Use this local address to listen for incoming Hamlib connections")
-	rootFlags.tciHost = rootCmd.PersistentFlags().StringP("tci_host", "t", "localhost:40001", "Connect the adapter to this TCI host")
-	rootFlags.trx = rootCmd.PersistentFlags().IntP("trx", "x", 0, "Use this TRX of the TCI host")
+	rootCmd.PersistentPreRunE = loadConfig
+
+	rootFlags.config = rootCmd.PersistentFlags().StringP("config", "", "", "Load the settings from this configuration file instead of searching the default locations")
+	rootFlags.profile = rootCmd.PersistentFlags().StringP("profile", "", "", "Apply this profile of the configuration file (default $"+profileEnvVariable+")")
+	rootFlags.localAddress = rootCmd.PersistentFlags().StringP("local_address", "l", "localhost:4532", "Use this local address to listen for incoming Hamlib connections")
+	rootFlags.tciHost = rootCmd.PersistentFlags().StringP("tci_host", "t", "localhost:40001", "Connect the adapter to this TCI host, as <host>:<port> or as ws:// or wss:// URL with optional path")
+	rootFlags.tciCA = rootCmd.PersistentFlags().StringP("tci_ca", "", "", "Verify the certificate of a wss:// TCI host with the CAs in this file (PEM) instead of the system CAs")
+	rootFlags.tciInsecure = rootCmd.PersistentFlags().BoolP("tci_insecure", "", false, "Do not verify the certificate of a wss:// TCI host")
+	rootFlags.trx = rootCmd.PersistentFlags().StringArrayP("trx", "x", []string{"0"}, "Use this TRX of the TCI host, optionally with its own local address as <trx>=<address> (can be used multiple times)")
 	rootFlags.traceHamlib = rootCmd.PersistentFlags().BoolP("trace_hamlib", "", false, "Trace the Hamlib set commands on the console")
 	rootFlags.traceTCI = rootCmd.PersistentFlags().BoolP("trace_tci", "", false, "Trace the TCI communication on the console")
 	rootFlags.noDigimodes = rootCmd.PersistentFlags().BoolP("no_digimodes", "d", false, "Use LSB/USB instead of the digital modes DIGL/DIGU")
+	rootFlags.vfoMode = rootCmd.PersistentFlags().BoolP("vfo_mode", "o", false, "Start Hamlib connections in VFO mode, the target VFO is passed with each command")
+	rootFlags.maxTXTime = rootCmd.PersistentFlags().DurationP("max_tx_time", "", 0, "Unkey the TRX if a transmission that was started through the adapter takes longer than this duration (e.g. 3m, 0 = no limit)")
+	rootFlags.tlsCert = rootCmd.PersistentFlags().StringP("tls_cert", "", "", "Use TLS for the Hamlib connections with this certificate file (PEM), needs --tls_key")
+	rootFlags.tlsKey = rootCmd.PersistentFlags().StringP("tls_key", "", "", "Use this private key file (PEM) for the TLS certificate of the Hamlib connections")
+	rootFlags.tlsClientCA = rootCmd.PersistentFlags().StringP("tls_client_ca", "", "", "Accept only Hamlib clients with a TLS client certificate that is signed by a CA in this file (PEM)")
+	rootFlags.password = rootCmd.PersistentFlags().StringP("password", "", "", "Require Hamlib clients to send this password (\\password) before any other request, better set it in the configuration file")
+	rootFlags.kenwoodAddr = rootCmd.PersistentFlags().StringP("kenwood_address", "", "", "Use this local address to listen for incoming Kenwood TS-2000 CAT connections to the first TRX")
+	rootFlags.flrigAddr = rootCmd.PersistentFlags().StringP("flrig_address", "", "", "Use this local address to listen for incoming FLRig XML-RPC requests to the first TRX (e.g. localhost:12345)")
+	rootFlags.multicast = rootCmd.PersistentFlags().StringP("multicast_address", "", "", "Publish the TRX state as JSON packets to this UDP address, like rigctld's multicast data publisher (e.g. 224.0.0.1:4532)")
+	rootFlags.dashboard = rootCmd.PersistentFlags().StringP("dashboard_address", "", "", "Provide a web dashboard with the live state of the adapter on this local address (e.g. localhost:8532)")
+	rootFlags.metricsAddr = rootCmd.PersistentFlags().StringP("metrics_address", "", "", "Provide the metrics of the adapter in the Prometheus text format on this local address (e.g. localhost:9532)")
+	rootFlags.record = rootCmd.PersistentFlags().StringP("record", "", "", "Record the Hamlib and the TCI communication to this file, the recording can be checked with the replay command")
+	rootFlags.kenwoodPTY = rootCmd.PersistentFlags().StringP("kenwood_pty", "", "", "Provide the Kenwood TS-2000 CAT protocol for the first TRX on a pseudo terminal that is linked to this path (Linux only)")
 }
 
 func root(cmd *cobra.Command, args []string) {
 	log.Printf("TCI-Hamlib Adapter %s", cmd.Version)
+	logFlags()
+	tciHost, err := tciHostFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go handleCancelation(signals, cancel)
+	reloads := notifyReload()
+
+	adapter := startAdapter(tciHost, ctx.Done(), cmd.Version)
+	handleReload(cmd, reloads, adapter, true)
+	adapter.Wait()
+}
+
+func logFlags() {
+	if loadedConfig.filename != "" {
+		log.Printf("using the configuration file %s", loadedConfig.filename)
+	}
+	if loadedConfig.profile != "" {
+		log.Printf("using the profile %s", loadedConfig.profile)
+	}
 	if *rootFlags.traceHamlib {
 		log.Print("hamlib tracing enabled")
 	}
@@ -59,24 +122,151 @@ func root(cmd *cobra.Command, args []string) {
 	if *rootFlags.noDigimodes {
 		log.Print("no_digimodes: using LSB/USB instead of DIGL/DIGU")
 	}
-	tciHost, err := parseTCPAddrArg(*rootFlags.tciHost, "localhost", 40001)
+	if *rootFlags.vfoMode {
+		log.Print("vfo_mode: Hamlib connections start in VFO mode")
+	}
+	if *rootFlags.maxTXTime > 0 {
+		log.Printf("max_tx_time: transmissions are limited to %v", *rootFlags.maxTXTime)
+	}
+	if *rootFlags.tciInsecure {
+		log.Print("tci_insecure: the certificate of the TCI host is not verified")
+	}
+	if *rootFlags.tlsCert != "" {
+		log.Print("tls_cert: Hamlib connections use TLS")
+	}
+	if *rootFlags.tlsClientCA != "" {
+		log.Print("tls_client_ca: Hamlib clients need a TLS client certificate")
+	}
+	if *rootFlags.password != "" {
+		log.Print("password: Hamlib clients need to send the password")
+	}
+}
+
+// startAdapter starts the adapter and all frontends that are selected through the root flags.
+func startAdapter(tciHost *adapter.TCIHost, done <-chan struct{}, version string) *adapter.Adapter {
+	trxAddresses, err := parseTRXArgs(*rootFlags.trx, *rootFlags.localAddress)
 	if err != nil {
-		log.Fatalf("invalid tci_host: %v", err)
+		log.Fatalf("invalid trx: %v", err)
 	}
-	if tciHost.Port == 0 {
-		tciHost.Port = client.DefaultPort
+	for i, trxAddress := range trxAddresses {
+		trxAddresses[i].Settings = loadedConfig.trxSettings[trxAddress.TRX]
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	go handleCancelation(signals, cancel)
+	var recorder *adapter.Recorder
+	if *rootFlags.record != "" {
+		recorder, err = adapter.CreateRecording(*rootFlags.record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("recording the Hamlib and TCI communication to %s", *rootFlags.record)
+	}
 
-	adapter, err := adapter.Listen(*rootFlags.localAddress, tciHost, *rootFlags.trx, ctx.Done(), *rootFlags.traceHamlib, *rootFlags.traceTCI, *rootFlags.noDigimodes, cmd.Version)
+	settings, err := adapterSettings(trxAddresses)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = checkUnprotectedFrontends()
+	if err != nil {
+		log.Fatal(err)
+	}
+	adapter, err := adapter.Listen(trxAddresses, tciHost, done, settings, version, recorder)
 	if err != nil {
 		log.Fatalf("starting the adapter failed: %v", err)
 	}
-	adapter.Wait()
+	startKenwood(adapter, trxAddresses[0].TRX)
+	startFLRig(adapter, trxAddresses[0].TRX)
+	startMulticast(adapter)
+	startMetrics(adapter)
+	startDashboard(adapter)
+	return adapter
+}
+
+func startKenwood(a *adapter.Adapter, trx int) {
+	if *rootFlags.kenwoodAddr != "" {
+		err := a.ListenKenwood(trx, *rootFlags.kenwoodAddr)
+		if err != nil {
+			log.Fatalf("starting the Kenwood frontend failed: %v", err)
+		}
+	}
+	if *rootFlags.kenwoodPTY != "" {
+		err := a.OpenKenwoodPTY(trx, *rootFlags.kenwoodPTY)
+		if err != nil {
+			log.Fatalf("starting the Kenwood frontend failed: %v", err)
+		}
+	}
+}
+
+func startFLRig(a *adapter.Adapter, trx int) {
+	if *rootFlags.flrigAddr == "" {
+		return
+	}
+	err := a.ListenFLRig(trx, *rootFlags.flrigAddr)
+	if err != nil {
+		log.Fatalf("starting the FLRig frontend failed: %v", err)
+	}
+}
+
+// checkUnprotectedFrontends returns an error if the Hamlib connections are protected by a password or TLS, but the
+// Kenwood or the FLRig frontend is reachable from other hosts. These frontends have neither password nor TLS, they
+// would bypass the protection of the Hamlib connections.
+func checkUnprotectedFrontends() error {
+	if *rootFlags.password == "" && *rootFlags.tlsCert == "" {
+		return nil
+	}
+	frontends := []struct {
+		flag    string
+		address string
+	}{
+		{"kenwood_address", *rootFlags.kenwoodAddr},
+		{"flrig_address", *rootFlags.flrigAddr},
+	}
+	for _, frontend := range frontends {
+		if frontend.address != "" && !isLoopbackAddress(frontend.address) {
+			return fmt.Errorf("%s: the frontend has no password and no TLS, it must listen on localhost if the Hamlib connections use a password or TLS", frontend.flag)
+		}
+	}
+	return nil
+}
+
+// isLoopbackAddress indicates if the given local address can only be reached from this host. An address without host
+// listens on all interfaces.
+func isLoopbackAddress(address string) bool {
+	host, _ := splitHostPort(address)
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func startMulticast(a *adapter.Adapter) {
+	if *rootFlags.multicast == "" {
+		return
+	}
+	err := a.PublishMulticast(*rootFlags.multicast)
+	if err != nil {
+		log.Fatalf("starting the multicast publisher failed: %v", err)
+	}
+}
+
+func startMetrics(a *adapter.Adapter) {
+	if *rootFlags.metricsAddr == "" {
+		return
+	}
+	err := a.ListenMetrics(*rootFlags.metricsAddr)
+	if err != nil {
+		log.Fatalf("starting the metrics listener failed: %v", err)
+	}
+}
+
+func startDashboard(a *adapter.Adapter) {
+	if *rootFlags.dashboard == "" {
+		return
+	}
+	err := a.ListenDashboard(*rootFlags.dashboard)
+	if err != nil {
+		log.Fatalf("starting the dashboard failed: %v", err)
+	}
 }
 
 func handleCancelation(signals <-chan os.Signal, cancel context.CancelFunc) {
@@ -94,6 +284,92 @@ func handleCancelation(signals <-chan os.Signal, cancel context.CancelFunc) {
 	}
 }
 
+// parseTRXArgs parses the values of the trx flag. Each value is either the index of a TRX or <trx>=<address>.
+// A TRX without address uses the given default address.
+func parseTRXArgs(args []string, defaultAddress string) ([]adapter.TRXAddress, error) {
+	result := make([]adapter.TRXAddress, 0, len(args))
+	usedAddresses := make(map[string]int)
+	usedTRX := make(map[int]bool)
+	for _, arg := range args {
+		trxArg, address, found := strings.Cut(arg, "=")
+		if !found {
+			address = defaultAddress
+		}
+		trx, err := strconv.Atoi(strings.TrimSpace(trxArg))
+		if err != nil || trx < 0 {
+			return nil, fmt.Errorf("%s is not a valid TRX index", trxArg)
+		}
+		if usedTRX[trx] {
+			return nil, fmt.Errorf("TRX %d is given more than once", trx)
+		}
+		usedTRX[trx] = true
+		address = strings.TrimSpace(address)
+		if address == "" {
+			return nil, fmt.Errorf("no local address for TRX %d", trx)
+		}
+		if otherTRX, ok := usedAddresses[address]; ok {
+			return nil, fmt.Errorf("TRX %d and TRX %d cannot both use the local address %s", otherTRX, trx, address)
+		}
+		usedAddresses[address] = trx
+		result = append(result, adapter.TRXAddress{TRX: trx, LocalAddress: address})
+	}
+	return result, nil
+}
+
+// tciHostFromFlags returns the TCI host that is selected through the root flags.
+func tciHostFromFlags() (*adapter.TCIHost, error) {
+	tciURL, err := parseTCIHostArg(*rootFlags.tciHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tci_host: %w", err)
+	}
+	tlsConfig, err := tciTLSConfig(*rootFlags.tciCA, *rootFlags.tciInsecure)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil && tciURL.Scheme != "wss" {
+		return nil, fmt.Errorf("tci_ca and tci_insecure need a wss:// URL as tci_host")
+	}
+	return &adapter.TCIHost{URL: tciURL, TLS: tlsConfig}, nil
+}
+
+// parseTCIHostArg parses the value of the tci_host flag. The value is either <host>:<port> for a plain WebSocket
+// connection or a ws:// or wss:// URL, optionally with path and with user and password for basic authentication.
+// Without port, the default TCI port is used for host and port and for ws:// URLs, wss:// URLs use the HTTPS port.
+func parseTCIHostArg(arg string) (*url.URL, error) {
+	if !strings.Contains(arg, "://") {
+		addr, err := parseTCPAddrArg(arg, "localhost", 40001)
+		if err != nil {
+			return nil, err
+		}
+		if addr.Port == 0 {
+			addr.Port = client.DefaultPort
+		}
+		return &url.URL{Scheme: "ws", Host: addr.String()}, nil
+	}
+
+	result, err := url.Parse(arg)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// the URL may contain a password and is not part of the error
+			return nil, fmt.Errorf("invalid URL: %w", urlErr.Err)
+		}
+		return nil, err
+	}
+	switch result.Scheme {
+	case "ws", "wss":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s, use ws or wss", result.Scheme)
+	}
+	if result.Hostname() == "" {
+		return nil, fmt.Errorf("no host in %s", result.Redacted())
+	}
+	if result.Scheme == "ws" && result.Port() == "" {
+		result.Host = net.JoinHostPort(result.Hostname(), strconv.Itoa(client.DefaultPort))
+	}
+	return result, nil
+}
+
 func parseTCPAddrArg(arg string, defaultHost string, defaultPort int) (*net.TCPAddr, error) {
 	host, port := splitHostPort(arg)
 	if host == "" {
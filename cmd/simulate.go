@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/tciadapter/adapter"
+	"github.com/ftl/tciadapter/simulator"
+)
+
+var simulateFlags = struct {
+	device         *string
+	trxCount       *int
+	minFrequency   *int
+	maxFrequency   *int
+	modes          *[]string
+	smeterLevel    *int
+	smeterNoise    *int
+	smeterInterval *time.Duration
+	traceSimulator *bool
+}{}
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Run the adapter on top of a simulated TCI radio, without a real SDR",
+	Long: `Run the adapter on top of a simulated TCI radio, without a real SDR.
+
+The simulated radio listens on the tci_host address, so other TCI applications can also connect to it. If tci_host is
+a URL, the simulated radio listens on the host and port of the URL with plain WebSocket.`,
+	Run: simulate,
+}
+
+func init() {
+	simulateFlags.device = simulateCmd.Flags().StringP("device", "", simulator.DefaultConfig.DeviceName, "The device name of the simulated radio")
+	simulateFlags.trxCount = simulateCmd.Flags().IntP("trx_count", "", simulator.DefaultConfig.TRXCount, "The number of TRX of the simulated radio")
+	simulateFlags.minFrequency = simulateCmd.Flags().IntP("min_frequency", "", simulator.DefaultConfig.MinVFOFrequency, "The lower band edge of the simulated radio in Hz")
+	simulateFlags.maxFrequency = simulateCmd.Flags().IntP("max_frequency", "", simulator.DefaultConfig.MaxVFOFrequency, "The upper band edge of the simulated radio in Hz")
+	simulateFlags.modes = simulateCmd.Flags().StringSliceP("modes", "", simulator.DefaultConfig.Modes, "The modes of the simulated radio")
+	simulateFlags.smeterLevel = simulateCmd.Flags().IntP("smeter", "", simulator.DefaultConfig.SMeterLevel, "The simulated signal level in dBm")
+	simulateFlags.smeterNoise = simulateCmd.Flags().IntP("noise", "", 3, "The maximum random deviation of the simulated signal level in dB")
+	simulateFlags.smeterInterval = simulateCmd.Flags().DurationP("smeter_interval", "", 200*time.Millisecond, "Send the simulated S-meter readings in this interval")
+	simulateFlags.traceSimulator = simulateCmd.Flags().BoolP("trace_simulator", "", false, "Trace the TCI communication of the simulated radio on the console")
+
+	rootCmd.AddCommand(simulateCmd)
+}
+
+func simulate(cmd *cobra.Command, args []string) {
+	log.Printf("TCI-Hamlib Adapter %s", cmd.Version)
+	logFlags()
+	tciURL, err := parseTCIHostArg(*rootFlags.tciHost)
+	if err != nil {
+		log.Fatalf("invalid tci_host: %v", err)
+	}
+	if tciURL.Scheme != "ws" || tciURL.User != nil || strings.Trim(tciURL.Path, "/") != "" {
+		log.Printf("tci_host: the simulated radio listens on %s with plain WebSocket, without TLS, path and authentication", tciURL.Host)
+	}
+	if *simulateFlags.trxCount < 1 {
+		log.Fatal("the simulated radio needs at least one TRX")
+	}
+	if *simulateFlags.minFrequency >= *simulateFlags.maxFrequency {
+		log.Fatalf("invalid band edges: %d Hz - %d Hz", *simulateFlags.minFrequency, *simulateFlags.maxFrequency)
+	}
+
+	config := simulator.DefaultConfig
+	config.DeviceName = *simulateFlags.device
+	config.TRXCount = *simulateFlags.trxCount
+	config.MinVFOFrequency = *simulateFlags.minFrequency
+	config.MaxVFOFrequency = *simulateFlags.maxFrequency
+	config.Modes = *simulateFlags.modes
+	config.SMeterLevel = *simulateFlags.smeterLevel
+	config.SMeterNoise = *simulateFlags.smeterNoise
+	config.SMeterInterval = *simulateFlags.smeterInterval
+	config.Trace = *simulateFlags.traceSimulator
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go handleCancelation(signals, cancel)
+	reloads := notifyReload()
+
+	radio, err := simulator.Start(tciURL.Host, config)
+	if err != nil {
+		log.Fatalf("starting the simulated radio failed: %v", err)
+	}
+	defer radio.Close()
+	log.Printf("simulating a %s with %d TRX on %s", config.DeviceName, config.TRXCount, radio.Addr())
+
+	adapter := startAdapter(adapter.TCIHostAt(radio.Addr()), ctx.Done(), cmd.Version)
+	handleReload(cmd, reloads, adapter, false)
+	adapter.Wait()
+}
@@ -8,12 +8,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
-	"github.com/ftl/tci/client"
-	"github.com/ftl/tciadapter/adapter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/eventlog"
@@ -67,20 +65,9 @@ func install(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	serviceArgs := []string{
-		"service",
-		"-l", *rootFlags.localAddress,
-		"-t", *rootFlags.tciHost,
-		"-x", strconv.Itoa(*rootFlags.trx),
-	}
-	if *rootFlags.traceHamlib {
-		serviceArgs = append(serviceArgs, "--trace_hamlib")
-	}
-	if *rootFlags.traceTCI {
-		serviceArgs = append(serviceArgs, "--trace_tci")
-	}
-	if *rootFlags.noDigimodes {
-		serviceArgs = append(serviceArgs, "-d")
+	serviceArgs, err := buildServiceArgs()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	serviceConfig := mgr.Config{
@@ -145,6 +132,37 @@ func uninstall(cmd *cobra.Command, args []string) {
 	log.Print("the tciadapter Windows service was sucessfully uninstalled")
 }
 
+// buildServiceArgs returns the arguments for the service command. The service uses the flags that are set on the
+// command line, the other settings are read from the configuration file when the service starts.
+func buildServiceArgs() ([]string, error) {
+	result := []string{"service"}
+	if loadedConfig.filename != "" {
+		// the service runs with another user account, it cannot search the configuration file in the user's
+		// configuration directory
+		filename, err := filepath.Abs(loadedConfig.filename)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, "--config", filename)
+	}
+	if loadedConfig.profile != "" {
+		result = append(result, "--profile", loadedConfig.profile)
+	}
+	rootCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if !flag.Changed || flag.Name == "config" || flag.Name == "profile" {
+			return
+		}
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range sliceValue.GetSlice() {
+				result = append(result, "--"+flag.Name, value)
+			}
+			return
+		}
+		result = append(result, "--"+flag.Name+"="+flag.Value.String())
+	})
+	return result, nil
+}
+
 func exePath() (string, error) {
 	prog := os.Args[0]
 	p, err := filepath.Abs(prog)
@@ -179,28 +197,14 @@ func (s *serviceHandler) Execute(args []string, requests <-chan svc.ChangeReques
 	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
 	changes <- svc.Status{State: svc.StartPending}
 
-	if *rootFlags.traceHamlib {
-		log.Print("hamlib tracing enabled")
-	}
-	if *rootFlags.traceTCI {
-		log.Print("TCI tracing enabled")
-	}
-	if *rootFlags.noDigimodes {
-		log.Print("no_digimodes: using LSB/USB instead of DIGL/DIGU")
-	}
-	tciHost, err := parseTCPAddrArg(*rootFlags.tciHost, "localhost", 40001)
+	logFlags()
+	tciHost, err := tciHostFromFlags()
 	if err != nil {
-		log.Fatalf("invalid tci_host: %v", err)
-	}
-	if tciHost.Port == 0 {
-		tciHost.Port = client.DefaultPort
+		log.Fatal(err)
 	}
 	done := make(chan struct{})
 
-	adapter, err := adapter.Listen(*rootFlags.localAddress, tciHost, *rootFlags.trx, done, *rootFlags.traceHamlib, *rootFlags.traceTCI, *rootFlags.noDigimodes, s.version)
-	if err != nil {
-		log.Fatalf("starting the adapter failed: %v", err)
-	}
+	adapter := startAdapter(tciHost, done, s.version)
 
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 	for {
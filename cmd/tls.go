@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsConfig loads the TLS configuration for the Hamlib connections from the given PEM files. Without certificate, the
+// Hamlib connections do not use TLS and the result is nil. With a client CA file, the clients must present a
+// certificate that is signed by one of the CAs in this file.
+func tlsConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("tls_client_ca needs tls_cert and tls_key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("tls_cert and tls_key must be used together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the TLS certificate: %w", err)
+	}
+	result := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		return result, nil
+	}
+
+	clientCAs, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the TLS client CA: %w", err)
+	}
+	result.ClientCAs = x509.NewCertPool()
+	if !result.ClientCAs.AppendCertsFromPEM(clientCAs) {
+		return nil, fmt.Errorf("no certificates found in the TLS client CA file %s", clientCAFile)
+	}
+	result.ClientAuth = tls.RequireAndVerifyClientCert
+	return result, nil
+}
+
+// tciTLSConfig returns the TLS configuration for a wss:// connection to the TCI host. With a CA file, the certificate
+// of the TCI host must be signed by one of the CAs in this file. Without CA file and without insecure option, the
+// result is nil and the system CAs are used.
+func tciTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && !insecure {
+		return nil, nil
+	}
+	result := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile == "" {
+		return result, nil
+	}
+
+	cas, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the TCI host CA: %w", err)
+	}
+	result.RootCAs = x509.NewCertPool()
+	if !result.RootCAs.AppendCertsFromPEM(cas) {
+		return nil, fmt.Errorf("no certificates found in the TCI host CA file %s", caFile)
+	}
+	return result, nil
+}
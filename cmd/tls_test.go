@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a self-signed certificate and its private key as PEM files and returns the filenames.
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+
+	config, err := tlsConfig("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = tlsConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+
+	config, err = tlsConfig(certFile, keyFile, certFile)
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.NotNil(t, config.ClientCAs)
+}
+
+func TestTLSConfig_Errors(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tt := []struct {
+		name                            string
+		certFile, keyFile, clientCAFile string
+	}{
+		{"certificate without key", certFile, "", ""},
+		{"key without certificate", "", keyFile, ""},
+		{"client CA without certificate", "", "", certFile},
+		{"missing certificate", missing, keyFile, ""},
+		{"key as certificate", keyFile, keyFile, ""},
+		{"missing client CA", certFile, keyFile, missing},
+		{"client CA without certificates", certFile, keyFile, keyFile},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tlsConfig(tc.certFile, tc.keyFile, tc.clientCAFile)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTCITLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+
+	config, err := tciTLSConfig("", false)
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = tciTLSConfig("", true)
+	require.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+
+	config, err = tciTLSConfig(certFile, false)
+	require.NoError(t, err)
+	assert.False(t, config.InsecureSkipVerify)
+	assert.NotNil(t, config.RootCAs)
+
+	_, err = tciTLSConfig(keyFile, false)
+	assert.Error(t, err, "no certificates")
+	_, err = tciTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), false)
+	assert.Error(t, err, "missing CA file")
+}
@@ -2,21 +2,27 @@ module github.com/ftl/tciadapter
 
 go 1.26
 
-// replace github.com/ftl/tci => ../tci
+// the TCI client with the disconnect fix and the dialer, until they are part of a release of github.com/ftl/tci
+replace github.com/ftl/tci => ../tci
 
 // replace github.com/ftl/rigproxy => ../rigproxy
 
 require (
+	github.com/creack/pty v1.1.24
 	github.com/ftl/rigproxy v0.2.3
 	github.com/ftl/tci v0.3.3
+	github.com/gorilla/websocket v1.5.0
 	github.com/spf13/cobra v1.6.1
+	github.com/spf13/pflag v1.0.5
+	github.com/stretchr/testify v1.8.2
 	golang.org/x/sys v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/ftl/hamradio v0.2.6 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
@@ -0,0 +1,480 @@
+// Package simulator provides a fake TCI server that simulates an SDR. It can be used to test the adapter without
+// a real SDR and to demonstrate the adapter.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Config describes the simulated SDR.
+type Config struct {
+	DeviceName      string
+	ProtocolName    string
+	ProtocolVersion string
+	TRXCount        int
+	MinVFOFrequency int
+	MaxVFOFrequency int
+	MinIFFrequency  int
+	MaxIFFrequency  int
+	Modes           []string
+	Trace           bool
+
+	// SMeterLevel is the simulated signal level in dBm.
+	SMeterLevel int
+	// SMeterNoise is the maximum random deviation of the S-meter readings from the SMeterLevel in dB.
+	SMeterNoise int
+	// SMeterInterval defines how often the S-meter readings are sent to the clients. The simulator does not send
+	// S-meter readings if the interval is zero.
+	SMeterInterval time.Duration
+}
+
+// DefaultConfig simulates a SunSDR2 PRO with two TRX.
+var DefaultConfig = Config{
+	DeviceName:      "SunSDR2PRO",
+	ProtocolName:    "ExpertSDR3",
+	ProtocolVersion: "1.8",
+	TRXCount:        2,
+	MinVFOFrequency: 10000,
+	MaxVFOFrequency: 30000000,
+	MinIFFrequency:  -48000,
+	MaxIFFrequency:  48000,
+	Modes:           []string{"AM", "SAM", "DSB", "LSB", "USB", "CW", "NFM", "DIGL", "DIGU", "WFM", "DRM"},
+	SMeterLevel:     -100,
+}
+
+// keyArgs defines for each stateful TCI command how many of its arguments identify the parameter (e.g. the TRX and
+// the VFO). A message with only these arguments is a request for the current value, a message with more arguments
+// sets the value.
+var keyArgs = map[string]int{
+	"vfo":             2,
+	"rx_volume":       2,
+	"rx_smeter":       2,
+	"drive":           1,
+	"tune_drive":      1,
+	"modulation":      1,
+	"rx_filter_band":  1,
+	"split_enable":    1,
+	"rit_enable":      1,
+	"rit_offset":      1,
+	"xit_enable":      1,
+	"xit_offset":      1,
+	"trx":             1,
+	"tune":            1,
+	"rx_enable":       1,
+	"rx_mute":         1,
+	"rx_nb_enable":    1,
+	"rx_nr_enable":    1,
+	"rx_anf_enable":   1,
+	"rx_apf_enable":   1,
+	"lock":            1,
+	"volume":          0,
+	"mute":            0,
+	"sql_enable":      0,
+	"sql_level":       0,
+	"cw_macros_speed": 0,
+	"cw_macros_delay": 0,
+}
+
+// Server is a fake TCI server. It keeps the state of all parameters that are set by the clients and notifies all
+// clients about changes, like a real TCI server.
+type Server struct {
+	config     Config
+	listener   net.Listener
+	httpServer *http.Server
+	upgrader   websocket.Upgrader
+	closed     chan struct{}
+	closeOnce  sync.Once
+
+	mutex    sync.Mutex
+	values   map[string][]string
+	order    []string
+	cwMacros []string
+	clients  map[*serverClient]bool
+}
+
+type serverClient struct {
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+}
+
+// Start starts a new simulator that listens on the given local address.
+func Start(localAddress string, config Config) (*Server, error) {
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open local port %s: %w", localAddress, err)
+	}
+
+	result := &Server{
+		config:   config,
+		listener: listener,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		closed:  make(chan struct{}),
+		values:  make(map[string][]string),
+		clients: make(map[*serverClient]bool),
+	}
+	result.initState()
+	result.httpServer = &http.Server{Handler: result}
+	if config.SMeterInterval > 0 {
+		go result.generateSMeter()
+	}
+
+	go func() {
+		err := result.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("TCI simulator: %v", err)
+		}
+	}()
+	return result, nil
+}
+
+func (s *Server) initState() {
+	for trx := 0; trx < s.config.TRXCount; trx++ {
+		s.setValue("vfo", []string{strconv.Itoa(trx), "0", strconv.Itoa(s.clampFrequency(7074000))})
+		s.setValue("vfo", []string{strconv.Itoa(trx), "1", strconv.Itoa(s.clampFrequency(7076000))})
+		s.setValue("rx_smeter", []string{strconv.Itoa(trx), "0", strconv.Itoa(s.config.SMeterLevel)})
+		s.setValue("rx_smeter", []string{strconv.Itoa(trx), "1", strconv.Itoa(s.config.SMeterLevel)})
+		s.setValue("modulation", []string{strconv.Itoa(trx), "usb"})
+		s.setValue("rx_filter_band", []string{strconv.Itoa(trx), "100", "2800"})
+		s.setValue("split_enable", []string{strconv.Itoa(trx), "false"})
+		s.setValue("rit_enable", []string{strconv.Itoa(trx), "false"})
+		s.setValue("rit_offset", []string{strconv.Itoa(trx), "0"})
+		s.setValue("xit_enable", []string{strconv.Itoa(trx), "false"})
+		s.setValue("xit_offset", []string{strconv.Itoa(trx), "0"})
+		s.setValue("trx", []string{strconv.Itoa(trx), "false"})
+		s.setValue("tune", []string{strconv.Itoa(trx), "false"})
+		s.setValue("drive", []string{strconv.Itoa(trx), "50"})
+	}
+	s.setValue("volume", []string{"-20"})
+	s.setValue("cw_macros_speed", []string{"24"})
+}
+
+// Addr returns the local address of the simulator.
+func (s *Server) Addr() *net.TCPAddr {
+	return s.listener.Addr().(*net.TCPAddr)
+}
+
+// Close closes the simulator and all client connections.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.httpServer.Close()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client := range s.clients {
+		client.conn.Close()
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("TCI simulator: %v", err)
+		return
+	}
+	client := &serverClient{conn: conn}
+	s.mutex.Lock()
+	for _, message := range s.handshake() {
+		client.send(message, s.config.Trace)
+	}
+	s.clients[client] = true
+	s.mutex.Unlock()
+
+	go s.serve(client)
+}
+
+func (s *Server) handshake() []string {
+	result := []string{
+		fmt.Sprintf("protocol:%s,%s;", s.config.ProtocolName, s.config.ProtocolVersion),
+		fmt.Sprintf("device:%s;", s.config.DeviceName),
+		"receive_only:false;",
+		fmt.Sprintf("trx_count:%d;", s.config.TRXCount),
+		"channels_count:2;",
+		fmt.Sprintf("vfo_limits:%d,%d;", s.config.MinVFOFrequency, s.config.MaxVFOFrequency),
+		fmt.Sprintf("if_limits:%d,%d;", s.config.MinIFFrequency, s.config.MaxIFFrequency),
+		fmt.Sprintf("modulations_list:%s;", strings.Join(s.config.Modes, ",")),
+	}
+	for _, key := range s.order {
+		result = append(result, formatMessage(s.values[key]))
+	}
+	return append(result, "ready;")
+}
+
+func (s *Server) serve(client *serverClient) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, client)
+		s.mutex.Unlock()
+		client.conn.Close()
+	}()
+	for {
+		msgType, msg, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		for _, message := range strings.Split(string(msg), ";") {
+			message = strings.TrimSpace(message)
+			if message == "" {
+				continue
+			}
+			if s.config.Trace {
+				log.Printf("TCI simulator < %s;", message)
+			}
+			s.handleMessage(client, message)
+		}
+	}
+}
+
+func (s *Server) handleMessage(client *serverClient, message string) {
+	name, args := parseMessage(message)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch name {
+	case "cw_macros":
+		if len(args) < 2 {
+			return
+		}
+		s.cwMacros = append(s.cwMacros, unescapeCWText(args[1]))
+		s.broadcast(formatMessage(append([]string{name}, args...)))
+		// the simulator does not transmit, the CW macro is sent immediately
+		s.broadcast(formatMessage([]string{"cw_macros_empty"}))
+		return
+	case "cw_macros_stop", "start", "stop":
+		s.broadcast(formatMessage(append([]string{name}, args...)))
+		return
+	}
+
+	keyCount, ok := keyArgs[name]
+	if !ok {
+		if s.config.Trace {
+			log.Printf("TCI simulator: unsupported message %s", message)
+		}
+		return
+	}
+	switch {
+	case len(args) < keyCount:
+		return
+	case len(args) == keyCount, name == "vfo" && !s.validFrequency(args[2]):
+		value, ok := s.values[valueKey(name, args[:keyCount])]
+		if ok {
+			client.send(formatMessage(value), s.config.Trace)
+		}
+	default:
+		s.broadcast(formatMessage(s.setValue(name, args)))
+	}
+}
+
+// setValue stores the given value and returns the complete message. It must only be called while holding the lock.
+func (s *Server) setValue(name string, args []string) []string {
+	key := valueKey(name, args[:keyArgs[name]])
+	if _, ok := s.values[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	value := append([]string{name}, args...)
+	s.values[key] = value
+	return value
+}
+
+// validFrequency indicates if the given frequency is within the VFO limits. Like a real SDR, the simulator
+// ignores frequencies outside of the VFO limits.
+func (s *Server) validFrequency(arg string) bool {
+	frequency, err := strconv.Atoi(arg)
+	if err != nil {
+		return false
+	}
+	return frequency == s.clampFrequency(frequency)
+}
+
+func (s *Server) clampFrequency(frequency int) int {
+	if s.config.MinVFOFrequency > 0 && frequency < s.config.MinVFOFrequency {
+		return s.config.MinVFOFrequency
+	}
+	if s.config.MaxVFOFrequency > 0 && frequency > s.config.MaxVFOFrequency {
+		return s.config.MaxVFOFrequency
+	}
+	return frequency
+}
+
+// generateSMeter sends S-meter readings for all receivers to the clients, until the simulator is closed.
+func (s *Server) generateSMeter() {
+	ticker := time.NewTicker(s.config.SMeterInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+		}
+
+		s.mutex.Lock()
+		for trx := 0; trx < s.config.TRXCount; trx++ {
+			for vfo := 0; vfo < 2; vfo++ {
+				level := s.config.SMeterLevel
+				if s.config.SMeterNoise > 0 {
+					level += rand.Intn(2*s.config.SMeterNoise+1) - s.config.SMeterNoise
+				}
+				value := s.setValue("rx_smeter", []string{strconv.Itoa(trx), strconv.Itoa(vfo), strconv.Itoa(level)})
+				s.broadcast(formatMessage(value))
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// broadcast must only be called while holding the lock.
+func (s *Server) broadcast(message string) {
+	for client := range s.clients {
+		client.send(message, s.config.Trace)
+	}
+}
+
+func (c *serverClient) send(message string, trace bool) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if trace {
+		log.Printf("TCI simulator > %s", message)
+	}
+	err := c.conn.WriteMessage(websocket.TextMessage, []byte(message))
+	if err != nil {
+		log.Printf("TCI simulator: %v", err)
+	}
+}
+
+// Set changes the given parameter like a user would do on the SDR, e.g. Set("vfo", 0, 0, 14074000).
+// All clients are notified about the change.
+func (s *Server) Set(name string, args ...any) {
+	stringArgs := formatArgs(args)
+	if len(stringArgs) <= keyArgs[name] {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.broadcast(formatMessage(s.setValue(name, stringArgs)))
+}
+
+// Get returns the current value of the given parameter, e.g. Get("vfo", 0, 1) returns the frequency of VFO B
+// of the first TRX. If the parameter is unknown, Get returns an empty slice.
+func (s *Server) Get(name string, keys ...any) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[valueKey(name, formatArgs(keys))]
+	if !ok {
+		return []string{}
+	}
+	return append([]string(nil), value[1+len(keys):]...)
+}
+
+// VFOFrequency returns the frequency of the given VFO in Hz.
+func (s *Server) VFOFrequency(trx int, vfo int) int {
+	return s.intValue("vfo", trx, vfo)
+}
+
+// Mode returns the mode of the given TRX in lower case.
+func (s *Server) Mode(trx int) string {
+	value := s.Get("modulation", trx)
+	if len(value) == 0 {
+		return ""
+	}
+	return strings.ToLower(value[0])
+}
+
+// RXFilterBand returns the lower and the upper edge of the RX filter of the given TRX in Hz.
+func (s *Server) RXFilterBand(trx int) (int, int) {
+	value := s.Get("rx_filter_band", trx)
+	if len(value) < 2 {
+		return 0, 0
+	}
+	min, _ := strconv.Atoi(value[0])
+	max, _ := strconv.Atoi(value[1])
+	return min, max
+}
+
+// SplitEnable indicates if split mode is enabled on the given TRX.
+func (s *Server) SplitEnable(trx int) bool {
+	return s.boolValue("split_enable", trx)
+}
+
+// TX indicates if the given TRX transmits.
+func (s *Server) TX(trx int) bool {
+	return s.boolValue("trx", trx)
+}
+
+// CWMacros returns all CW texts that were sent through the cw_macros command.
+func (s *Server) CWMacros() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.cwMacros...)
+}
+
+func (s *Server) intValue(name string, keys ...any) int {
+	value := s.Get(name, keys...)
+	if len(value) == 0 {
+		return 0
+	}
+	result, _ := strconv.Atoi(value[0])
+	return result
+}
+
+func (s *Server) boolValue(name string, keys ...any) bool {
+	value := s.Get(name, keys...)
+	if len(value) == 0 {
+		return false
+	}
+	return value[0] == "true" || value[0] == "1"
+}
+
+func parseMessage(message string) (string, []string) {
+	name, args, found := strings.Cut(message, ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !found {
+		return name, nil
+	}
+	result := strings.Split(args, ",")
+	for i := range result {
+		result[i] = strings.TrimSpace(result[i])
+	}
+	return name, result
+}
+
+func formatMessage(value []string) string {
+	if len(value) == 1 {
+		return value[0] + ";"
+	}
+	return value[0] + ":" + strings.Join(value[1:], ",") + ";"
+}
+
+func formatArgs(args []any) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = fmt.Sprint(arg)
+	}
+	return result
+}
+
+func valueKey(name string, keys []string) string {
+	return name + ":" + strings.Join(keys, ",")
+}
+
+func unescapeCWText(text string) string {
+	if text == "_" {
+		return ""
+	}
+	result := strings.ReplaceAll(text, "^", ":")
+	result = strings.ReplaceAll(result, "~", ",")
+	return strings.ReplaceAll(result, "*", ";")
+}
@@ -0,0 +1,111 @@
+package simulator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := Start("127.0.0.1:0", DefaultConfig)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// connect opens a websocket connection to the given simulator and reads the handshake. It returns the client side
+// of the connection and the server's representation of the new client.
+func connect(t *testing.T, s *Server) (*websocket.Conn, *serverClient) {
+	t.Helper()
+	s.mutex.Lock()
+	known := make(map[*serverClient]bool, len(s.clients))
+	for client := range s.clients {
+		known[client] = true
+	}
+	s.mutex.Unlock()
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", s.Addr()), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	for readMessage(t, conn) != "ready;" {
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client := range s.clients {
+		if !known[client] {
+			return conn, client
+		}
+	}
+	require.FailNow(t, "the simulator did not register the client")
+	return nil, nil
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return string(message)
+}
+
+func TestHandleMessage_GetAndSet(t *testing.T) {
+	s := startTestServer(t)
+	conn, client := connect(t, s)
+	otherConn, _ := connect(t, s)
+
+	s.handleMessage(client, "vfo:0,0")
+	assert.Equal(t, "vfo:0,0,7074000;", readMessage(t, conn))
+
+	s.handleMessage(client, "modulation:0")
+	assert.Equal(t, "modulation:0,usb;", readMessage(t, conn))
+
+	// too few key arguments are ignored
+	s.handleMessage(client, "vfo:0")
+
+	s.handleMessage(client, "vfo:0,0,14074000")
+	assert.Equal(t, "vfo:0,0,14074000;", readMessage(t, conn))
+	assert.Equal(t, 14074000, s.VFOFrequency(0, 0))
+
+	// only the setter is broadcast to the other client, the answers to the queries are not
+	assert.Equal(t, "vfo:0,0,14074000;", readMessage(t, otherConn))
+}
+
+func TestHandleMessage_RejectsVFOOutOfRange(t *testing.T) {
+	s := startTestServer(t)
+	conn, client := connect(t, s)
+	otherConn, _ := connect(t, s)
+
+	for _, frequency := range []string{"5000", "50000000", "invalid"} {
+		s.handleMessage(client, "vfo:0,0,"+frequency)
+		assert.Equal(t, "vfo:0,0,7074000;", readMessage(t, conn), frequency)
+		assert.Equal(t, 7074000, s.VFOFrequency(0, 0), frequency)
+	}
+
+	s.handleMessage(client, "vfo:0,0,30000000")
+	assert.Equal(t, "vfo:0,0,30000000;", readMessage(t, conn))
+	assert.Equal(t, "vfo:0,0,30000000;", readMessage(t, otherConn))
+}
+
+func TestHandleMessage_CWMacros(t *testing.T) {
+	s := startTestServer(t)
+	conn, client := connect(t, s)
+	otherConn, _ := connect(t, s)
+
+	s.handleMessage(client, "cw_macros:0,cq~cq de dl0abc^ k*")
+
+	for _, c := range []*websocket.Conn{conn, otherConn} {
+		assert.Equal(t, "cw_macros:0,cq~cq de dl0abc^ k*;", readMessage(t, c))
+		assert.Equal(t, "cw_macros_empty;", readMessage(t, c))
+	}
+	assert.Equal(t, []string{"cq,cq de dl0abc: k;"}, s.CWMacros())
+
+	// incomplete macros are ignored
+	s.handleMessage(client, "cw_macros:0")
+	assert.Equal(t, []string{"cq,cq de dl0abc: k;"}, s.CWMacros())
+}